@@ -6,8 +6,12 @@ import (
 	"os"
 
 	"github.com/deb-ict/cloudbm-community/pkg/hosting"
+	"github.com/deb-ict/cloudbm-community/pkg/http/bearer"
+	"github.com/deb-ict/cloudbm-community/pkg/http/clientinfo"
+	"github.com/deb-ict/cloudbm-community/pkg/http/middleware"
 	"gopkg.in/yaml.v3"
 
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/oauth"
 	auth_svc "github.com/deb-ict/cloudbm-community/pkg/module/auth/service"
 	contact_svc "github.com/deb-ict/cloudbm-community/pkg/module/contact/service"
 	gallery_svc "github.com/deb-ict/cloudbm-community/pkg/module/gallery/service"
@@ -16,22 +20,30 @@ import (
 )
 
 type config struct {
-	Http           hosting.HttpConfig         `yaml:"http"`
-	AuthService    auth_svc.ServiceOptions    `yaml:"auth_service"`
-	ContactService contact_svc.ServiceOptions `yaml:"contact_service"`
-	GalleryService gallery_svc.ServiceOptions `yaml:"gallery_service"`
-	ProductService product_svc.ServiceOptions `yaml:"product_service"`
-	SessionService session_svc.ServiceOptions `yaml:"session_service"`
+	Http           hosting.HttpConfig                 `yaml:"http"`
+	OAuth          oauth.TokenHandlerOptions          `yaml:"oauth"`
+	Authentication bearer.ValidatorConfig             `yaml:"authentication"`
+	ClientInfo     clientinfo.MiddlewareOptions       `yaml:"client_info"`
+	AuthService    auth_svc.ServiceOptions            `yaml:"auth_service"`
+	ContactService contact_svc.ServiceOptions         `yaml:"contact_service"`
+	GalleryService gallery_svc.ServiceOptions         `yaml:"gallery_service"`
+	ProductService product_svc.ServiceOptions         `yaml:"product_service"`
+	SessionService session_svc.ServiceOptions         `yaml:"session_service"`
+	SessionCookie  middleware.SessionMiddlewareConfig `yaml:"session_cookie"`
 }
 
 func LoadConfig(configPath string) (*config, error) {
 	cfg := &config{
 		Http:           hosting.HttpConfig{},
+		OAuth:          oauth.TokenHandlerOptions{},
+		Authentication: bearer.ValidatorConfig{},
+		ClientInfo:     clientinfo.MiddlewareOptions{},
 		AuthService:    auth_svc.ServiceOptions{},
 		ContactService: contact_svc.ServiceOptions{},
 		GalleryService: gallery_svc.ServiceOptions{},
 		ProductService: product_svc.ServiceOptions{},
 		SessionService: session_svc.ServiceOptions{},
+		SessionCookie:  middleware.SessionMiddlewareConfig{},
 	}
 	err := cfg.loadYaml(configPath)
 	cfg.loadEnvironment()
@@ -74,13 +86,28 @@ func (cfg *config) loadYaml(configPath string) error {
 
 func (cfg *config) loadEnvironment() {
 	cfg.Http.LoadEnvironment()
+	cfg.OAuth.LoadEnvironment()
+	cfg.Authentication.LoadEnvironment()
+	cfg.AuthService.Smtp.LoadEnvironment()
+	cfg.AuthService.Verification.LoadEnvironment()
+	cfg.GalleryService.LoadEnvironment()
 }
 
 func (cfg *config) ensureDefaults() {
 	cfg.Http.EnsureDefaults()
+	cfg.OAuth.EnsureDefaults()
+	// Accept the tokens issued by the local token endpoint, unless configured otherwise
+	if cfg.Authentication.Issuer == "" {
+		cfg.Authentication.Issuer = cfg.OAuth.Issuer
+	}
+	if len(cfg.Authentication.Audiences) == 0 {
+		cfg.Authentication.Audiences = []string{cfg.OAuth.Audience}
+	}
+	cfg.Authentication.EnsureDefaults()
 	cfg.AuthService.EnsureDefaults()
 	cfg.ContactService.EnsureDefaults()
 	cfg.GalleryService.EnsureDefaults()
 	cfg.ProductService.EnsureDefaults()
 	cfg.SessionService.EnsureDefaults()
+	cfg.SessionCookie.EnsureDefaults()
 }
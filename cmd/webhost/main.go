@@ -7,11 +7,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"time"
 
+	"github.com/deb-ict/cloudbm-community/pkg/hosting"
+	"github.com/deb-ict/cloudbm-community/pkg/http/bearer"
+	"github.com/deb-ict/cloudbm-community/pkg/http/clientinfo"
+	"github.com/deb-ict/cloudbm-community/pkg/http/middleware"
 	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
 	auth_api_v1 "github.com/deb-ict/cloudbm-community/pkg/module/auth/api/v1"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/oauth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
 	auth_svc "github.com/deb-ict/cloudbm-community/pkg/module/auth/service"
 	contact_api_v1 "github.com/deb-ict/cloudbm-community/pkg/module/contact/api/v1"
 	contact_svc "github.com/deb-ict/cloudbm-community/pkg/module/contact/service"
@@ -19,71 +25,16 @@ import (
 	gallery_svc "github.com/deb-ict/cloudbm-community/pkg/module/gallery/service"
 	product_api_v1 "github.com/deb-ict/cloudbm-community/pkg/module/product/api/v1"
 	product_svc "github.com/deb-ict/cloudbm-community/pkg/module/product/service"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
 	session_api_v1 "github.com/deb-ict/cloudbm-community/pkg/module/session/api/v1"
+	session_security "github.com/deb-ict/cloudbm-community/pkg/module/session/security"
 	session_svc "github.com/deb-ict/cloudbm-community/pkg/module/session/service"
+	"github.com/deb-ict/cloudbm-community/pkg/storage"
 	"github.com/deb-ict/go-router"
 	"github.com/deb-ict/go-router/authentication"
 	"github.com/deb-ict/go-router/authorization"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type jwtValidator struct {
-}
-
-func (v *jwtValidator) GetBearerAuthenticationData(token string) (authentication.ClaimMap, error) {
-	jwtClaims := jwt.MapClaims{}
-	parsedToken, err := jwt.ParseWithClaims(token, jwtClaims, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte("your-256-bit-secret"), nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	if !parsedToken.Valid {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
-	claims := make(authentication.ClaimMap)
-	for key, value := range jwtClaims {
-		//TODO: We need to write a claims mapper
-		//	Roles and scopes must be split into string array
-		if key == "role" || key == "scope" {
-			stringArrayValue, ok := value.([]string)
-			if ok {
-				claims[key] = &authentication.Claim{
-					Name:   key,
-					Values: stringArrayValue,
-				}
-				continue
-			}
-			stringValue, ok := value.(string)
-			if ok {
-				claims[key] = &authentication.Claim{
-					Name:   key,
-					Values: strings.Split(stringValue, " "),
-				}
-				continue
-			}
-		}
-
-		stringValue, ok := value.(string)
-		if !ok {
-			// Handle non-string claim values as needed (e.g., log a warning, skip, etc.)
-			slog.WarnContext(context.Background(), "Skipping non-string claim",
-				slog.String("key", key),
-				slog.Any("value", value),
-			)
-			continue
-		}
-		claims.SetClaimSingleValue(key, stringValue)
-	}
-
-	return claims, nil
-}
-
 func main() {
 	// Parse arguments
 	var configPath string
@@ -97,65 +48,110 @@ func main() {
 	slog.SetDefault(slog.New(slogJsonHandler))
 	slog.SetLogLoggerLevel(slog.LevelInfo)
 
-	claims := jwt.MapClaims{}
-	claims["sub"] = "1234567890"
-	claims["iss"] = "https://localhost:8000"
-	claims["aud"] = "cloudbm-users"
-	claims["name"] = "John Doe"
-	claims["iat"] = jwt.NewNumericDate(time.Now().UTC())
-	claims["nbf"] = jwt.NewNumericDate(time.Now().UTC())
-	claims["exp"] = jwt.NewNumericDate(time.Now().UTC().Add(1 * time.Hour))
-	claims["jti"] = "unique-token-id"
-	claims["role"] = "user admin"
-	claims["scope"] = "product:read product:write"
-	claims["email"] = "john.doe@deb-ict.com"
-	claims["email_verified"] = true
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("your-256-bit-secret"))
+	// Load configuration
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Load the token signing keys
+	keyManager, err := security.NewKeyManager(&config.AuthService.SigningKeys)
 	if err != nil {
-		slog.ErrorContext(context.Background(), "Failed to generate JWT token",
+		os.Exit(1)
+	}
+	config.AuthService.KeyManager = keyManager
+
+	// Hash the verification codes with a random key when none is configured
+	err = config.AuthService.Verification.EnsureCodeHashKey()
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to generate verification code hash key",
 			slog.Any("error", err),
 		)
+		os.Exit(1)
 	}
-	slog.InfoContext(context.Background(), "Generated JWT token",
-		slog.String("token", tokenString),
-	)
 
-	// Load configuration
-	config, err := LoadConfig(configPath)
+	// Load the session encryption keys, session data is stored in plaintext without keys
+	if len(config.SessionService.Encryption.Keys) > 0 {
+		keyring, err := session_security.NewKeyring(&config.SessionService.Encryption)
+		if err != nil {
+			slog.ErrorContext(context.Background(), "Failed to load session encryption keys",
+				slog.Any("error", err),
+			)
+			os.Exit(1)
+		}
+		config.SessionService.Keyring = keyring
+		config.SessionCookie.Keyring = keyring
+	}
+
+	// Sign the urls of private gallery images with a random secret when none is configured
+	err = config.GalleryService.EnsureUrlSigningSecret()
 	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to generate gallery url signing secret",
+			slog.Any("error", err),
+		)
 		os.Exit(1)
 	}
 
+	// Store the gallery images in a bucket, the files are stored on disk without a bucket
+	if config.GalleryService.S3.Bucket != "" {
+		blobStore, err := storage.NewS3BlobStore(&config.GalleryService.S3)
+		if err != nil {
+			slog.ErrorContext(context.Background(), "Failed to create gallery blob store",
+				slog.Any("error", err),
+			)
+			os.Exit(1)
+		}
+		config.GalleryService.BlobStore = blobStore
+	}
+
 	// Handle SIGINT (CTRL+C) gracefully.
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
 	// Initialize the middlewares
-	authenticationValidator := &jwtValidator{}
-	authenticationHandler := authentication.NewBearerAuthenticationHandler(authenticationValidator)
-	authenticationMiddleware := authentication.NewMiddleware(authenticationHandler)
 	authorizationMiddleware := authorization.NewMiddleware()
 
+	// Setup the background jobs
+	scheduler := hosting.NewScheduler()
+
 	// Setup the HTTP server and routes
 	router := router.NewRouter()
-	registerAuthService(router, authorizationMiddleware, &config.AuthService)
+	authSvc := registerAuthService(router, authorizationMiddleware, scheduler, &config.AuthService, &config.OAuth)
 	registerGalleryService(router, authorizationMiddleware, &config.GalleryService)
 	registerContactService(router, authorizationMiddleware, &config.ContactService)
 	registerProductService(router, authorizationMiddleware, &config.ProductService)
-	registerSessionService(router, authorizationMiddleware, &config.SessionService)
+	sessionSvc := registerSessionService(router, authorizationMiddleware, scheduler, &config.SessionService)
 
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Welcome to CloudBM!"))
 	})
 
-	// Setup the authentication middleware
+	// Setup the client info middleware, the audit log records the address and user agent of the client
+	router.Use(clientinfo.NewMiddleware(&config.ClientInfo).Middleware)
+
+	// Setup the authentication middleware, api keys are accepted as alternative to bearer tokens
+	authenticationValidator := bearer.NewValidator(security.KeyFunc(keyManager), &config.Authentication, nil)
+	authenticationHandler := middleware.NewCompositeAuthenticationHandler(
+		authentication.NewBearerAuthenticationHandler(authenticationValidator),
+		middleware.NewApiKeyHeaderAuthenticationHandler(oauth.NewApiKeyValidator(authSvc), authentication.DefaultApiKeyHeaderName),
+	)
+	authenticationMiddleware := authentication.NewMiddleware(authenticationHandler)
 	router.Use(authenticationMiddleware.Middleware)
 
 	// Setup the authorization middleware
 	router.Use(authorizationMiddleware.Middleware)
 
+	// Setup the cookie session middleware
+	sessionMiddleware, err := middleware.NewSessionMiddleware(sessionSvc, &config.SessionCookie)
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to create session middleware",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+	router.Use(sessionMiddleware.Middleware)
+
 	// Setup the middlewares
 	//router.Use(logging.NewMiddleware().Middleware)
 	//router.Use(authentication.NewMiddleware(nil).Middleware)
@@ -183,6 +179,15 @@ func main() {
 	httpHandler := logging.NewMiddleware().Middleware(router)
 	httpHandler = customerHeaderMiddleware(httpHandler)
 
+	// Start the background jobs
+	err = scheduler.Start(ctx)
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to start scheduler",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+
 	// Start the HTTP server
 	httpServerAddr := config.Http.GetBindAddress()
 	slog.InfoContext(context.Background(), "Starting http server",
@@ -217,15 +222,33 @@ func main() {
 	slog.InfoContext(context.Background(), "Stopping http server")
 	httpServer.Shutdown(ctx)
 
+	// Stop the background jobs
+	slog.InfoContext(context.Background(), "Stopping scheduler")
+	scheduler.Stop()
+
 	// Shutdown
 	os.Exit(0)
 }
 
-func registerAuthService(router *router.Router, authorization *authorization.Middleware, opts *auth_svc.ServiceOptions) {
+func registerAuthService(router *router.Router, authorization *authorization.Middleware, jobs hosting.JobRegistry, opts *auth_svc.ServiceOptions, oauthOpts *oauth.TokenHandlerOptions) auth.Service {
 	authSvc := auth_svc.NewService(nil, opts)
-	authApiV1 := auth_api_v1.NewApiV1(authSvc)
+	authApiV1 := auth_api_v1.NewApiV1(authSvc, oauthOpts.MfaRequiredScopes)
 	authApiV1.RegisterAuthorizationPolicies(authorization)
 	authApiV1.RegisterRoutes(router.PathPrefix("/api/auth").SubRouter())
+
+	tokenHandler := oauth.NewTokenHandler(authSvc, oauthOpts)
+	tokenHandler.RegisterAuthorizationPolicies(authorization)
+	tokenHandler.RegisterRoutes(router)
+
+	err := auth_svc.RegisterJobs(jobs, authSvc.KeyManager())
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to register auth jobs",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+
+	return authSvc
 }
 
 func registerGalleryService(router *router.Router, authorization *authorization.Middleware, opts *gallery_svc.ServiceOptions) {
@@ -249,9 +272,19 @@ func registerProductService(router *router.Router, authorization *authorization.
 	productApiV1.RegisterRoutes(router.PathPrefix("/api/product").SubRouter())
 }
 
-func registerSessionService(router *router.Router, authorization *authorization.Middleware, opts *session_svc.ServiceOptions) {
+func registerSessionService(router *router.Router, authorization *authorization.Middleware, jobs hosting.JobRegistry, opts *session_svc.ServiceOptions) session.Service {
 	sessionSvc := session_svc.NewService(nil, opts)
 	sessionApiV1 := session_api_v1.NewApiV1(sessionSvc)
 	sessionApiV1.RegisterAuthorizationPolicies(authorization)
 	sessionApiV1.RegisterRoutes(router.PathPrefix("/api/session").SubRouter())
+
+	err := session_svc.RegisterJobs(jobs, sessionSvc, opts)
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to register session jobs",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+
+	return sessionSvc
 }
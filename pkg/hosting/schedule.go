@@ -0,0 +1,180 @@
+package hosting
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrScheduleInvalid error = errors.New("schedule invalid")
+)
+
+// Schedule returns the next time a job runs after the given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// cronSchedule holds the allowed values of each field as bit sets.
+type cronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// When both day fields are restricted, a day matches when either field matches
+	anyDay bool
+}
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are sunday
+}
+
+func Every(interval time.Duration) Schedule {
+	return &intervalSchedule{
+		interval: interval,
+	}
+}
+
+// ParseSchedule parses either "@every <duration>" or a 5-field cron expression.
+func ParseSchedule(value string) (Schedule, error) {
+	value = strings.TrimSpace(value)
+	if duration, ok := strings.CutPrefix(value, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("%w: invalid interval %q", ErrScheduleInvalid, duration)
+		}
+		return Every(interval), nil
+	}
+	return ParseCron(value)
+}
+
+// ParseCron parses a cron expression with the fields minute, hour, day of month, month and day of week.
+// Fields support *, lists (1,2), ranges (1-5) and steps (*/15, 1-10/2). Times are evaluated in UTC.
+func ParseCron(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w: expected %d fields, got %d", ErrScheduleInvalid, len(cronFields), len(fields))
+	}
+
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		bits, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = bits
+	}
+
+	// Sunday can be written as 0 or 7
+	if values[4]&(1<<7) != 0 {
+		values[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:     values[0],
+		hour:       values[1],
+		dayOfMonth: values[2],
+		month:      values[3],
+		dayOfWeek:  values[4],
+		anyDay:     fields[2] != "*" && fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			value, err := strconv.Atoi(stepPart)
+			if err != nil || value <= 0 {
+				return 0, fmt.Errorf("%w: invalid step %q", ErrScheduleInvalid, part)
+			}
+			step = value
+		}
+
+		start, end := bounds.min, bounds.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			value, err := strconv.Atoi(from)
+			if err != nil {
+				return 0, fmt.Errorf("%w: invalid value %q", ErrScheduleInvalid, part)
+			}
+			start, end = value, value
+			if isRange {
+				end, err = strconv.Atoi(to)
+				if err != nil {
+					return 0, fmt.Errorf("%w: invalid range %q", ErrScheduleInvalid, part)
+				}
+			} else if hasStep {
+				// A single value with a step runs from the value up to the maximum
+				end = bounds.max
+			}
+		}
+		if start < bounds.min || end > bounds.max || start > end {
+			return 0, fmt.Errorf("%w: value out of range %q", ErrScheduleInvalid, part)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (s *intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Every combination repeats within a few years, stop searching when the expression never matches
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
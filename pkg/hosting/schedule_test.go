@@ -0,0 +1,87 @@
+package hosting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron_Next(t *testing.T) {
+	// Wednesday 2025-01-15 10:07:30 UTC
+	after := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2025, 1, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 6 *", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Restricted day of month and day of week match either field
+		{"0 0 20 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(after))
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			_, err := ParseCron(expression)
+			assert.ErrorIs(t, err, ErrScheduleInvalid)
+		})
+	}
+}
+
+func TestParseCron_NeverMatches(t *testing.T) {
+	schedule, err := ParseCron("0 0 31 2 *")
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero(), "February 31 should never match")
+}
+
+func TestParseSchedule(t *testing.T) {
+	after := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+
+	schedule, err := ParseSchedule("@every 90s")
+	assert.NoError(t, err)
+	assert.Equal(t, after.Add(90*time.Second), schedule.Next(after))
+
+	schedule, err = ParseSchedule("*/5 * * * *")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 10, 10, 0, 0, time.UTC), schedule.Next(after))
+
+	_, err = ParseSchedule("@every soon")
+	assert.ErrorIs(t, err, ErrScheduleInvalid)
+
+	_, err = ParseSchedule("@every -1m")
+	assert.ErrorIs(t, err, ErrScheduleInvalid)
+}
@@ -0,0 +1,162 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+)
+
+var (
+	ErrJobInvalid          error = errors.New("job invalid")
+	ErrJobDuplicate        error = errors.New("job already registered")
+	ErrSchedulerStarted    error = errors.New("scheduler already started")
+	ErrSchedulerNotStarted error = errors.New("scheduler not started")
+)
+
+type JobFunc func(ctx context.Context) error
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// A random delay up to jitter is added to every run, so instances sharing a database don't run at the same time
+	Jitter time.Duration
+	Run    JobFunc
+}
+
+// JobRegistry is implemented by the scheduler, modules use it to register their periodic jobs.
+type JobRegistry interface {
+	RegisterJob(job *Job) error
+}
+
+// Scheduler runs registered jobs in the background until the context is cancelled or Stop is called.
+// A job never overlaps with itself, the next run is planned after the previous run has finished.
+type Scheduler struct {
+	mutex  sync.Mutex
+	jobs   []*Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: make([]*Job, 0),
+	}
+}
+
+func (s *Scheduler) RegisterJob(job *Job) error {
+	if job == nil || job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return ErrJobInvalid
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel != nil {
+		return ErrSchedulerStarted
+	}
+	for _, registered := range s.jobs {
+		if registered.Name == job.Name {
+			return fmt.Errorf("%w: %s", ErrJobDuplicate, job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel != nil {
+		return ErrSchedulerStarted
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job *Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+
+	logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Started scheduler",
+		slog.Int("jobs", len(s.jobs)),
+	)
+	return nil
+}
+
+// Stop cancels running jobs and waits until they returned.
+func (s *Scheduler) Stop() error {
+	s.mutex.Lock()
+	cancel := s.cancel
+	s.mutex.Unlock()
+
+	if cancel == nil {
+		return ErrSchedulerNotStarted
+	}
+	cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	for {
+		now := time.Now()
+		next := job.Schedule.Next(now)
+		if next.IsZero() {
+			logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Job has no next run, stopping",
+				slog.String("job", job.Name),
+			)
+			return
+		}
+		delay := next.Sub(now)
+		if job.Jitter > 0 {
+			delay += rand.N(job.Jitter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, job)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job) {
+	logger := logging.GetLoggerFromContext(ctx)
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.Run(ctx)
+	}()
+
+	duration := time.Since(start)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to run job",
+			slog.String("job", job.Name),
+			slog.Duration("duration", duration),
+			slog.Any("error", err),
+		)
+		return
+	}
+	logger.InfoContext(ctx, "Job completed",
+		slog.String("job", job.Name),
+		slog.Duration("duration", duration),
+	)
+}
@@ -0,0 +1,123 @@
+package hosting
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_RegisterJob(t *testing.T) {
+	scheduler := NewScheduler()
+	run := func(ctx context.Context) error { return nil }
+
+	err := scheduler.RegisterJob(&Job{Name: "test", Schedule: Every(time.Minute), Run: run})
+	assert.NoError(t, err)
+
+	err = scheduler.RegisterJob(&Job{Name: "test", Schedule: Every(time.Minute), Run: run})
+	assert.ErrorIs(t, err, ErrJobDuplicate)
+
+	err = scheduler.RegisterJob(&Job{Name: "no-schedule", Run: run})
+	assert.ErrorIs(t, err, ErrJobInvalid)
+
+	err = scheduler.Start(context.Background())
+	assert.NoError(t, err)
+	defer scheduler.Stop()
+
+	err = scheduler.RegisterJob(&Job{Name: "late", Schedule: Every(time.Minute), Run: run})
+	assert.ErrorIs(t, err, ErrSchedulerStarted)
+	assert.ErrorIs(t, scheduler.Start(context.Background()), ErrSchedulerStarted)
+}
+
+func TestScheduler_RunsJobs(t *testing.T) {
+	scheduler := NewScheduler()
+
+	var runs atomic.Int32
+	var failures atomic.Int32
+	scheduler.RegisterJob(&Job{
+		Name:     "count",
+		Schedule: Every(5 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+	scheduler.RegisterJob(&Job{
+		Name:     "fail",
+		Schedule: Every(5 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if failures.Add(1) == 1 {
+				panic("first run")
+			}
+			return errors.New("failed")
+		},
+	})
+
+	assert.NoError(t, scheduler.Start(context.Background()))
+	assert.Eventually(t, func() bool {
+		return runs.Load() >= 3 && failures.Load() >= 3
+	}, time.Second, 5*time.Millisecond, "Jobs should keep running after errors and panics")
+	assert.NoError(t, scheduler.Stop())
+
+	count := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, count, runs.Load(), "Jobs should not run after stop")
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	scheduler := NewScheduler()
+
+	var running atomic.Int32
+	var overlapped atomic.Bool
+	var runs atomic.Int32
+	scheduler.RegisterJob(&Job{
+		Name:     "slow",
+		Schedule: Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if running.Add(1) > 1 {
+				overlapped.Store(true)
+			}
+			defer running.Add(-1)
+			time.Sleep(10 * time.Millisecond)
+			runs.Add(1)
+			return nil
+		},
+	})
+
+	assert.NoError(t, scheduler.Start(context.Background()))
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, scheduler.Stop())
+	assert.False(t, overlapped.Load(), "Runs of a job should not overlap")
+}
+
+func TestScheduler_StopCancelsRunningJob(t *testing.T) {
+	scheduler := NewScheduler()
+
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	scheduler.RegisterJob(&Job{
+		Name:     "wait",
+		Schedule: Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			cancelled.Store(true)
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, scheduler.Start(ctx))
+	<-started
+	cancel()
+	assert.NoError(t, scheduler.Stop())
+	assert.True(t, cancelled.Load(), "The job context should be cancelled")
+}
+
+func TestScheduler_StopWithoutStart(t *testing.T) {
+	scheduler := NewScheduler()
+	assert.ErrorIs(t, scheduler.Stop(), ErrSchedulerNotStarted)
+}
@@ -0,0 +1,154 @@
+package bearer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/deb-ict/go-router/authentication"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type ClaimsMapper interface {
+	MapClaims(claims jwt.MapClaims) (authentication.ClaimMap, error)
+}
+
+type ClaimsMapperConfig struct {
+	// Claims holding a space delimited list of values, like the OAuth scope claim
+	DelimitedClaims []string `yaml:"delimited_claims"`
+	// Claims which are copied to another claim name, like sub to the router subject id
+	Aliases map[string]string `yaml:"aliases"`
+	// Claims which are not mapped at all
+	Ignore []string `yaml:"ignore"`
+	// Separator used to flatten the names of nested claims
+	NestedSeparator string `yaml:"nested_separator"`
+}
+
+type claimsMapper struct {
+	delimitedClaims []string
+	aliases         map[string]string
+	ignore          []string
+	nestedSeparator string
+}
+
+func NewClaimsMapper(config *ClaimsMapperConfig) ClaimsMapper {
+	if config == nil {
+		config = &ClaimsMapperConfig{}
+	}
+	config.EnsureDefaults()
+
+	m := &claimsMapper{
+		delimitedClaims: slices.Clone(config.DelimitedClaims),
+		aliases:         make(map[string]string),
+		ignore:          slices.Clone(config.Ignore),
+		nestedSeparator: config.NestedSeparator,
+	}
+	for key, value := range config.Aliases {
+		m.aliases[key] = value
+	}
+	return m
+}
+
+func (m *claimsMapper) MapClaims(claims jwt.MapClaims) (authentication.ClaimMap, error) {
+	result := make(authentication.ClaimMap)
+	for key, value := range claims {
+		if slices.Contains(m.ignore, key) {
+			continue
+		}
+		m.mapClaim(result, key, value)
+	}
+
+	for source, target := range m.aliases {
+		claim, ok := result[source]
+		if !ok {
+			continue
+		}
+		result[target] = &authentication.Claim{
+			Name:   target,
+			Values: slices.Clone(claim.Values),
+		}
+	}
+
+	return result, nil
+}
+
+func (m *claimsMapper) mapClaim(result authentication.ClaimMap, key string, value any) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case map[string]any:
+		for nestedKey, nestedValue := range v {
+			m.mapClaim(result, key+m.nestedSeparator+nestedKey, nestedValue)
+		}
+		return
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if stringValue, ok := claimValueToString(item); ok {
+				values = append(values, stringValue)
+			}
+		}
+		result[key] = &authentication.Claim{Name: key, Values: values}
+		return
+	case []string:
+		result[key] = &authentication.Claim{Name: key, Values: slices.Clone(v)}
+		return
+	case string:
+		if slices.Contains(m.delimitedClaims, key) {
+			result[key] = &authentication.Claim{Name: key, Values: strings.Fields(v)}
+			return
+		}
+	}
+
+	stringValue, ok := claimValueToString(value)
+	if !ok {
+		slog.WarnContext(context.Background(), "Skipping unsupported claim value",
+			slog.String("key", key),
+			slog.String("type", fmt.Sprintf("%T", value)),
+		)
+		return
+	}
+	result.SetClaimSingleValue(key, stringValue)
+}
+
+func claimValueToString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10), true
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case *jwt.NumericDate:
+		return strconv.FormatInt(v.Unix(), 10), true
+	default:
+		return "", false
+	}
+}
+
+func (cfg *ClaimsMapperConfig) EnsureDefaults() {
+	if cfg.DelimitedClaims == nil {
+		cfg.DelimitedClaims = []string{authentication.ClaimRole, authentication.ClaimScope}
+	}
+	if cfg.Aliases == nil {
+		cfg.Aliases = map[string]string{
+			"sub": authentication.ClaimSubjectId,
+		}
+	}
+	if cfg.NestedSeparator == "" {
+		cfg.NestedSeparator = "."
+	}
+}
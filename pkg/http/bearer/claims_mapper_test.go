@@ -0,0 +1,71 @@
+package bearer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/deb-ict/go-router/authentication"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimsMapper_MapClaims(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		key      string
+		expected []string
+	}{
+		{"String", jwt.MapClaims{"name": "john"}, "name", []string{"john"}},
+		{"Bool", jwt.MapClaims{"email_verified": true}, "email_verified", []string{"true"}},
+		{"JsonNumber", jwt.MapClaims{"exp": json.Number("1700000000")}, "exp", []string{"1700000000"}},
+		{"IntegralFloat", jwt.MapClaims{"exp": float64(1700000000)}, "exp", []string{"1700000000"}},
+		{"Float", jwt.MapClaims{"score": 0.5}, "score", []string{"0.5"}},
+		{"NumericDate", jwt.MapClaims{"iat": jwt.NewNumericDate(time.Unix(1700000000, 0))}, "iat", []string{"1700000000"}},
+		{"Array", jwt.MapClaims{"groups": []any{"a", "b", true}}, "groups", []string{"a", "b", "true"}},
+		{"StringArray", jwt.MapClaims{"aud": []string{"x", "y"}}, "aud", []string{"x", "y"}},
+		{"DelimitedScope", jwt.MapClaims{"scope": "openid user.read"}, "scope", []string{"openid", "user.read"}},
+		{"DelimitedRoleArray", jwt.MapClaims{"role": []any{"admin", "user"}}, "role", []string{"admin", "user"}},
+		{"NotDelimited", jwt.MapClaims{"name": "john doe"}, "name", []string{"john doe"}},
+		{"Nested", jwt.MapClaims{"address": map[string]any{"country": "BE"}}, "address.country", []string{"BE"}},
+		{"SubjectAlias", jwt.MapClaims{"sub": "1234"}, authentication.ClaimSubjectId, []string{"1234"}},
+	}
+
+	mapper := NewClaimsMapper(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := mapper.MapClaims(tt.claims)
+			assert.NoError(t, err)
+			claim, ok := claims[tt.key]
+			if assert.True(t, ok, "Claim %s should be mapped", tt.key) {
+				assert.Equal(t, tt.expected, claim.Values)
+			}
+		})
+	}
+}
+
+func TestClaimsMapper_Config(t *testing.T) {
+	mapper := NewClaimsMapper(&ClaimsMapperConfig{
+		DelimitedClaims: []string{"groups"},
+		Aliases:         map[string]string{"oid": "sid"},
+		Ignore:          []string{"secret"},
+		NestedSeparator: "_",
+	})
+
+	claims, err := mapper.MapClaims(jwt.MapClaims{
+		"groups":  "a b",
+		"scope":   "openid user.read",
+		"oid":     "1234",
+		"secret":  "value",
+		"address": map[string]any{"country": "BE"},
+		"unknown": struct{}{},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, claims["groups"].Values)
+	assert.Equal(t, []string{"openid user.read"}, claims["scope"].Values)
+	assert.Equal(t, []string{"1234"}, claims["sid"].Values)
+	assert.Equal(t, []string{"BE"}, claims["address_country"].Values)
+	assert.NotContains(t, claims, "secret")
+	assert.NotContains(t, claims, "unknown")
+}
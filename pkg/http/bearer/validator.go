@@ -0,0 +1,131 @@
+package bearer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deb-ict/go-router/authentication"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DEFAULT_LEEWAY_SECONDS int = 30
+)
+
+var DefaultAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// Access tokens are typed at+jwt (RFC 9068), so an id token signed with the same key isn't accepted
+var DefaultTokenTypes = []string{"at+jwt"}
+
+var ErrTokenTypeInvalid error = errors.New("token type invalid")
+
+type ValidatorConfig struct {
+	Issuer     string   `yaml:"issuer"`
+	Audiences  []string `yaml:"audiences"`
+	Algorithms []string `yaml:"algorithms"`
+	// Accepted values of the typ header, the application/ prefix is optional
+	TokenTypes    []string           `yaml:"token_types"`
+	LeewaySeconds int                `yaml:"leeway_seconds"`
+	Claims        ClaimsMapperConfig `yaml:"claims"`
+}
+
+// Validator validates bearer JWT tokens and maps their claims for the router authentication middleware.
+type Validator struct {
+	keyFunc    jwt.Keyfunc
+	mapper     ClaimsMapper
+	parser     *jwt.Parser
+	tokenTypes []string
+}
+
+func NewValidator(keyFunc jwt.Keyfunc, config *ValidatorConfig, mapper ClaimsMapper) *Validator {
+	if config == nil {
+		config = &ValidatorConfig{}
+	}
+	config.EnsureDefaults()
+	if mapper == nil {
+		mapper = NewClaimsMapper(&config.Claims)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(config.Algorithms),
+		jwt.WithLeeway(time.Duration(config.LeewaySeconds) * time.Second),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithJSONNumber(),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Audiences) > 0 {
+		opts = append(opts, jwt.WithAudience(config.Audiences...))
+	}
+
+	return &Validator{
+		keyFunc:    keyFunc,
+		mapper:     mapper,
+		parser:     jwt.NewParser(opts...),
+		tokenTypes: slices.Clone(config.TokenTypes),
+	}
+}
+
+func (v *Validator) GetBearerAuthenticationData(token string) (authentication.ClaimMap, error) {
+	jwtClaims := jwt.MapClaims{}
+	parsedToken, err := v.parser.ParseWithClaims(token, jwtClaims, v.keyFunc)
+	if err != nil {
+		slog.DebugContext(context.Background(), "Bearer token rejected",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if !parsedToken.Valid {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	if !v.isAcceptedType(parsedToken.Header["typ"]) {
+		slog.DebugContext(context.Background(), "Bearer token rejected",
+			slog.Any("typ", parsedToken.Header["typ"]),
+			slog.Any("error", ErrTokenTypeInvalid),
+		)
+		return nil, ErrTokenTypeInvalid
+	}
+
+	return v.mapper.MapClaims(jwtClaims)
+}
+
+func (v *Validator) isAcceptedType(value any) bool {
+	tokenType, _ := value.(string)
+	tokenType = strings.TrimPrefix(strings.ToLower(tokenType), "application/")
+	return slices.ContainsFunc(v.tokenTypes, func(accepted string) bool {
+		return strings.EqualFold(accepted, tokenType)
+	})
+}
+
+func (cfg *ValidatorConfig) LoadEnvironment() {
+	issuer, ok := os.LookupEnv("AUTHENTICATION_ISSUER")
+	if ok {
+		slog.InfoContext(context.Background(), "Override authentication issuer from environment")
+		cfg.Issuer = issuer
+	}
+	audiences, ok := os.LookupEnv("AUTHENTICATION_AUDIENCES")
+	if ok {
+		slog.InfoContext(context.Background(), "Override authentication audiences from environment")
+		cfg.Audiences = strings.Split(audiences, ",")
+	}
+}
+
+func (cfg *ValidatorConfig) EnsureDefaults() {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = DefaultAlgorithms
+	}
+	if len(cfg.TokenTypes) == 0 {
+		cfg.TokenTypes = DefaultTokenTypes
+	}
+	if cfg.LeewaySeconds <= 0 {
+		cfg.LeewaySeconds = DEFAULT_LEEWAY_SECONDS
+	}
+	cfg.Claims.EnsureDefaults()
+}
@@ -0,0 +1,96 @@
+package bearer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_GetBearerAuthenticationData(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		return &privateKey.PublicKey, nil
+	}
+
+	now := time.Now()
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            "https://issuer",
+			"aud":            "cloudbm",
+			"sub":            "1234",
+			"exp":            now.Add(time.Hour).Unix(),
+			"nbf":            now.Unix(),
+			"iat":            now.Unix(),
+			"email_verified": true,
+			"scope":          "openid user.read",
+		}
+	}
+
+	tests := []struct {
+		name      string
+		tokenType any
+		modify    func(claims jwt.MapClaims)
+		valid     bool
+	}{
+		{"Valid", "at+jwt", func(claims jwt.MapClaims) {}, true},
+		{"MediaType", "application/at+JWT", func(claims jwt.MapClaims) {}, true},
+		{"IdToken", "JWT", func(claims jwt.MapClaims) {}, false},
+		{"MissingType", nil, func(claims jwt.MapClaims) {}, false},
+		{"AudienceArray", "at+jwt", func(claims jwt.MapClaims) { claims["aud"] = []string{"other", "cloudbm"} }, true},
+		{"InvalidIssuer", "at+jwt", func(claims jwt.MapClaims) { claims["iss"] = "https://other" }, false},
+		{"InvalidAudience", "at+jwt", func(claims jwt.MapClaims) { claims["aud"] = "other" }, false},
+		{"MissingExpiration", "at+jwt", func(claims jwt.MapClaims) { delete(claims, "exp") }, false},
+		{"Expired", "at+jwt", func(claims jwt.MapClaims) { claims["exp"] = now.Add(-time.Hour).Unix() }, false},
+		{"ExpiredWithinLeeway", "at+jwt", func(claims jwt.MapClaims) { claims["exp"] = now.Add(-10 * time.Second).Unix() }, true},
+		{"NotYetValid", "at+jwt", func(claims jwt.MapClaims) { claims["nbf"] = now.Add(time.Hour).Unix() }, false},
+	}
+
+	validator := NewValidator(keyFunc, &ValidatorConfig{
+		Issuer:    "https://issuer",
+		Audiences: []string{"cloudbm"},
+	}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+			if tt.tokenType == nil {
+				delete(token.Header, "typ")
+			} else {
+				token.Header["typ"] = tt.tokenType
+			}
+			tokenString, err := token.SignedString(privateKey)
+			assert.NoError(t, err)
+
+			result, err := validator.GetBearerAuthenticationData(tokenString)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"1234"}, result["sid"].Values)
+			assert.Equal(t, []string{"true"}, result["email_verified"].Values)
+			assert.Equal(t, []string{"openid", "user.read"}, result["scope"].Values)
+		})
+	}
+}
+
+func TestValidator_RejectsUnexpectedAlgorithm(t *testing.T) {
+	validator := NewValidator(func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}, nil, nil)
+
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	_, err = validator.GetBearerAuthenticationData(tokenString)
+	assert.Error(t, err)
+}
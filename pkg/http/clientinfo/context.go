@@ -0,0 +1,34 @@
+package clientinfo
+
+import (
+	"context"
+)
+
+type ContextKey string
+
+const (
+	ClientInfoContextKey ContextKey = "cbm.clientinfo"
+)
+
+type ClientInfo struct {
+	IpAddress string
+	UserAgent string
+}
+
+func WithClientInfoInContext(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, ClientInfoContextKey, info)
+}
+
+// GetClientInfoFromContext returns the client of the request, or an empty client info
+// when the context is not created by a request.
+func GetClientInfoFromContext(ctx context.Context) *ClientInfo {
+	value := ctx.Value(ClientInfoContextKey)
+	if value == nil {
+		return &ClientInfo{}
+	}
+	info, ok := value.(*ClientInfo)
+	if !ok {
+		return &ClientInfo{}
+	}
+	return info
+}
@@ -0,0 +1,102 @@
+package clientinfo
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type MiddlewareOptions struct {
+	// Proxies (addresses or CIDR ranges) which are trusted to set the X-Forwarded-For header
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type ClientInfoMiddleware struct {
+	trustedProxies []netip.Prefix
+}
+
+func NewMiddleware(opts *MiddlewareOptions) *ClientInfoMiddleware {
+	if opts == nil {
+		opts = &MiddlewareOptions{}
+	}
+
+	m := &ClientInfoMiddleware{
+		trustedProxies: make([]netip.Prefix, 0),
+	}
+	for _, proxy := range opts.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			slog.WarnContext(context.Background(), "Ignoring invalid trusted proxy",
+				slog.String("proxy", proxy),
+				slog.Any("error", err),
+			)
+			continue
+		}
+		m.trustedProxies = append(m.trustedProxies, prefix)
+	}
+	return m
+}
+
+func (m *ClientInfoMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &ClientInfo{
+			IpAddress: m.clientIp(r),
+			UserAgent: r.UserAgent(),
+		}
+		ctx := WithClientInfoInContext(r.Context(), info)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIp returns the remote address of the request. When the request comes from a trusted proxy,
+// the forwarded addresses are walked from right to left up to the first address which isn't trusted,
+// as the addresses further left can be set by the client.
+func (m *ClientInfoMiddleware) clientIp(r *http.Request) string {
+	remoteIp := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteIp = host
+	}
+	if !m.isTrusted(remoteIp) {
+		return remoteIp
+	}
+
+	forwarded := make([]string, 0)
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(address))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if !m.isTrusted(forwarded[i]) {
+			return forwarded[i]
+		}
+	}
+	return remoteIp
+}
+
+func (m *ClientInfoMiddleware) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range m.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
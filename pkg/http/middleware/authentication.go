@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/deb-ict/go-router/authentication"
+)
+
+type compositeAuthenticationHandler struct {
+	handlers []authentication.Handler
+}
+
+// NewCompositeAuthenticationHandler tries the handlers in order, the first handler
+// which authenticates the request wins.
+func NewCompositeAuthenticationHandler(handlers ...authentication.Handler) authentication.Handler {
+	h := &compositeAuthenticationHandler{
+		handlers: handlers,
+	}
+	h.EnsureDefaults()
+
+	return h
+}
+
+func (h *compositeAuthenticationHandler) HandleAuthentication(r *http.Request) authentication.Context {
+	for _, handler := range h.handlers {
+		if handler == nil {
+			continue
+		}
+		auth := handler.HandleAuthentication(r)
+		if auth != nil && auth.IsAuthenticated() {
+			return auth
+		}
+	}
+	return nil
+}
+
+func (h *compositeAuthenticationHandler) EnsureDefaults() {
+	for _, handler := range h.handlers {
+		if handler != nil {
+			handler.EnsureDefaults()
+		}
+	}
+}
+
+type apiKeyHeaderAuthenticationHandler struct {
+	validator  authentication.ApiKeyAuthenticationValidator
+	headerName string
+}
+
+// NewApiKeyHeaderAuthenticationHandler only reads the api key from the header. The handler of the router
+// also accepts the key as query parameter, which ends up in access logs, proxies and the browser history.
+func NewApiKeyHeaderAuthenticationHandler(validator authentication.ApiKeyAuthenticationValidator, headerName string) authentication.Handler {
+	h := &apiKeyHeaderAuthenticationHandler{
+		validator:  validator,
+		headerName: headerName,
+	}
+	h.EnsureDefaults()
+
+	return h
+}
+
+func (h *apiKeyHeaderAuthenticationHandler) HandleAuthentication(r *http.Request) authentication.Context {
+	if h.validator == nil {
+		return nil
+	}
+
+	apiKey := r.Header.Get(h.headerName)
+	if apiKey == "" {
+		return nil
+	}
+
+	claims, err := h.validator.GetApiKeyAuthenticationData(apiKey)
+	if err != nil || claims == nil {
+		return nil
+	}
+
+	return authentication.NewContext(true, claims)
+}
+
+func (h *apiKeyHeaderAuthenticationHandler) EnsureDefaults() {
+	if h.headerName == "" {
+		h.headerName = authentication.DefaultApiKeyHeaderName
+	}
+}
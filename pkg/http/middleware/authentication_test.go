@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deb-ict/go-router/authentication"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAuthenticationHandler struct {
+	subject string
+	calls   int
+}
+
+func (h *testAuthenticationHandler) HandleAuthentication(r *http.Request) authentication.Context {
+	h.calls++
+	if h.subject == "" {
+		return nil
+	}
+	claims := authentication.ClaimMap{}
+	claims.SetSubjectId(h.subject)
+	return authentication.NewContext(true, claims)
+}
+
+func (h *testAuthenticationHandler) EnsureDefaults() {
+}
+
+func TestCompositeAuthenticationHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    string
+		second   string
+		expected string
+		calls    int
+	}{
+		{"first handler authenticates", "user1", "user2", "user1", 0},
+		{"falls back to second handler", "", "user2", "user2", 1},
+		{"no handler authenticates", "", "", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &testAuthenticationHandler{subject: tt.first}
+			second := &testAuthenticationHandler{subject: tt.second}
+			handler := NewCompositeAuthenticationHandler(first, nil, second)
+
+			auth := handler.HandleAuthentication(httptest.NewRequest(http.MethodGet, "/", nil))
+			if tt.expected == "" {
+				assert.Nil(t, auth)
+			} else {
+				assert.Equal(t, tt.expected, auth.GetSubjectId())
+			}
+			assert.Equal(t, 1, first.calls)
+			assert.Equal(t, tt.calls, second.calls)
+		})
+	}
+}
+
+type testApiKeyValidator struct {
+	calls int
+}
+
+func (v *testApiKeyValidator) GetApiKeyAuthenticationData(apiKey string) (authentication.ClaimMap, error) {
+	v.calls++
+	if apiKey != "valid" {
+		return nil, assert.AnError
+	}
+	claims := authentication.ClaimMap{}
+	claims.SetSubjectId("client1")
+	return claims, nil
+}
+
+func TestApiKeyHeaderAuthenticationHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		target   string
+		expected string
+		calls    int
+	}{
+		{"header", "valid", "/", "client1", 1},
+		{"invalid header", "invalid", "/", "", 1},
+		{"query parameter is ignored", "", "/?api_key=valid", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &testApiKeyValidator{}
+			handler := NewApiKeyHeaderAuthenticationHandler(validator, "")
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set(authentication.DefaultApiKeyHeaderName, tt.header)
+			}
+			auth := handler.HandleAuthentication(r)
+			if tt.expected == "" {
+				assert.Nil(t, auth)
+			} else {
+				assert.Equal(t, tt.expected, auth.GetSubjectId())
+			}
+			assert.Equal(t, tt.calls, validator.calls)
+		})
+	}
+}
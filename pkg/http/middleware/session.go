@@ -0,0 +1,505 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/security"
+)
+
+type sessionContextKey string
+
+type sessionAction int
+
+const (
+	SessionStoreDatabase string = "database"
+	SessionStoreCookie   string = "cookie"
+
+	DEFAULT_SESSION_COOKIE_NAME      string = "cbm_session"
+	DEFAULT_SESSION_COOKIE_PATH      string = "/"
+	DEFAULT_SESSION_STORE            string = SessionStoreDatabase
+	DEFAULT_SESSION_LIFETIME_MINUTES int64  = 30
+
+	SessionContextKey sessionContextKey = "cbm.session"
+)
+
+const (
+	sessionActionNone sessionAction = iota
+	sessionActionRegenerate
+	sessionActionBindUser
+	sessionActionLogout
+)
+
+type SessionMiddlewareConfig struct {
+	CookieName   string `yaml:"cookie_name"`
+	CookiePath   string `yaml:"cookie_path"`
+	CookieDomain string `yaml:"cookie_domain"`
+	// Lax, Strict or None
+	SameSite string `yaml:"same_site"`
+	// Allow the cookie over plain http, only for local development
+	Insecure bool `yaml:"insecure"`
+	// The database store keeps the session id in the cookie. The cookie store keeps the whole session
+	// encrypted in the cookie, which needs no database, but a regenerated or logged out session can't
+	// be revoked before it expires.
+	Store string `yaml:"store"`
+	// Lifetime of sessions in the cookie store, the database store uses the timeout of the session service
+	LifetimeMinutes int64 `yaml:"lifetime_minutes"`
+	// Encrypts the cookie store sessions
+	Keyring security.Keyring `yaml:"-"`
+}
+
+type SessionMiddleware struct {
+	store  sessionStore
+	config SessionMiddlewareConfig
+}
+
+type sessionStore interface {
+	// load returns the session of the cookie value, or nil when the cookie doesn't hold a session
+	load(ctx context.Context, value string) (*model.Session, error)
+	// save stores the changes of the session and returns the new cookie value
+	save(ctx context.Context, httpSession *HttpSession, value string) (string, error)
+}
+
+type databaseSessionStore struct {
+	service session.Service
+}
+
+// HttpSession is the session of the current request. Changes are kept in memory and saved
+// once, before the response is written. Only the changed keys are written, so parallel requests
+// changing other keys of the same session don't overwrite each other.
+type HttpSession struct {
+	mutex   sync.Mutex
+	session *model.Session
+	dirty   bool
+	action  sessionAction
+	// The user to bind when the action is sessionActionBindUser
+	actionUserId string
+	// Changed keys, a nil value is a deleted key
+	changes map[string]*string
+	cleared bool
+}
+
+type sessionResponseWriter struct {
+	http.ResponseWriter
+	middleware  *SessionMiddleware
+	request     *http.Request
+	session     *HttpSession
+	cookieValue string
+	saved       bool
+}
+
+// NewSessionMiddleware returns ErrSessionKeyringRequired when the cookie store is configured without
+// encryption keys.
+func NewSessionMiddleware(service session.Service, config *SessionMiddlewareConfig) (*SessionMiddleware, error) {
+	if config == nil {
+		config = &SessionMiddlewareConfig{}
+	}
+	config.EnsureDefaults()
+
+	m := &SessionMiddleware{
+		store:  &databaseSessionStore{service: service},
+		config: *config,
+	}
+	if config.Store == SessionStoreCookie {
+		if config.Keyring == nil {
+			return nil, ErrSessionKeyringRequired
+		}
+		m.store = newCookieSessionStore(config)
+	}
+	return m, nil
+}
+
+func (m *SessionMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		httpSession := &HttpSession{}
+		cookieValue := ""
+		if cookie, err := r.Cookie(m.config.CookieName); err == nil && cookie.Value != "" {
+			cookieValue = cookie.Value
+			data, err := m.store.load(ctx, cookieValue)
+			if err != nil {
+				logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to load session",
+					slog.Any("error", err),
+				)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			httpSession.session = data
+		}
+
+		sw := &sessionResponseWriter{
+			ResponseWriter: w,
+			middleware:     m,
+			session:        httpSession,
+			cookieValue:    cookieValue,
+		}
+		sw.request = r.WithContext(WithSessionInContext(ctx, httpSession))
+		next.ServeHTTP(sw, sw.request)
+		sw.save()
+	})
+}
+
+func (m *SessionMiddleware) cookie(value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     m.config.CookiePath,
+		Domain:   m.config.CookieDomain,
+		Secure:   !m.config.Insecure,
+		HttpOnly: true,
+		SameSite: parseSameSite(m.config.SameSite),
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+func (w *sessionResponseWriter) WriteHeader(statusCode int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *sessionResponseWriter) Write(data []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(data)
+}
+
+// save stores a dirty session and sets the cookie when its value changed. This has to happen
+// before the headers are written, so it runs on the first write of the handler.
+func (w *sessionResponseWriter) save() {
+	if w.saved {
+		return
+	}
+	w.saved = true
+
+	ctx := w.request.Context()
+	w.session.mutex.Lock()
+	defer w.session.mutex.Unlock()
+
+	value, err := w.middleware.store.save(ctx, w.session, w.cookieValue)
+	if err != nil {
+		// The response is already decided by the handler, only the session changes are lost
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to save session",
+			slog.Any("error", err),
+		)
+		return
+	}
+	if value != w.cookieValue {
+		http.SetCookie(w.ResponseWriter, w.middleware.cookie(value))
+	}
+}
+
+// load returns the session of the id in the cookie, or nil for an unknown or expired session. A new
+// session is only stored when the handler changes it.
+func (s *databaseSessionStore) load(ctx context.Context, value string) (*model.Session, error) {
+	return s.service.LoadSession(ctx, value)
+}
+
+func (s *databaseSessionStore) save(ctx context.Context, httpSession *HttpSession, value string) (string, error) {
+	if httpSession.dirty {
+		data := httpSession.session
+		if data == nil {
+			data = &model.Session{Data: make(map[string]string)}
+		}
+		var saved *model.Session
+		var err error
+		if data.IsTransient() {
+			saved, err = s.service.SaveSession(ctx, data)
+		} else {
+			saved, err = s.service.UpdateSessionData(ctx, data.Id, httpSession.applyChanges)
+		}
+		if err != nil {
+			return "", err
+		}
+		httpSession.session = saved
+		httpSession.resetChanges()
+	}
+
+	if httpSession.action != sessionActionNone && httpSession.session != nil && !httpSession.session.IsTransient() {
+		data, err := s.applyAction(ctx, httpSession)
+		if err != nil {
+			// Keeping the old id is not safe after a login or logout, drop the session instead
+			logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to regenerate session",
+				slog.String("id", httpSession.session.Id),
+				slog.Any("error", err),
+			)
+			s.service.DeleteSession(ctx, httpSession.session.Id)
+			data = nil
+		}
+		httpSession.session = data
+		httpSession.action = sessionActionNone
+	}
+
+	if httpSession.session == nil {
+		return "", nil
+	}
+	return httpSession.session.Id, nil
+}
+
+func (s *databaseSessionStore) applyAction(ctx context.Context, httpSession *HttpSession) (*model.Session, error) {
+	id := httpSession.session.Id
+	switch httpSession.action {
+	case sessionActionBindUser:
+		return s.service.BindUser(ctx, id, httpSession.actionUserId)
+	case sessionActionLogout:
+		return s.service.Logout(ctx, id)
+	default:
+		return s.service.RegenerateSession(ctx, id)
+	}
+}
+
+func (w *sessionResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func WithSessionInContext(ctx context.Context, httpSession *HttpSession) context.Context {
+	return context.WithValue(ctx, SessionContextKey, httpSession)
+}
+
+// GetSessionFromContext returns the session of the request. Without the session middleware a
+// detached session is returned, so handlers don't have to check, but nothing is saved.
+func GetSessionFromContext(ctx context.Context) *HttpSession {
+	value := ctx.Value(SessionContextKey)
+	if value == nil {
+		return &HttpSession{}
+	}
+	httpSession, ok := value.(*HttpSession)
+	if !ok {
+		return &HttpSession{}
+	}
+	return httpSession
+}
+
+// Id returns the id of the session, which is empty until the session is saved for the first time.
+func (s *HttpSession) Id() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil {
+		return ""
+	}
+	return s.session.Id
+}
+
+func (s *HttpSession) UserId() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil {
+		return ""
+	}
+	return s.session.UserId
+}
+
+func (s *HttpSession) GetString(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil {
+		return "", false
+	}
+	value, ok := s.session.Data[key]
+	return value, ok
+}
+
+func (s *HttpSession) SetString(key string, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ensureSession()
+	if current, ok := s.session.Data[key]; ok && current == value {
+		return
+	}
+	s.session.Data[key] = value
+	s.setChange(key, &value)
+}
+
+func (s *HttpSession) GetInt(key string) (int64, bool) {
+	value, ok := s.GetString(key)
+	if !ok {
+		return 0, false
+	}
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return result, true
+}
+
+func (s *HttpSession) SetInt(key string, value int64) {
+	s.SetString(key, strconv.FormatInt(value, 10))
+}
+
+func (s *HttpSession) GetBool(key string) (bool, bool) {
+	value, ok := s.GetString(key)
+	if !ok {
+		return false, false
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false
+	}
+	return result, true
+}
+
+func (s *HttpSession) SetBool(key string, value bool) {
+	s.SetString(key, strconv.FormatBool(value))
+}
+
+// GetJSON decodes the value into target, it returns false when the key is missing or can't be decoded.
+func (s *HttpSession) GetJSON(key string, target any) bool {
+	value, ok := s.GetString(key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal([]byte(value), target) == nil
+}
+
+func (s *HttpSession) SetJSON(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.SetString(key, string(data))
+	return nil
+}
+
+func (s *HttpSession) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil {
+		return
+	}
+	if _, ok := s.session.Data[key]; !ok {
+		return
+	}
+	delete(s.session.Data, key)
+	s.setChange(key, nil)
+}
+
+func (s *HttpSession) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil || len(s.session.Data) == 0 {
+		return
+	}
+	s.session.Data = make(map[string]string)
+	s.changes = nil
+	s.cleared = true
+	s.dirty = true
+}
+
+// Regenerate moves the session to a new id when the response is written.
+func (s *HttpSession) Regenerate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil || s.action != sessionActionNone {
+		return
+	}
+	s.action = sessionActionRegenerate
+}
+
+// BindUser attaches the user to the session, the session gets a new id when the response is written.
+func (s *HttpSession) BindUser(userId string) {
+	if userId == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ensureSession()
+	s.session.UserId = userId
+	s.dirty = true
+	s.action = sessionActionBindUser
+	s.actionUserId = userId
+}
+
+// Logout detaches the user from the session, the session gets a new id when the response is written.
+func (s *HttpSession) Logout() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session == nil {
+		return
+	}
+	s.session.UserId = ""
+	s.action = sessionActionLogout
+}
+
+func (s *HttpSession) resetChanges() {
+	s.dirty = false
+	s.changes = nil
+	s.cleared = false
+}
+
+func (s *HttpSession) setChange(key string, value *string) {
+	if s.changes == nil {
+		s.changes = make(map[string]*string)
+	}
+	s.changes[key] = value
+	s.dirty = true
+}
+
+// applyChanges replays the changes of this request on the latest data of the session.
+func (s *HttpSession) applyChanges(data map[string]string) error {
+	if s.cleared {
+		clear(data)
+	}
+	for key, value := range s.changes {
+		if value == nil {
+			delete(data, key)
+		} else {
+			data[key] = *value
+		}
+	}
+	return nil
+}
+
+func (s *HttpSession) ensureSession() {
+	if s.session == nil {
+		s.session = &model.Session{}
+	}
+	if s.session.Data == nil {
+		s.session.Data = make(map[string]string)
+	}
+}
+
+func (config *SessionMiddlewareConfig) EnsureDefaults() {
+	if config.CookieName == "" {
+		config.CookieName = DEFAULT_SESSION_COOKIE_NAME
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DEFAULT_SESSION_COOKIE_PATH
+	}
+	if config.Store == "" {
+		config.Store = DEFAULT_SESSION_STORE
+	}
+	if config.LifetimeMinutes <= 0 {
+		config.LifetimeMinutes = DEFAULT_SESSION_LIFETIME_MINUTES
+	}
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
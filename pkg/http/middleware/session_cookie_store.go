@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/security"
+)
+
+// Browsers limit a cookie to about 4096 bytes, including its name and attributes
+const maxSessionCookieLength int = 3800
+
+var (
+	ErrSessionCookieTooLarge  error = errors.New("session too large for the cookie store")
+	ErrSessionKeyringRequired error = errors.New("cookie session store requires encryption keys")
+
+	// Binds the cookie to its purpose, so session data stored in the database can't be used as cookie
+	sessionCookieAdditionalData = []byte("cbm.session.cookie")
+)
+
+// cookieSessionStore keeps the whole session in the cookie, encrypted and authenticated with the keyring.
+type cookieSessionStore struct {
+	keyring  security.Keyring
+	lifetime time.Duration
+}
+
+type cookieSessionPayload struct {
+	Id        string            `json:"id"`
+	UserId    string            `json:"uid,omitempty"`
+	CreatedAt int64             `json:"iat"`
+	ExpiresAt int64             `json:"exp"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+func newCookieSessionStore(config *SessionMiddlewareConfig) *cookieSessionStore {
+	return &cookieSessionStore{
+		keyring:  config.Keyring,
+		lifetime: time.Duration(config.LifetimeMinutes) * time.Minute,
+	}
+}
+
+// load decrypts the session in the cookie. A tampered or expired cookie, or a cookie encrypted with a
+// key that was removed from the keyring, starts a new session.
+func (s *cookieSessionStore) load(ctx context.Context, value string) (*model.Session, error) {
+	plaintext, err := s.keyring.Decrypt(value, sessionCookieAdditionalData)
+	if err != nil {
+		return nil, nil
+	}
+	var payload cookieSessionPayload
+	err = json.Unmarshal(plaintext, &payload)
+	if err != nil {
+		return nil, nil
+	}
+
+	data := &model.Session{
+		Id:                   payload.Id,
+		UserId:               payload.UserId,
+		CreatedAt:            time.Unix(payload.CreatedAt, 0).UTC(),
+		ExpiresAt:            time.Unix(payload.ExpiresAt, 0).UTC(),
+		Lifetime:             s.lifetime,
+		UseSlidingExpiration: true,
+		Data:                 payload.Data,
+	}
+	if data.Data == nil {
+		data.Data = make(map[string]string)
+	}
+	if data.HasExpired() {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func (s *cookieSessionStore) save(ctx context.Context, httpSession *HttpSession, value string) (string, error) {
+	data := httpSession.session
+	if data == nil {
+		return "", nil
+	}
+	// Without changes the cookie is only renewed once half of the sliding lifetime has passed
+	if !httpSession.dirty && httpSession.action == sessionActionNone && time.Until(data.ExpiresAt) > s.lifetime/2 {
+		return value, nil
+	}
+
+	switch httpSession.action {
+	case sessionActionBindUser:
+		data.UserId = httpSession.actionUserId
+	case sessionActionLogout:
+		data.UserId = ""
+	}
+	if data.IsTransient() || httpSession.action != sessionActionNone {
+		id, err := newSessionId()
+		if err != nil {
+			return "", err
+		}
+		data.Id = id
+	}
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt = time.Now().UTC()
+	}
+	data.Lifetime = s.lifetime
+	data.UseSlidingExpiration = true
+	data.Touch()
+
+	plaintext, err := json.Marshal(&cookieSessionPayload{
+		Id:        data.Id,
+		UserId:    data.UserId,
+		CreatedAt: data.CreatedAt.Unix(),
+		ExpiresAt: data.ExpiresAt.Unix(),
+		Data:      data.Data,
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := s.keyring.Encrypt(plaintext, sessionCookieAdditionalData)
+	if err != nil {
+		return "", err
+	}
+	if len(result) > maxSessionCookieLength {
+		return "", ErrSessionCookieTooLarge
+	}
+
+	httpSession.resetChanges()
+	httpSession.action = sessionActionNone
+	return result, nil
+}
+
+func newSessionId() (string, error) {
+	id := make([]byte, 24)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
@@ -0,0 +1,511 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/security"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySessionDatabase struct {
+	mutex    sync.Mutex
+	nextId   int
+	sessions map[string]*model.Session
+}
+
+func newMemorySessionDatabase() *memorySessionDatabase {
+	return &memorySessionDatabase{
+		sessions: make(map[string]*model.Session),
+	}
+}
+
+func (db *memorySessionDatabase) Sessions() session.SessionRepository {
+	return db
+}
+
+func (db *memorySessionDatabase) GetSessions(ctx context.Context, offset int64, limit int64, filter *model.SessionFilter, sort *core.Sort) ([]*model.Session, int64, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	result := make([]*model.Session, 0)
+	for _, data := range db.sessions {
+		if filter != nil && filter.UserId != "" && data.UserId != filter.UserId {
+			continue
+		}
+		if filter != nil && !filter.ExpiresBefore.IsZero() && !data.ExpiresAt.Before(filter.ExpiresBefore) {
+			continue
+		}
+		result = append(result, data.Clone())
+	}
+	return result, int64(len(result)), nil
+}
+
+func (db *memorySessionDatabase) GetSessionById(ctx context.Context, id string) (*model.Session, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.sessions[id].Clone(), nil
+}
+
+func (db *memorySessionDatabase) CreateSession(ctx context.Context, data *model.Session) (string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.nextId++
+	data = data.Clone()
+	data.Id = "session-" + strconv.Itoa(db.nextId)
+	db.sessions[data.Id] = data
+	return data.Id, nil
+}
+
+func (db *memorySessionDatabase) UpdateSession(ctx context.Context, data *model.Session) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	current, ok := db.sessions[data.Id]
+	if !ok {
+		return nil
+	}
+	if current.Version != data.Version {
+		return session.ErrSessionConflict
+	}
+	data.Version++
+	db.sessions[data.Id] = data.Clone()
+	return nil
+}
+
+func (db *memorySessionDatabase) DeleteSession(ctx context.Context, data *model.Session) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	delete(db.sessions, data.Id)
+	return nil
+}
+
+func newTestSessionMiddleware(db *memorySessionDatabase) *SessionMiddleware {
+	svc := service.NewService(db, nil)
+	m, _ := NewSessionMiddleware(svc, nil)
+	return m
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == DEFAULT_SESSION_COOKIE_NAME {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestSessionMiddlewareCreatesSessionOnWrite(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		httpSession.SetInt("cart_items", 2)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookie := sessionCookie(t, w)
+	if assert.NotNil(t, cookie) {
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(t, "2", db.sessions[cookie.Value].Data["cart_items"])
+	}
+}
+
+func TestSessionMiddlewareNoSessionWithoutWrite(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := GetSessionFromContext(r.Context()).GetString("missing")
+		assert.False(t, ok)
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Nil(t, sessionCookie(t, w))
+	assert.Empty(t, db.sessions)
+}
+
+func TestSessionMiddlewareLoadsExistingSession(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	var id string
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		count, _ := httpSession.GetInt("count")
+		httpSession.SetInt("count", count+1)
+		id = httpSession.Id()
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := sessionCookie(t, w)
+	assert.NotNil(t, cookie)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// The cookie is only sent again when the session changes
+	assert.Nil(t, sessionCookie(t, w))
+	assert.Equal(t, cookie.Value, id)
+	assert.Equal(t, "2", db.sessions[cookie.Value].Data["count"])
+}
+
+func TestSessionMiddlewareReplacesUnknownSession(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetSessionFromContext(r.Context()).SetBool("seen", true)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: DEFAULT_SESSION_COOKIE_NAME, Value: "unknown"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	cookie := sessionCookie(t, w)
+	if assert.NotNil(t, cookie) {
+		assert.NotEqual(t, "unknown", cookie.Value)
+		assert.Equal(t, "true", db.sessions[cookie.Value].Data["seen"])
+	}
+}
+
+func TestSessionMiddlewareIgnoresUnknownSessionWithoutWrite(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, GetSessionFromContext(r.Context()).Id())
+		w.Write([]byte("ok"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: DEFAULT_SESSION_COOKIE_NAME, Value: "unknown"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// A made up id must not create a session, the stale cookie is removed
+	assert.Empty(t, db.sessions)
+	cookie := sessionCookie(t, w)
+	if assert.NotNil(t, cookie) {
+		assert.Empty(t, cookie.Value)
+		assert.Equal(t, -1, cookie.MaxAge)
+	}
+}
+
+func TestHttpSessionJSON(t *testing.T) {
+	type cart struct {
+		Items []string `json:"items"`
+	}
+
+	httpSession := GetSessionFromContext(context.Background())
+	err := httpSession.SetJSON("cart", &cart{Items: []string{"a", "b"}})
+	assert.NoError(t, err)
+
+	var result cart
+	assert.True(t, httpSession.GetJSON("cart", &result))
+	assert.Equal(t, []string{"a", "b"}, result.Items)
+
+	httpSession.Delete("cart")
+	assert.False(t, httpSession.GetJSON("cart", &result))
+}
+
+func TestSessionMiddlewareBindUserRegeneratesSession(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		switch r.URL.Path {
+		case "/login":
+			httpSession.BindUser("user-1")
+		case "/logout":
+			httpSession.Logout()
+		default:
+			httpSession.SetString("language", "nl")
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	anonymous := sessionCookie(t, w)
+	assert.NotNil(t, anonymous)
+
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	r.AddCookie(anonymous)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	login := sessionCookie(t, w)
+	if assert.NotNil(t, login) {
+		assert.NotEqual(t, anonymous.Value, login.Value, "The session id should change on login")
+		assert.NotContains(t, db.sessions, anonymous.Value)
+		assert.Equal(t, "user-1", db.sessions[login.Value].UserId)
+		assert.Equal(t, "nl", db.sessions[login.Value].Data["language"])
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	r.AddCookie(login)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	logout := sessionCookie(t, w)
+	if assert.NotNil(t, logout) {
+		assert.NotEqual(t, login.Value, logout.Value, "The session id should change on logout")
+		assert.NotContains(t, db.sessions, login.Value)
+		assert.Empty(t, db.sessions[logout.Value].UserId)
+		assert.Equal(t, "nl", db.sessions[logout.Value].Data["language"])
+	}
+}
+
+func TestSessionServiceLimitsSessionsPerUser(t *testing.T) {
+	db := newMemorySessionDatabase()
+	svc := service.NewService(db, &service.ServiceOptions{MaxSessionsPerUser: 2})
+	ctx := context.Background()
+
+	ids := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		data, err := svc.BindUser(ctx, "", "user-1")
+		assert.NoError(t, err)
+		ids = append(ids, data.Id)
+	}
+	other, err := svc.BindUser(ctx, "", "user-2")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, db.sessions, ids[0], "The oldest session should be removed")
+	assert.Contains(t, db.sessions, ids[1])
+	assert.Contains(t, db.sessions, ids[2])
+
+	err = svc.LogoutUser(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.NotContains(t, db.sessions, ids[1])
+	assert.NotContains(t, db.sessions, ids[2])
+	assert.Contains(t, db.sessions, other.Id, "Sessions of other users should be kept")
+}
+
+func TestSessionMiddlewareKeepsParallelChanges(t *testing.T) {
+	db := newMemorySessionDatabase()
+	m := newTestSessionMiddleware(db)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		if key := r.URL.Query().Get("key"); key != "" {
+			httpSession.SetString(key, "set")
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/?key=initial", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	cookie := sessionCookie(t, w)
+	assert.NotNil(t, cookie)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/?key=item-"+strconv.Itoa(i), nil)
+			r.AddCookie(cookie)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}(i)
+	}
+	wg.Wait()
+
+	data := db.sessions[cookie.Value].Data
+	assert.Equal(t, "set", data["initial"])
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "set", data["item-"+strconv.Itoa(i)], "Changes of parallel requests should not be lost")
+	}
+}
+
+func TestSessionServiceIncrementSessionData(t *testing.T) {
+	db := newMemorySessionDatabase()
+	svc := service.NewService(db, nil)
+	ctx := context.Background()
+
+	data, err := svc.CreateSession(ctx, &model.Session{Lifetime: time.Hour, Data: map[string]string{}})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.IncrementSessionData(ctx, data.Id, "cart_items", 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, "4", db.sessions[data.Id].Data["cart_items"])
+
+	err = svc.SetSessionData(ctx, data.Id, "name", "value")
+	assert.NoError(t, err)
+	_, err = svc.IncrementSessionData(ctx, data.Id, "name", 1)
+	assert.ErrorIs(t, err, session.ErrSessionDataInvalid)
+
+	err = svc.DeleteSessionData(ctx, data.Id, "name")
+	assert.NoError(t, err)
+	assert.NotContains(t, db.sessions[data.Id].Data, "name")
+}
+
+func TestSessionServiceUpdateSessionConflict(t *testing.T) {
+	db := newMemorySessionDatabase()
+	svc := service.NewService(db, nil)
+	ctx := context.Background()
+
+	data, err := svc.CreateSession(ctx, &model.Session{Lifetime: time.Hour, Data: map[string]string{}})
+	assert.NoError(t, err)
+
+	stale := data.Clone()
+	data.Data["first"] = "1"
+	_, err = svc.UpdateSession(ctx, data.Id, data)
+	assert.NoError(t, err)
+
+	stale.Data["second"] = "2"
+	_, err = svc.UpdateSession(ctx, stale.Id, stale)
+	assert.ErrorIs(t, err, session.ErrSessionConflict)
+	assert.Equal(t, "1", db.sessions[data.Id].Data["first"])
+}
+
+func newTestKeyring(t *testing.T) security.Keyring {
+	secret, err := security.GenerateEncryptionKey()
+	assert.NoError(t, err)
+	keyring, err := security.NewKeyring(&security.KeyringOptions{
+		Keys: []security.EncryptionKeyOptions{{Id: "k1", Secret: secret}},
+	})
+	assert.NoError(t, err)
+	return keyring
+}
+
+func TestSessionMiddlewareEncryptsDataAtRest(t *testing.T) {
+	db := newMemorySessionDatabase()
+	svc := service.NewService(db, &service.ServiceOptions{Keyring: newTestKeyring(t)})
+	m, err := NewSessionMiddleware(svc, nil)
+	assert.NoError(t, err)
+
+	var value string
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		if r.Method == http.MethodPost {
+			httpSession.SetString("card", "4111111111111111")
+		}
+		value, _ = httpSession.GetString("card")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	cookie := sessionCookie(t, w)
+	if !assert.NotNil(t, cookie) {
+		return
+	}
+
+	stored := db.sessions[cookie.Value]
+	assert.Empty(t, stored.Data)
+	assert.NotEmpty(t, stored.EncryptedData)
+	assert.NotContains(t, stored.EncryptedData, "4111111111111111")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "4111111111111111", value)
+}
+
+func TestSessionMiddlewareCookieStore(t *testing.T) {
+	m, err := NewSessionMiddleware(nil, &SessionMiddlewareConfig{
+		Store:   SessionStoreCookie,
+		Keyring: newTestKeyring(t),
+	})
+	assert.NoError(t, err)
+
+	var userId string
+	var count int64
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpSession := GetSessionFromContext(r.Context())
+		switch r.URL.Path {
+		case "/login":
+			httpSession.BindUser("user-1")
+		case "/count":
+			count, _ = httpSession.GetInt("count")
+			httpSession.SetInt("count", count+1)
+		}
+		userId = httpSession.UserId()
+		count, _ = httpSession.GetInt("count")
+	}))
+
+	request := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := sessionCookie(t, request("/count", nil))
+	if !assert.NotNil(t, first) {
+		return
+	}
+	assert.NotContains(t, first.Value, "count")
+
+	second := sessionCookie(t, request("/count", first))
+	assert.NotNil(t, second)
+	assert.Equal(t, int64(2), count)
+
+	// Without changes the cookie is not sent again
+	assert.Nil(t, sessionCookie(t, request("/", second)))
+	assert.Equal(t, int64(2), count)
+
+	login := sessionCookie(t, request("/login", second))
+	assert.NotNil(t, login)
+	request("/", login)
+	assert.Equal(t, "user-1", userId)
+	assert.Equal(t, int64(2), count)
+
+	// A tampered cookie starts a new session
+	i := len(login.Value) / 2
+	replacement := "A"
+	if login.Value[i:i+1] == replacement {
+		replacement = "B"
+	}
+	tampered := &http.Cookie{Name: DEFAULT_SESSION_COOKIE_NAME, Value: login.Value[:i] + replacement + login.Value[i+1:]}
+	w := request("/", tampered)
+	assert.Empty(t, userId)
+	assert.Equal(t, int64(0), count)
+	if cleared := sessionCookie(t, w); assert.NotNil(t, cleared) {
+		assert.Equal(t, -1, cleared.MaxAge)
+	}
+}
+
+func TestSessionMiddlewareCookieStoreRequiresKeyring(t *testing.T) {
+	m, err := NewSessionMiddleware(nil, &SessionMiddlewareConfig{
+		Store: SessionStoreCookie,
+	})
+	assert.ErrorIs(t, err, ErrSessionKeyringRequired)
+	assert.Nil(t, m)
+}
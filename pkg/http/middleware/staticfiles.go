@@ -2,20 +2,40 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
 	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/imaging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
 )
 
 const (
-	DEFAULT_STATIC_ASSET_URI string = "/static/"
-	GALLERY_BASE_URI         string = "/assets/gallery/"
+	DEFAULT_STATIC_ASSET_URI           string = "/static/"
+	DEFAULT_STATIC_ASSET_CACHE_MAX_AGE int    = 3600
+	DEFAULT_GALLERY_CACHE_MAX_AGE      int    = 86400
 )
 
+// Precompressed variants of static assets, in order of preference
+var staticAssetEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
 type StaticFileMiddlewareConfig struct {
 	StaticAsset StaticAssetConfig  `yaml:"Static"`
 	Gallery     GalleryAssetConfig `yaml:"Gallery"`
@@ -24,17 +44,23 @@ type StaticFileMiddlewareConfig struct {
 type StaticAssetConfig struct {
 	Uri    string `yaml:"UriPrefix"`
 	Folder string `yaml:"Folder"`
+	// Cache lifetime in seconds, a negative value disables caching
+	CacheMaxAge int `yaml:"CacheMaxAge"`
 }
 
+// The gallery images are served on the image base uri of the gallery service, which also builds the image urls
 type GalleryAssetConfig struct {
-	Uri string `yaml:"UriPrefix"`
+	// Cache lifetime in seconds, a negative value disables caching
+	CacheMaxAge int `yaml:"CacheMaxAge"`
 }
 
 type StaticFileMiddleware struct {
-	staticAssetBaseUri string
-	staticAssetFolder  string
-	galleryBaseUri     string
-	galleryService     gallery.Service
+	staticAssetBaseUri      string
+	staticAssetFolder       string
+	staticAssetCacheControl string
+	galleryBaseUri          string
+	galleryCacheControl     string
+	galleryService          gallery.Service
 }
 
 func NewStaticFileMiddleware(galleryService gallery.Service, config *StaticFileMiddlewareConfig) *StaticFileMiddleware {
@@ -43,46 +69,32 @@ func NewStaticFileMiddleware(galleryService gallery.Service, config *StaticFileM
 	}
 	config.StaticAsset.LoadEnvironment()
 	config.StaticAsset.EnsureDefaults()
-	config.Gallery.LoadEnvironment()
 	config.Gallery.EnsureDefaults()
 
-	return &StaticFileMiddleware{
-		staticAssetBaseUri: config.StaticAsset.Uri,
-		staticAssetFolder:  config.StaticAsset.Folder,
-		galleryBaseUri:     config.Gallery.Uri,
-		galleryService:     galleryService,
+	m := &StaticFileMiddleware{
+		staticAssetBaseUri:      config.StaticAsset.Uri,
+		staticAssetFolder:       config.StaticAsset.Folder,
+		staticAssetCacheControl: getCacheControl(config.StaticAsset.CacheMaxAge),
+		galleryCacheControl:     getCacheControl(config.Gallery.CacheMaxAge),
+		galleryService:          galleryService,
+	}
+	if galleryService != nil {
+		m.galleryBaseUri = galleryService.ImageBaseUri()
 	}
+	return m
 }
 
 func (m *StaticFileMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Uri path for css, js, ...
 		if strings.HasPrefix(r.URL.Path, m.staticAssetBaseUri) {
-			path := strings.Replace(r.URL.Path, m.staticAssetBaseUri, m.staticAssetFolder, 1)
-			http.ServeFile(w, r, path)
+			m.serveStaticAsset(w, r)
 			return
 		}
 
 		// Gallery service
-		if strings.HasPrefix(r.URL.Path, m.galleryBaseUri) {
-			// Parse the image id
-			imageId := strings.Replace(r.URL.Path, m.galleryBaseUri, "", 1)
-			if imageId == "" {
-				return
-			}
-
-			// Get the image data
-			buffer, contentType, fileName, err := m.galleryService.GetImageData(r.Context(), imageId)
-			if err != nil {
-				http.NotFound(w, r)
-				return
-			}
-			defer buffer.Close()
-
-			// Return the image
-			w.Header().Set("Content-Type", contentType)
-			w.Header().Set("Content-Disposition", "inline; filename="+fileName)
-			io.Copy(w, buffer)
+		if m.galleryService != nil && strings.HasPrefix(r.URL.Path, m.galleryBaseUri) {
+			m.serveGalleryImage(w, r)
 			return
 		}
 
@@ -91,9 +103,252 @@ func (m *StaticFileMiddleware) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// serveStaticAsset serves a precompressed .br or .gz variant of the file when the client accepts it.
+func (m *StaticFileMiddleware) serveStaticAsset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", m.staticAssetCacheControl)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	// Cleaning the rooted path drops .. elements, so the file can't be outside the folder
+	filePath := filepath.Join(m.staticAssetFolder, filepath.FromSlash(path.Clean("/"+strings.TrimPrefix(r.URL.Path, m.staticAssetBaseUri))))
+	for _, variant := range staticAssetEncodings {
+		if !acceptsEncoding(r, variant.encoding) {
+			continue
+		}
+		file, err := os.Open(filePath + variant.extension)
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil || stat.IsDir() {
+			continue
+		}
+
+		// The content type is detected from the uncompressed file name
+		contentType := mime.TypeByExtension(filepath.Ext(filePath))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", variant.encoding)
+		http.ServeContent(w, r, filePath, stat.ModTime(), file)
+		return
+	}
+
+	http.ServeFile(w, r, filePath)
+}
+
+func (m *StaticFileMiddleware) serveGalleryImage(w http.ResponseWriter, r *http.Request) {
+	// Parse the image id
+	imageId := strings.Replace(r.URL.Path, m.galleryBaseUri, "", 1)
+	if imageId == "" {
+		return
+	}
+	rendition, err := parseImageRendition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	image, err := m.galleryService.GetImageById(r.Context(), imageId)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Private images are only served with a signed url, an invalid signature doesn't reveal the image exists
+	cacheControl := m.galleryCacheControl
+	if image.IsPrivate() {
+		query := r.URL.Query()
+		err = m.galleryService.VerifyImageUrl(r.Context(), image.Id, query.Get("expires"), query.Get("signature"))
+		if err == gallery.ErrImageUrlExpired {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		cacheControl = "private, no-store"
+	}
+
+	if rendition != nil && imaging.RenditionFormat(image.MimeType, imaging.MimeTypeWebp) == imaging.MimeTypeWebp {
+		// Renditions of images which aren't jpeg are written as webp for clients that accept it
+		w.Header().Add("Vary", "Accept")
+		if acceptsMimeType(r, imaging.MimeTypeWebp) {
+			rendition.Format = imaging.MimeTypeWebp
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// The entity tag is known before the image is read, a cached copy doesn't need a rendition
+	etag := getImageETag(image, rendition)
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+		if matchesETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Get the image without personal metadata, or a resized rendition when a size is requested
+	var buffer io.ReadCloser
+	var contentType, fileName string
+	if rendition != nil {
+		buffer, contentType, fileName, err = m.galleryService.GetImageRendition(r.Context(), imageId, rendition)
+	} else {
+		buffer, contentType, fileName, err = m.galleryService.GetPublicImageData(r.Context(), imageId)
+	}
+	if err == gallery.ErrRenditionInvalid || err == gallery.ErrRenditionNotFound {
+		w.Header().Del("ETag")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		w.Header().Del("ETag")
+		http.NotFound(w, r)
+		return
+	}
+	defer buffer.Close()
+
+	// Return the image
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "inline; filename="+fileName)
+	if contentType == imaging.MimeTypeSvg {
+		// Svg files are sanitized on upload, block scripts and external resources as well
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+	}
+	err = serveContent(w, r, fileName, image.UploadedAt, buffer)
+	if err != nil {
+		logging.GetLoggerFromContext(r.Context()).ErrorContext(r.Context(), "Failed to serve gallery image",
+			slog.String("id", imageId),
+			slog.Any("error", err),
+		)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serveContent handles conditional and range requests. Content that can't seek, like a blob read from
+// a bucket, is buffered in a temporary file first.
+func serveContent(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.Reader) error {
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok {
+		tempFile, err := os.CreateTemp("", "cbm-serve-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tempFile.Name())
+		defer tempFile.Close()
+
+		_, err = io.Copy(tempFile, content)
+		if err == nil {
+			_, err = tempFile.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return err
+		}
+		seeker = tempFile
+	}
+	http.ServeContent(w, r, name, modTime, seeker)
+	return nil
+}
+
+// getImageETag returns a strong entity tag from the content hash, every rendition has its own tag.
+// Images stored before the hash was introduced have no tag.
+func getImageETag(image *model.Image, rendition *model.ImageRendition) string {
+	if image.ContentHash == "" {
+		return ""
+	}
+	if rendition == nil {
+		return fmt.Sprintf(`"%s"`, image.ContentHash)
+	}
+
+	variant := fmt.Sprintf("%dx%d-%s", rendition.Width, rendition.Height, rendition.Fit)
+	if rendition.Name != "" {
+		variant = url.QueryEscape(rendition.Name)
+	}
+	if rendition.Format == imaging.MimeTypeWebp {
+		variant += "-webp"
+	}
+	return fmt.Sprintf(`"%s-%s"`, image.ContentHash, variant)
+}
+
+// matchesETag returns true when the If-None-Match header lists the tag, weak tags match as well.
+func matchesETag(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func getCacheControl(maxAge int) string {
+	if maxAge < 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", maxAge)
+}
+
+// parseImageRendition reads ?size=name or ?w=&h=&fit=cover|contain, it returns nil for the original image.
+func parseImageRendition(r *http.Request) (*model.ImageRendition, error) {
+	query := r.URL.Query()
+	if name := query.Get("size"); name != "" {
+		return &model.ImageRendition{Name: name}, nil
+	}
+	if !query.Has("w") && !query.Has("h") {
+		return nil, nil
+	}
+
+	rendition := &model.ImageRendition{
+		Fit: query.Get("fit"),
+	}
+	for key, value := range map[string]*int{"w": &rendition.Width, "h": &rendition.Height} {
+		if !query.Has(key) {
+			continue
+		}
+		parsed, err := strconv.Atoi(query.Get(key))
+		if err != nil {
+			return nil, gallery.ErrRenditionInvalid
+		}
+		*value = parsed
+	}
+	return rendition, nil
+}
+
+// acceptsMimeType returns true when the accept header lists the mime type, wildcards are ignored.
+func acceptsMimeType(r *http.Request, mimeType string) bool {
+	return acceptsValue(r.Header.Values("Accept"), mimeType)
+}
+
+// acceptsEncoding returns true when the accept-encoding header lists the encoding, wildcards are ignored.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	return acceptsValue(r.Header.Values("Accept-Encoding"), encoding)
+}
+
+func acceptsValue(headers []string, value string) bool {
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			params := strings.Split(entry, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), value) {
+				continue
+			}
+			for _, param := range params[1:] {
+				quality, ok := strings.CutPrefix(strings.TrimSpace(param), "q=")
+				if !ok {
+					continue
+				}
+				q, err := strconv.ParseFloat(quality, 64)
+				return err == nil && q > 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
 func (cfg *StaticFileMiddlewareConfig) LoadEnvironment() {
 	cfg.StaticAsset.LoadEnvironment()
-	cfg.Gallery.LoadEnvironment()
 }
 
 func (cfg *StaticFileMiddlewareConfig) EnsureDefaults() {
@@ -124,6 +379,9 @@ func (cfg *StaticAssetConfig) EnsureDefaults() {
 	if !strings.HasSuffix(cfg.Uri, "/") {
 		cfg.Uri = cfg.Uri + "/"
 	}
+	if cfg.CacheMaxAge == 0 {
+		cfg.CacheMaxAge = DEFAULT_STATIC_ASSET_CACHE_MAX_AGE
+	}
 	if cfg.Folder == "" {
 		cfg.Folder = "/var/www/html/static/"
 	}
@@ -132,22 +390,8 @@ func (cfg *StaticAssetConfig) EnsureDefaults() {
 	}
 }
 
-func (cfg *GalleryAssetConfig) LoadEnvironment() {
-	uri, ok := os.LookupEnv("GALLERY_URI")
-	if ok {
-		slog.InfoContext(context.Background(), "Override gallery uri from environment")
-		cfg.Uri = uri
-	}
-}
-
 func (cfg *GalleryAssetConfig) EnsureDefaults() {
-	if cfg.Uri == "" {
-		cfg.Uri = GALLERY_BASE_URI
-	}
-	if !strings.HasPrefix(cfg.Uri, "/") {
-		cfg.Uri = "/" + cfg.Uri
-	}
-	if !strings.HasSuffix(cfg.Uri, "/") {
-		cfg.Uri = cfg.Uri + "/"
+	if cfg.CacheMaxAge == 0 {
+		cfg.CacheMaxAge = DEFAULT_GALLERY_CACHE_MAX_AGE
 	}
 }
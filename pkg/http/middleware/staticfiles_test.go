@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsMimeType(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   []string
+		expected bool
+	}{
+		{"no header", nil, false},
+		{"browser", []string{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8"}, true},
+		{"wildcard only", []string{"image/*,*/*"}, false},
+		{"quality", []string{"image/png, image/webp; q=0.5"}, true},
+		{"refused", []string{"image/webp;q=0, */*"}, false},
+		{"multiple headers", []string{"image/png", "IMAGE/WEBP"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/assets/gallery/1", nil)
+			for _, value := range tt.accept {
+				r.Header.Add("Accept", value)
+			}
+			assert.Equal(t, tt.expected, acceptsMimeType(r, "image/webp"))
+		})
+	}
+}
+
+// testGalleryService serves a single image, the other service methods aren't used
+type testGalleryService struct {
+	gallery.Service
+	image    *model.Image
+	data     []byte
+	seekable bool
+	reads    int
+}
+
+func (svc *testGalleryService) ImageBaseUri() string {
+	return "/assets/gallery/"
+}
+
+func (svc *testGalleryService) GetImageById(ctx context.Context, id string) (*model.Image, error) {
+	if id != svc.image.Id {
+		return nil, gallery.ErrImageNotFound
+	}
+	return svc.image, nil
+}
+
+func (svc *testGalleryService) GetPublicImageData(ctx context.Context, id string) (io.ReadCloser, string, string, error) {
+	svc.reads++
+	if svc.seekable {
+		return &testSeekableReader{bytes.NewReader(svc.data)}, svc.image.MimeType, "test.png", nil
+	}
+	return io.NopCloser(bytes.NewBuffer(svc.data)), svc.image.MimeType, "test.png", nil
+}
+
+func (svc *testGalleryService) GetImageRendition(ctx context.Context, id string, rendition *model.ImageRendition) (io.ReadCloser, string, string, error) {
+	if rendition.Name != "thumbnail" {
+		return nil, "", "", gallery.ErrRenditionNotFound
+	}
+	return svc.GetPublicImageData(ctx, id)
+}
+
+func (svc *testGalleryService) VerifyImageUrl(ctx context.Context, id string, expires string, signature string) error {
+	switch {
+	case signature != "valid":
+		return gallery.ErrImageUrlInvalid
+	case expires != "later":
+		return gallery.ErrImageUrlExpired
+	}
+	return nil
+}
+
+type testSeekableReader struct {
+	*bytes.Reader
+}
+
+func (r *testSeekableReader) Close() error {
+	return nil
+}
+
+func TestStaticFileMiddleware_Gallery(t *testing.T) {
+	uploadedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, seekable := range []bool{true, false} {
+		svc := &testGalleryService{
+			image: &model.Image{
+				Id:          "1",
+				MimeType:    "image/png",
+				ContentHash: "abc",
+				UploadedAt:  uploadedAt,
+			},
+			data:     []byte("0123456789"),
+			seekable: seekable,
+		}
+		handler := NewStaticFileMiddleware(svc, &StaticFileMiddlewareConfig{
+			Gallery: GalleryAssetConfig{CacheMaxAge: 60},
+		}).Middleware(http.NotFoundHandler())
+		serve := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			for key, value := range headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w
+		}
+
+		t.Run(fmt.Sprintf("seekable %v", seekable), func(t *testing.T) {
+			w := serve("/assets/gallery/1", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "0123456789", w.Body.String())
+			assert.Equal(t, "10", w.Header().Get("Content-Length"))
+			assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+			assert.Equal(t, uploadedAt.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+			assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+
+			// A cached copy is validated without reading the image
+			reads := svc.reads
+			w = serve("/assets/gallery/1", map[string]string{"If-None-Match": `"other", "abc"`})
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Equal(t, reads, svc.reads)
+
+			w = serve("/assets/gallery/1", map[string]string{"If-Modified-Since": uploadedAt.Format(http.TimeFormat), "If-None-Match": `"other"`})
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			w = serve("/assets/gallery/1", map[string]string{"Range": "bytes=2-5"})
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, "2345", w.Body.String())
+			assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"))
+
+			// Every rendition has its own tag
+			w = serve("/assets/gallery/1?size=thumbnail", map[string]string{"Accept": "image/webp"})
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, `"abc-thumbnail-webp"`, w.Header().Get("ETag"))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+			// Photos stay jpeg, as the lossless webp would be larger
+			svc.image.MimeType = "image/jpeg"
+			w = serve("/assets/gallery/1?size=thumbnail", map[string]string{"Accept": "image/webp"})
+			assert.Equal(t, `"abc-thumbnail"`, w.Header().Get("ETag"))
+			assert.Empty(t, w.Header().Get("Vary"))
+			svc.image.MimeType = "image/png"
+
+			w = serve("/assets/gallery/1?size=unknown", nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, w.Header().Get("ETag"))
+
+			w = serve("/assets/gallery/2", nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	}
+}
+
+func TestStaticFileMiddleware_PrivateGallery(t *testing.T) {
+	svc := &testGalleryService{
+		image: &model.Image{
+			Id:         "1",
+			Visibility: model.ImageVisibilityPrivate,
+			MimeType:   "image/png",
+		},
+		data: []byte("private"),
+	}
+	handler := NewStaticFileMiddleware(svc, nil).Middleware(http.NotFoundHandler())
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+	}{
+		{"unsigned", "/assets/gallery/1", http.StatusNotFound},
+		{"invalid signature", "/assets/gallery/1?expires=later&signature=invalid", http.StatusNotFound},
+		{"expired", "/assets/gallery/1?expires=earlier&signature=valid", http.StatusForbidden},
+		{"signed", "/assets/gallery/1?expires=later&signature=valid", http.StatusOK},
+		{"signed rendition", "/assets/gallery/1?expires=later&signature=valid&size=thumbnail", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "private", w.Body.String())
+				assert.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestStaticFileMiddleware_StaticAsset(t *testing.T) {
+	root := t.TempDir()
+	folder := filepath.Join(root, "static")
+	assert.NoError(t, os.Mkdir(folder, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "secret.txt.gz"), []byte("secret"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "app.js"), []byte("plain"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "app.js.gz"), []byte("gzip"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "app.js.br"), []byte("brotli"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "style.css"), []byte("css"), 0644))
+	handler := NewStaticFileMiddleware(nil, &StaticFileMiddlewareConfig{
+		StaticAsset: StaticAssetConfig{Folder: folder},
+	}).Middleware(http.NotFoundHandler())
+
+	tests := []struct {
+		name             string
+		target           string
+		acceptEncoding   string
+		expectedStatus   int
+		expectedBody     string
+		expectedEncoding string
+	}{
+		{"uncompressed", "/static/app.js", "", http.StatusOK, "plain", ""},
+		{"gzip", "/static/app.js", "gzip, deflate", http.StatusOK, "gzip", "gzip"},
+		{"brotli preferred", "/static/app.js", "gzip, br", http.StatusOK, "brotli", "br"},
+		{"brotli refused", "/static/app.js", "gzip, br;q=0", http.StatusOK, "gzip", "gzip"},
+		{"no variant", "/static/style.css", "gzip, br", http.StatusOK, "css", ""},
+		{"outside folder", "/static/../secret.txt", "gzip", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+			assert.Equal(t, tt.expectedEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+			assert.Contains(t, w.Header().Get("Content-Type"), strings.Split(mime.TypeByExtension(filepath.Ext(tt.target)), ";")[0])
+		})
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"other", "abc"`, true},
+		{"*", true},
+		{`"abcd"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchesETag(tt.header, `"abc"`))
+		})
+	}
+}
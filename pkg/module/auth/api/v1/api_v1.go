@@ -1,20 +1,35 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/deb-ict/cloudbm-community/pkg/core"
 	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/oauth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
 	"github.com/deb-ict/go-router"
+	"github.com/deb-ict/go-router/authentication"
 	"github.com/deb-ict/go-router/authorization"
 )
 
 const (
-	PolicyReadUsersV1   = "auth_api:ReadUsers:v1"
-	PolicyCreateUsersV1 = "auth_api:CreateUsers:v1"
-	PolicyUpdateUsersV1 = "auth_api:UpdateUsers:v1"
-	PolicyDeleteUsersV1 = "auth_api:DeleteUsers:v1"
+	PolicyAuthenticatedV1 = "auth_api:Authenticated:v1"
+	PolicyReadUsersV1     = "auth_api:ReadUsers:v1"
+	PolicyCreateUsersV1   = "auth_api:CreateUsers:v1"
+	PolicyUpdateUsersV1   = "auth_api:UpdateUsers:v1"
+	PolicyResetUsersV1    = "auth_api:ResetUsers:v1"
+	PolicyDeleteUsersV1   = "auth_api:DeleteUsers:v1"
+	PolicyReadRolesV1     = "auth_api:ReadRoles:v1"
+	PolicyCreateRolesV1   = "auth_api:CreateRoles:v1"
+	PolicyUpdateRolesV1   = "auth_api:UpdateRoles:v1"
+	PolicyDeleteRolesV1   = "auth_api:DeleteRoles:v1"
+	PolicyAssignRolesV1   = "auth_api:AssignRoles:v1"
+	PolicyReadApiKeysV1   = "auth_api:ReadApiKeys:v1"
+	PolicyCreateApiKeysV1 = "auth_api:CreateApiKeys:v1"
+	PolicyDeleteApiKeysV1 = "auth_api:DeleteApiKeys:v1"
+	PolicyReadAuditV1     = "auth_api:ReadAudit:v1"
 )
 
 type ApiV1 interface {
@@ -23,16 +38,23 @@ type ApiV1 interface {
 }
 
 type apiV1 struct {
-	service auth.Service
+	service           auth.Service
+	mfaRequiredScopes []string
 }
 
-func NewApiV1(service auth.Service) ApiV1 {
+// NewApiV1 creates the auth api, the scopes which require mfa are the ones of the token handler.
+func NewApiV1(service auth.Service, mfaRequiredScopes []string) ApiV1 {
 	return &apiV1{
-		service: service,
+		service:           service,
+		mfaRequiredScopes: mfaRequiredScopes,
 	}
 }
 
 func (api *apiV1) RegisterAuthorizationPolicies(middleware *authorization.Middleware) {
+	// Api keys authenticate as their user, but must not be able to manage the account itself
+	middleware.SetPolicy(authorization.NewPolicy(PolicyAuthenticatedV1,
+		&interactiveRequirement{},
+	))
 	middleware.SetPolicy(authorization.NewPolicy(PolicyReadUsersV1,
 		authorization.NewScopeRequirement("user.read"),
 	))
@@ -42,9 +64,54 @@ func (api *apiV1) RegisterAuthorizationPolicies(middleware *authorization.Middle
 	middleware.SetPolicy(authorization.NewPolicy(PolicyUpdateUsersV1,
 		authorization.NewScopeRequirement("user.update"),
 	))
+	// Taking over the credentials of another user, possibly an administrator, requires mfa
+	middleware.SetPolicy(authorization.NewPolicy(PolicyResetUsersV1,
+		authorization.NewScopeRequirement("user.update"),
+		authorization.NewClaimRequirement("amr", oauth.AuthMethodOtp),
+	))
 	middleware.SetPolicy(authorization.NewPolicy(PolicyDeleteUsersV1,
 		authorization.NewScopeRequirement("user.delete"),
 	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyReadRolesV1,
+		authorization.NewScopeRequirement("role.read"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyCreateRolesV1,
+		authorization.NewScopeRequirement("role.create"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyUpdateRolesV1,
+		authorization.NewScopeRequirement("role.update"),
+		authorization.NewClaimRequirement("amr", oauth.AuthMethodOtp),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyDeleteRolesV1,
+		authorization.NewScopeRequirement("role.delete"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyAssignRolesV1,
+		authorization.NewScopeRequirement("role.assign"),
+		authorization.NewClaimRequirement("amr", oauth.AuthMethodOtp),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyReadApiKeysV1,
+		authorization.NewScopeRequirement("apikey.read"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyCreateApiKeysV1,
+		authorization.NewScopeRequirement("apikey.create"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyDeleteApiKeysV1,
+		authorization.NewScopeRequirement("apikey.delete"),
+	))
+	middleware.SetPolicy(authorization.NewPolicy(PolicyReadAuditV1,
+		authorization.NewScopeRequirement("audit.read"),
+	))
+}
+
+// interactiveRequirement refuses requests authenticated with an api key.
+type interactiveRequirement struct {
+}
+
+func (r *interactiveRequirement) MeetsRequirement(auth authentication.Context) bool {
+	if auth == nil || !auth.IsAuthenticated() {
+		return false
+	}
+	return !auth.GetClaim("amr").HasValue(oauth.AuthMethodApiKey)
 }
 
 func (api *apiV1) RegisterRoutes(r *router.Router) {
@@ -69,6 +136,156 @@ func (api *apiV1) RegisterRoutes(r *router.Router) {
 		router.AllowedMethod(http.MethodDelete),
 		router.Authorized(PolicyDeleteUsersV1),
 	)
+	r.HandleFunc("/v1/user/{id}/role", api.GetUserRolesHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadUsersV1),
+	)
+	r.HandleFunc("/v1/user/{id}/role/{roleId}", api.AddUserRoleHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyAssignRolesV1),
+	)
+	r.HandleFunc("/v1/user/{id}/role/{roleId}", api.RemoveUserRoleHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyAssignRolesV1),
+	)
+	r.HandleFunc("/v1/user/{id}/password", api.SetUserPasswordHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyResetUsersV1),
+	)
+	r.HandleFunc("/v1/user/{id}/unlock", api.UnlockUserHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyUpdateUsersV1),
+	)
+	r.HandleFunc("/v1/user/{id}/mfa", api.ResetUserMfaHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyResetUsersV1),
+	)
+	r.HandleFunc("/v1/user/{id}/activation", api.SendActivationHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyUpdateUsersV1),
+	)
+
+	// Current user
+	r.HandleFunc("/v1/me", api.GetProfileHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/email", api.ChangeEmailHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/phone", api.ChangePhoneHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/email/verification", api.SendEmailVerificationHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/email/verify", api.VerifyMyEmailHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/phone/verification", api.SendPhoneVerificationHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/phone/verify", api.VerifyMyPhoneHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/password", api.ChangePasswordHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/token", api.GetRefreshTokensHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/token/{id}", api.RevokeRefreshTokenHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/apikey", api.GetMyApiKeysHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/apikey", api.CreateMyApiKeyHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/apikey/{id}", api.RevokeMyApiKeyHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/mfa/totp", api.EnrollTotpHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/mfa/totp/confirm", api.ConfirmTotpHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/mfa/totp/disable", api.DisableTotpHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+
+	// Account activation, password reset and email verification, these are public
+	r.HandleFunc("/v1/activate", api.ActivateUserHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+	)
+	r.HandleFunc("/v1/password/forgot", api.RequestPasswordResetHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+	)
+	r.HandleFunc("/v1/password/reset", api.ResetPasswordHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+	)
+	r.HandleFunc("/v1/verify/email", api.VerifyEmailHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+	)
+
+	// Roles
+	r.HandleFunc("/v1/role", api.GetRolesHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadRolesV1),
+	)
+	r.HandleFunc("/v1/role/{id}", api.GetRoleByIdHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadRolesV1),
+	)
+	r.HandleFunc("/v1/role", api.CreateRoleHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyCreateRolesV1),
+	)
+	r.HandleFunc("/v1/role/{id}", api.UpdateRoleHandlerV1,
+		router.AllowedMethod(http.MethodPut),
+		router.Authorized(PolicyUpdateRolesV1),
+	)
+	r.HandleFunc("/v1/role/{id}", api.DeleteRoleHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyDeleteRolesV1),
+	)
+
+	// Api keys
+	r.HandleFunc("/v1/apikey", api.GetApiKeysHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadApiKeysV1),
+	)
+	r.HandleFunc("/v1/apikey", api.CreateApiKeyHandlerV1,
+		router.AllowedMethod(http.MethodPost),
+		router.Authorized(PolicyCreateApiKeysV1),
+	)
+	r.HandleFunc("/v1/apikey/{id}", api.DeleteApiKeyHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyDeleteApiKeysV1),
+	)
+
+	// Audit log
+	r.HandleFunc("/v1/audit", api.GetAuditEventsHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadAuditV1),
+	)
 }
 
 func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
@@ -76,6 +293,14 @@ func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
 		return false
 	}
 
+	var policyErr *security.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		rest.WriteValidationError(w, err.Error(), map[string][]string{
+			"password": policyErr.Violations,
+		})
+		return true
+	}
+
 	switch err {
 	case auth.ErrUserNotFound:
 		rest.WriteError(w, http.StatusNotFound, err.Error())
@@ -83,6 +308,54 @@ func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	case auth.ErrDuplicateEmail:
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrPasswordNotMatch:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrUserLocked:
+		rest.WriteError(w, http.StatusLocked, err.Error())
+	case auth.ErrUserDisabled:
+		rest.WriteError(w, http.StatusForbidden, err.Error())
+	case auth.ErrUserAlreadyActive:
+		rest.WriteError(w, http.StatusConflict, err.Error())
+	case auth.ErrInvalidToken:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrTokenExpired:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrVerificationRateLimited:
+		rest.WriteError(w, http.StatusTooManyRequests, err.Error())
+	case auth.ErrVerificationLimited:
+		rest.WriteError(w, http.StatusTooManyRequests, err.Error())
+	case auth.ErrNoEmail:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrNoPhone:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrAlreadyVerified:
+		rest.WriteError(w, http.StatusConflict, err.Error())
+	case auth.ErrMfaAlreadyEnabled:
+		rest.WriteError(w, http.StatusConflict, err.Error())
+	case auth.ErrMfaNotEnabled:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrMfaNotEnrolled:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrInvalidOtp:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrRefreshTokenNotFound:
+		rest.WriteError(w, http.StatusNotFound, err.Error())
+	case auth.ErrApiKeyNotFound:
+		rest.WriteError(w, http.StatusNotFound, err.Error())
+	case auth.ErrApiKeyOwnerInvalid:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrApiKeyClientNotFound:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrApiKeyPrefixConflict:
+		rest.WriteError(w, http.StatusConflict, err.Error())
+	case auth.ErrScopeNotAllowed:
+		rest.WriteError(w, http.StatusForbidden, err.Error())
+	case auth.ErrMfaRequired:
+		rest.WriteError(w, http.StatusForbidden, err.Error())
+	case auth.ErrRoleNotFound:
+		rest.WriteError(w, http.StatusNotFound, err.Error())
+	case auth.ErrDuplicateRoleName:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	case core.ErrInvalidId:
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	default:
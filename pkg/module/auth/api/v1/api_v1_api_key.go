@@ -0,0 +1,246 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/oauth"
+	"github.com/deb-ict/go-router"
+	"github.com/deb-ict/go-router/authentication"
+)
+
+type ApiKeyListV1 struct {
+	rest.PaginatedList
+	Items []*ApiKeyV1 `json:"items"`
+}
+
+type ApiKeyV1 struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	UserId     string     `json:"user_id,omitempty"`
+	ClientId   string     `json:"client_id,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type CreatedApiKeyV1 struct {
+	ApiKeyV1
+	// The key is only returned when it is created
+	Key string `json:"key"`
+}
+
+type CreateApiKeyV1 struct {
+	Name      string     `json:"name"`
+	UserId    string     `json:"user_id"`
+	ClientId  string     `json:"client_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (api *apiV1) GetApiKeysHandlerV1(w http.ResponseWriter, r *http.Request) {
+	api.getApiKeys(w, r, api.parseApiKeyFilterV1(r))
+}
+
+func (api *apiV1) CreateApiKeyHandlerV1(w http.ResponseWriter, r *http.Request) {
+	var model *CreateApiKeyV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	if api.handleError(w, api.checkApiKeyScopes(r, model.Scopes)) {
+		return
+	}
+
+	api.createApiKey(w, r, model)
+}
+
+func (api *apiV1) DeleteApiKeyHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	err := api.service.DeleteApiKey(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) GetMyApiKeysHandlerV1(w http.ResponseWriter, r *http.Request) {
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	api.getApiKeys(w, r, &model.ApiKeyFilter{
+		UserId: userId,
+	})
+}
+
+func (api *apiV1) CreateMyApiKeyHandlerV1(w http.ResponseWriter, r *http.Request) {
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	var model *CreateApiKeyV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	model.UserId = userId
+	model.ClientId = ""
+	if api.handleError(w, api.checkApiKeyScopes(r, model.Scopes)) {
+		return
+	}
+
+	api.createApiKey(w, r, model)
+}
+
+func (api *apiV1) RevokeMyApiKeyHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	id := router.Param(r, "id")
+
+	err := api.service.RevokeUserApiKey(ctx, userId, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) getApiKeys(w http.ResponseWriter, r *http.Request, filter *model.ApiKeyFilter) {
+	ctx := r.Context()
+
+	paging := rest.GetPaging(r)
+	sort := rest.GetSorting(r)
+
+	result, count, err := api.service.GetApiKeys(ctx, (paging.PageIndex-1)*paging.PageSize, paging.PageSize, filter, sort)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := ApiKeyListV1{
+		PaginatedList: rest.PaginatedList{
+			PageIndex: paging.PageIndex,
+			PageSize:  paging.PageSize,
+			ItemCount: count,
+		},
+		Items: make([]*ApiKeyV1, 0),
+	}
+	for _, item := range result {
+		response.Items = append(response.Items, ApiKeyToViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) createApiKey(w http.ResponseWriter, r *http.Request, model *CreateApiKeyV1) {
+	ctx := r.Context()
+
+	errors := make(map[string][]string)
+	if model.Name == "" {
+		errors["name"] = []string{"name is required"}
+	}
+	if len(model.Scopes) == 0 {
+		errors["scopes"] = []string{"at least one scope is required"}
+	}
+	if model.ExpiresAt != nil && !model.ExpiresAt.After(time.Now()) {
+		errors["expires_at"] = []string{"expiry must be in the future"}
+	}
+	if len(errors) > 0 {
+		rest.WriteValidationError(w, "invalid api key", errors)
+		return
+	}
+
+	key, result, err := api.service.CreateApiKey(ctx, ApiKeyFromCreateViewModelV1(model))
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := &CreatedApiKeyV1{
+		ApiKeyV1: *ApiKeyToViewModelV1(result),
+		Key:      key,
+	}
+	rest.WriteResult(w, response)
+}
+
+// checkApiKeyScopes makes sure a key can't grant more than the token used to create it. Scopes which
+// require mfa are refused unless the caller authenticated with a one-time password, so they can't be
+// passed on by a caller which got them from an api key.
+func (api *apiV1) checkApiKeyScopes(r *http.Request, scopes []string) error {
+	caller := authentication.GetContext(r.Context())
+	hasMfa := caller.HasClaimValue("amr", oauth.AuthMethodOtp)
+	for _, scope := range scopes {
+		if !caller.HasScope(scope) {
+			return auth.ErrScopeNotAllowed
+		}
+		if !hasMfa && slices.Contains(api.mfaRequiredScopes, scope) {
+			return auth.ErrMfaRequired
+		}
+	}
+	return nil
+}
+
+func (api *apiV1) parseApiKeyFilterV1(r *http.Request) *model.ApiKeyFilter {
+	filter := &model.ApiKeyFilter{
+		UserId:   r.URL.Query().Get("user_id"),
+		ClientId: r.URL.Query().Get("client_id"),
+	}
+	return filter
+}
+
+func ApiKeyToViewModelV1(model *model.ApiKey) *ApiKeyV1 {
+	viewModel := &ApiKeyV1{
+		Id:        model.Id,
+		Name:      model.Name,
+		UserId:    model.UserId,
+		ClientId:  model.ClientId,
+		Prefix:    model.Prefix,
+		Scopes:    make([]string, 0),
+		CreatedAt: model.CreatedAt,
+	}
+	viewModel.Scopes = append(viewModel.Scopes, model.Scopes...)
+	if !model.ExpiresAt.IsZero() {
+		expiresAt := model.ExpiresAt
+		viewModel.ExpiresAt = &expiresAt
+	}
+	if !model.LastUsedAt.IsZero() {
+		lastUsedAt := model.LastUsedAt
+		viewModel.LastUsedAt = &lastUsedAt
+	}
+	return viewModel
+}
+
+func ApiKeyFromCreateViewModelV1(viewModel *CreateApiKeyV1) *model.ApiKey {
+	model := &model.ApiKey{
+		Name:     viewModel.Name,
+		UserId:   viewModel.UserId,
+		ClientId: viewModel.ClientId,
+		Scopes:   make([]string, 0),
+	}
+	for _, scope := range viewModel.Scopes {
+		if scope != "" && !slices.Contains(model.Scopes, scope) {
+			model.Scopes = append(model.Scopes, scope)
+		}
+	}
+	if viewModel.ExpiresAt != nil {
+		model.ExpiresAt = viewModel.ExpiresAt.UTC()
+	}
+	return model
+}
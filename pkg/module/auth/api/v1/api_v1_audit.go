@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+)
+
+type AuditEventListV1 struct {
+	rest.PaginatedList
+	Items []*AuditEventV1 `json:"items"`
+}
+
+type AuditEventV1 struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	ActorId   string            `json:"actor_id"`
+	SubjectId string            `json:"subject_id"`
+	IpAddress string            `json:"ip_address"`
+	UserAgent string            `json:"user_agent"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func (api *apiV1) GetAuditEventsHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, ok := api.parseAuditEventFilterV1(w, r)
+	if !ok {
+		return
+	}
+	paging := rest.GetPaging(r)
+	sort := rest.GetSorting(r)
+
+	result, count, err := api.service.GetAuditEvents(ctx, (paging.PageIndex-1)*paging.PageSize, paging.PageSize, filter, sort)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := AuditEventListV1{
+		PaginatedList: rest.PaginatedList{
+			PageIndex: paging.PageIndex,
+			PageSize:  paging.PageSize,
+			ItemCount: count,
+		},
+		Items: make([]*AuditEventV1, 0),
+	}
+	for _, item := range result {
+		response.Items = append(response.Items, AuditEventToViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) parseAuditEventFilterV1(w http.ResponseWriter, r *http.Request) (*model.AuditEventFilter, bool) {
+	query := r.URL.Query()
+	filter := &model.AuditEventFilter{
+		Type:      model.AuditEventType(query.Get("type")),
+		ActorId:   query.Get("actor_id"),
+		SubjectId: query.Get("subject_id"),
+		IpAddress: query.Get("ip_address"),
+		Outcome:   model.AuditOutcome(query.Get("outcome")),
+	}
+
+	errors := make(map[string][]string)
+	for _, param := range []string{"from", "to"} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors[param] = []string{"expected a RFC 3339 timestamp"}
+			continue
+		}
+		if param == "from" {
+			filter.From = t.UTC()
+		} else {
+			filter.To = t.UTC()
+		}
+	}
+	if len(errors) > 0 {
+		rest.WriteValidationError(w, "invalid audit event filter", errors)
+		return nil, false
+	}
+
+	return filter, true
+}
+
+func AuditEventToViewModelV1(model *model.AuditEvent) *AuditEventV1 {
+	return &AuditEventV1{
+		Id:        model.Id,
+		Type:      string(model.Type),
+		ActorId:   model.ActorId,
+		SubjectId: model.SubjectId,
+		IpAddress: model.IpAddress,
+		UserAgent: model.UserAgent,
+		Outcome:   string(model.Outcome),
+		Reason:    model.Reason,
+		Details:   model.Details,
+		Timestamp: model.Timestamp,
+	}
+}
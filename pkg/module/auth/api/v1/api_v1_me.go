@@ -0,0 +1,234 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/go-router"
+	"github.com/deb-ict/go-router/authentication"
+)
+
+type ProfileV1 struct {
+	Id            string `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	PendingEmail  string `json:"pending_email,omitempty"`
+	Phone         string `json:"phone"`
+	PhoneVerified bool   `json:"phone_verified"`
+	MfaEnabled    bool   `json:"mfa_enabled"`
+}
+
+type ChangeEmailV1 struct {
+	CurrentPassword string `json:"current_password"`
+	Email           string `json:"email"`
+}
+
+type ChangePhoneV1 struct {
+	Phone string `json:"phone"`
+}
+
+type ChangePasswordV1 struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RefreshTokenListV1 struct {
+	rest.PaginatedList
+	Items []*RefreshTokenV1 `json:"items"`
+}
+
+type RefreshTokenV1 struct {
+	Id        string    `json:"id"`
+	ClientId  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	AuthTime  time.Time `json:"auth_time"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (api *apiV1) GetProfileHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := api.service.GetUserById(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToProfileViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) ChangeEmailHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	var model *ChangeEmailV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	errors := make(map[string][]string)
+	if model.CurrentPassword == "" {
+		errors["current_password"] = []string{"current password is required"}
+	}
+	if model.Email == "" {
+		errors["email"] = []string{"email is required"}
+	}
+	if len(errors) > 0 {
+		rest.WriteValidationError(w, "invalid email", errors)
+		return
+	}
+
+	result, err := api.service.ChangeEmail(ctx, userId, model.CurrentPassword, model.Email)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToProfileViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) ChangePhoneHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	var model *ChangePhoneV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+
+	result, err := api.service.ChangePhone(ctx, userId, model.Phone)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToProfileViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) ChangePasswordHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	var model *ChangePasswordV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+
+	err = api.service.ChangePassword(ctx, userId, model.CurrentPassword, model.NewPassword)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) GetRefreshTokensHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	filter := &model.RefreshTokenFilter{
+		UserId: userId,
+	}
+	paging := rest.GetPaging(r)
+	sort := rest.GetSorting(r)
+
+	result, count, err := api.service.GetRefreshTokens(ctx, (paging.PageIndex-1)*paging.PageSize, paging.PageSize, filter, sort)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := RefreshTokenListV1{
+		PaginatedList: rest.PaginatedList{
+			PageIndex: paging.PageIndex,
+			PageSize:  paging.PageSize,
+			ItemCount: count,
+		},
+		Items: make([]*RefreshTokenV1, 0),
+	}
+	for _, item := range result {
+		response.Items = append(response.Items, RefreshTokenToViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) RevokeRefreshTokenHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	id := router.Param(r, "id")
+
+	err := api.service.RevokeRefreshToken(ctx, userId, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) currentUserId(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := authentication.GetContext(r.Context()).GetSubjectId()
+	if userId == "" {
+		rest.WriteStatus(w, http.StatusUnauthorized)
+		return "", false
+	}
+	return userId, true
+}
+
+func UserToProfileViewModelV1(model *model.User) *ProfileV1 {
+	return &ProfileV1{
+		Id:            model.Id,
+		Username:      model.Username,
+		Email:         model.Email,
+		EmailVerified: model.EmailVerified,
+		PendingEmail:  model.PendingEmail,
+		Phone:         model.Phone,
+		PhoneVerified: model.PhoneVerified,
+		MfaEnabled:    model.MfaEnabled,
+	}
+}
+
+func RefreshTokenToViewModelV1(model *model.RefreshToken) *RefreshTokenV1 {
+	viewModel := &RefreshTokenV1{
+		Id:        model.Id,
+		ClientId:  model.ClientId,
+		Scopes:    make([]string, 0),
+		AuthTime:  model.AuthTime,
+		CreatedAt: model.CreatedAt,
+		ExpiresAt: model.ExpiresAt,
+	}
+	viewModel.Scopes = append(viewModel.Scopes, model.Scopes...)
+	return viewModel
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/go-router"
+)
+
+type TotpEnrollmentV1 struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+}
+
+type VerifyOtpV1 struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesV1 struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (api *apiV1) EnrollTotpHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := api.service.EnrollTotp(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := TotpEnrollmentToViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) ConfirmTotpHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+	code, ok := api.parseOtpV1(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := api.service.ConfirmTotp(ctx, userId, code)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := &RecoveryCodesV1{
+		RecoveryCodes: result,
+	}
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) DisableTotpHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+	code, ok := api.parseOtpV1(w, r)
+	if !ok {
+		return
+	}
+
+	err := api.service.DisableTotp(ctx, userId, code)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) ResetUserMfaHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	err := api.service.ResetMfa(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) parseOtpV1(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var model *VerifyOtpV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return "", false
+	}
+	if model.Code == "" {
+		rest.WriteValidationError(w, "invalid one-time password", map[string][]string{
+			"code": {"code is required"},
+		})
+		return "", false
+	}
+	return model.Code, true
+}
+
+func TotpEnrollmentToViewModelV1(model *model.TotpEnrollment) *TotpEnrollmentV1 {
+	return &TotpEnrollmentV1{
+		Secret:          model.Secret,
+		ProvisioningUri: model.ProvisioningUri,
+	}
+}
@@ -0,0 +1,223 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/go-router"
+)
+
+type RoleV1 struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+}
+
+type RoleListV1 struct {
+	rest.PaginatedList
+	Items []*RoleListItemV1 `json:"items"`
+}
+
+type RoleListItemV1 struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateRoleV1 struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+}
+
+type UpdateRoleV1 struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+}
+
+func (api *apiV1) GetRolesHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter := api.parseRoleFilterV1(r)
+	paging := rest.GetPaging(r)
+	sort := rest.GetSorting(r)
+
+	result, count, err := api.service.GetRoles(ctx, (paging.PageIndex-1)*paging.PageSize, paging.PageSize, filter, sort)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := RoleListV1{
+		PaginatedList: rest.PaginatedList{
+			PageIndex: paging.PageIndex,
+			PageSize:  paging.PageSize,
+			ItemCount: count,
+		},
+		Items: make([]*RoleListItemV1, 0),
+	}
+	for _, item := range result {
+		response.Items = append(response.Items, RoleToListItemViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) GetRoleByIdHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	result, err := api.service.GetRoleById(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := RoleToViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) CreateRoleHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var model *CreateRoleV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+
+	result, err := api.service.CreateRole(ctx, RoleFromCreateViewModelV1(model))
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := RoleToViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) UpdateRoleHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	var model *UpdateRoleV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+
+	result, err := api.service.UpdateRole(ctx, id, RoleFromUpdateViewModelV1(model))
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := RoleToViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) DeleteRoleHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	err := api.service.DeleteRole(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) GetUserRolesHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	result, err := api.service.GetUserRoles(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := make([]*RoleV1, 0)
+	for _, item := range result {
+		response = append(response, RoleToViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) AddUserRoleHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	roleId := router.Param(r, "roleId")
+
+	result, err := api.service.AddUserRole(ctx, id, roleId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToViewModel(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) RemoveUserRoleHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	roleId := router.Param(r, "roleId")
+
+	result, err := api.service.RemoveUserRole(ctx, id, roleId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToViewModel(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) parseRoleFilterV1(r *http.Request) *model.RoleFilter {
+	return &model.RoleFilter{
+		Name: r.URL.Query().Get("name"),
+	}
+}
+
+func RoleToViewModelV1(model *model.Role) *RoleV1 {
+	viewModel := &RoleV1{
+		Id:          model.Id,
+		Name:        model.Name,
+		Description: model.Description,
+		Scopes:      make([]string, 0),
+	}
+	viewModel.Scopes = append(viewModel.Scopes, model.Scopes...)
+	return viewModel
+}
+
+func RoleToListItemViewModelV1(model *model.Role) *RoleListItemV1 {
+	return &RoleListItemV1{
+		Id:          model.Id,
+		Name:        model.Name,
+		Description: model.Description,
+	}
+}
+
+func RoleFromCreateViewModelV1(viewModel *CreateRoleV1) *model.Role {
+	model := &model.Role{
+		Name:        viewModel.Name,
+		Description: viewModel.Description,
+		Scopes:      make([]string, 0),
+	}
+	model.Scopes = append(model.Scopes, viewModel.Scopes...)
+	return model
+}
+
+func RoleFromUpdateViewModelV1(viewModel *UpdateRoleV1) *model.Role {
+	model := &model.Role{
+		Name:        viewModel.Name,
+		Description: viewModel.Description,
+		Scopes:      make([]string, 0),
+	}
+	model.Scopes = append(model.Scopes, viewModel.Scopes...)
+	return model
+}
@@ -10,14 +10,16 @@ import (
 )
 
 type UserV1 struct {
-	Id            string `json:"id"`
-	Username      string `json:"username"`
-	Email         string `json:"email"`
-	EmailVerified bool   `json:"email_verified"`
-	Phone         string `json:"phone"`
-	PhoneVerified bool   `json:"phone_verified"`
-	IsLocked      bool   `json:"is_locked"`
-	IsEnabled     bool   `json:"is_enabled"`
+	Id            string   `json:"id"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Phone         string   `json:"phone"`
+	PhoneVerified bool     `json:"phone_verified"`
+	IsLocked      bool     `json:"is_locked"`
+	IsEnabled     bool     `json:"is_enabled"`
+	MfaEnabled    bool     `json:"mfa_enabled"`
+	Roles         []string `json:"roles"`
 }
 
 type UserListV1 struct {
@@ -38,6 +40,7 @@ type UserListItemV1 struct {
 
 type CreateUserV1 struct {
 	Username      string `json:"username"`
+	Password      string `json:"password"`
 	Email         string `json:"email"`
 	EmailVerified bool   `json:"email_verified"`
 	Phone         string `json:"phone"`
@@ -45,6 +48,10 @@ type CreateUserV1 struct {
 	IsEnabled     bool   `json:"is_enabled"`
 }
 
+type SetPasswordV1 struct {
+	Password string `json:"password"`
+}
+
 type UpdateUserV1 struct {
 	EmailVerified bool   `json:"email_verified"`
 	Phone         string `json:"phone"`
@@ -101,7 +108,7 @@ func (api *apiV1) CreateUserHandlerV1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := api.service.CreateUser(ctx, UserFromCreateViewModelV1(model))
+	result, err := api.service.CreateUser(ctx, UserFromCreateViewModelV1(model), model.Password)
 	if api.handleError(w, err) {
 		return
 	}
@@ -143,6 +150,43 @@ func (api *apiV1) DeleteUserHandlerV1(w http.ResponseWriter, r *http.Request) {
 	rest.WriteStatus(w, http.StatusNoContent)
 }
 
+func (api *apiV1) SetUserPasswordHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	var model *SetPasswordV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+
+	err = api.service.SetPassword(ctx, id, model.Password)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) UnlockUserHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	user, err := api.service.GetUserById(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	result, err := api.service.UnlockUser(ctx, user)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToViewModel(result)
+	rest.WriteResult(w, response)
+}
+
 func (api *apiV1) parseUserFilterV1(r *http.Request) *model.UserFilter {
 	filter := &model.UserFilter{
 		Username: r.URL.Query().Get("username"),
@@ -152,16 +196,20 @@ func (api *apiV1) parseUserFilterV1(r *http.Request) *model.UserFilter {
 }
 
 func UserToViewModel(model *model.User) *UserV1 {
-	return &UserV1{
+	viewModel := &UserV1{
 		Id:            model.Id,
 		Username:      model.Username,
 		Email:         model.Email,
 		EmailVerified: model.EmailVerified,
 		Phone:         model.Phone,
 		PhoneVerified: model.PhoneVerified,
-		IsLocked:      model.IsLocked,
+		IsLocked:      model.Locked(),
 		IsEnabled:     model.IsEnabled,
+		MfaEnabled:    model.MfaEnabled,
+		Roles:         make([]string, 0),
 	}
+	viewModel.Roles = append(viewModel.Roles, model.Roles...)
+	return viewModel
 }
 
 func UserToListItemViewModelV1(model *model.User) *UserListItemV1 {
@@ -172,7 +220,7 @@ func UserToListItemViewModelV1(model *model.User) *UserListItemV1 {
 		EmailVerified: model.EmailVerified,
 		Phone:         model.Phone,
 		PhoneVerified: model.PhoneVerified,
-		IsLocked:      model.IsLocked,
+		IsLocked:      model.Locked(),
 		IsEnabled:     model.IsEnabled,
 	}
 }
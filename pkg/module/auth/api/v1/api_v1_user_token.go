@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/go-router"
+)
+
+type ActivateUserV1 struct {
+	UserId string `json:"user_id"`
+	Token  string `json:"token"`
+}
+
+type RequestPasswordResetV1 struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordV1 struct {
+	UserId   string `json:"user_id"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (api *apiV1) SendActivationHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+
+	err := api.service.SendActivation(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusAccepted)
+}
+
+func (api *apiV1) ActivateUserHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var model *ActivateUserV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	if model.UserId == "" || model.Token == "" {
+		rest.WriteValidationError(w, "invalid activation request", map[string][]string{
+			"token": {"user_id and token are required"},
+		})
+		return
+	}
+
+	_, err = api.service.ActivateUser(ctx, model.UserId, model.Token)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) RequestPasswordResetHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var model *RequestPasswordResetV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	if model.Email == "" {
+		rest.WriteValidationError(w, "invalid password reset request", map[string][]string{
+			"email": {"email is required"},
+		})
+		return
+	}
+
+	err = api.service.RequestPasswordReset(ctx, model.Email)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusAccepted)
+}
+
+func (api *apiV1) ResetPasswordHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var model *ResetPasswordV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	validationErrors := make(map[string][]string)
+	if model.UserId == "" || model.Token == "" {
+		validationErrors["token"] = []string{"user_id and token are required"}
+	}
+	if model.Password == "" {
+		validationErrors["password"] = []string{"password is required"}
+	}
+	if len(validationErrors) > 0 {
+		rest.WriteValidationError(w, "invalid password reset", validationErrors)
+		return
+	}
+
+	err = api.service.ResetPassword(ctx, model.UserId, model.Token, model.Password)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+)
+
+type VerifyCodeV1 struct {
+	Code string `json:"code"`
+}
+
+type VerifyEmailV1 struct {
+	UserId string `json:"user_id"`
+	Code   string `json:"code"`
+}
+
+func (api *apiV1) SendEmailVerificationHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	err := api.service.SendEmailVerification(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusAccepted)
+}
+
+func (api *apiV1) VerifyMyEmailHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	model, ok := api.decodeVerifyCodeV1(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := api.service.VerifyEmail(ctx, userId, model.Code)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToProfileViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+// VerifyEmailHandlerV1 handles the link in the verification email, which can be opened without signing in.
+func (api *apiV1) VerifyEmailHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var model *VerifyEmailV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return
+	}
+	if model.UserId == "" || model.Code == "" {
+		rest.WriteValidationError(w, "invalid verification request", map[string][]string{
+			"code": {"user_id and code are required"},
+		})
+		return
+	}
+
+	_, err = api.service.VerifyEmail(ctx, model.UserId, model.Code)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) SendPhoneVerificationHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	err := api.service.SendPhoneVerification(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusAccepted)
+}
+
+func (api *apiV1) VerifyMyPhoneHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	model, ok := api.decodeVerifyCodeV1(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := api.service.VerifyPhone(ctx, userId, model.Code)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := UserToProfileViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) decodeVerifyCodeV1(w http.ResponseWriter, r *http.Request) (*VerifyCodeV1, bool) {
+	var model *VerifyCodeV1
+	err := json.NewDecoder(r.Body).Decode(&model)
+	if api.handleError(w, err) {
+		return nil, false
+	}
+	if model.Code == "" {
+		rest.WriteValidationError(w, "invalid verification code", map[string][]string{
+			"code": {"code is required"},
+		})
+		return nil, false
+	}
+	return model, true
+}
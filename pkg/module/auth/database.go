@@ -10,6 +10,10 @@ import (
 type Database interface {
 	Users() UserRepository
 	UserTokens() UserTokenRepository
+	Roles() RoleRepository
+	RefreshTokens() RefreshTokenRepository
+	ApiKeys() ApiKeyRepository
+	AuditEvents() AuditEventRepository
 }
 
 type UserRepository interface {
@@ -24,5 +28,38 @@ type UserRepository interface {
 
 type UserTokenRepository interface {
 	CreateUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) (string, error)
+	UpdateUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error
 	DeleteUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error
 }
+
+type RoleRepository interface {
+	GetRoles(ctx context.Context, offset int64, limit int64, filter *model.RoleFilter, sort *core.Sort) ([]*model.Role, int64, error)
+	GetRoleById(ctx context.Context, id string) (*model.Role, error)
+	GetRoleByName(ctx context.Context, name string) (*model.Role, error)
+	CreateRole(ctx context.Context, role *model.Role) (string, error)
+	UpdateRole(ctx context.Context, role *model.Role) error
+	DeleteRole(ctx context.Context, role *model.Role) error
+}
+
+type RefreshTokenRepository interface {
+	GetRefreshTokens(ctx context.Context, offset int64, limit int64, filter *model.RefreshTokenFilter, sort *core.Sort) ([]*model.RefreshToken, int64, error)
+	GetRefreshTokenById(ctx context.Context, id string) (*model.RefreshToken, error)
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	CreateRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) (string, error)
+	DeleteRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) error
+}
+
+type ApiKeyRepository interface {
+	GetApiKeys(ctx context.Context, offset int64, limit int64, filter *model.ApiKeyFilter, sort *core.Sort) ([]*model.ApiKey, int64, error)
+	GetApiKeyById(ctx context.Context, id string) (*model.ApiKey, error)
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (*model.ApiKey, error)
+	CreateApiKey(ctx context.Context, apiKey *model.ApiKey) (string, error)
+	UpdateApiKey(ctx context.Context, apiKey *model.ApiKey) error
+	DeleteApiKey(ctx context.Context, apiKey *model.ApiKey) error
+}
+
+// The audit log is append-only, events can't be updated or deleted
+type AuditEventRepository interface {
+	GetAuditEvents(ctx context.Context, offset int64, limit int64, filter *model.AuditEventFilter, sort *core.Sort) ([]*model.AuditEvent, int64, error)
+	CreateAuditEvent(ctx context.Context, event *model.AuditEvent) (string, error)
+}
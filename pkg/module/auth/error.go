@@ -3,11 +3,31 @@ package auth
 import "errors"
 
 var (
-	ErrUserNotFound      error = errors.New("user not found")
-	ErrUserLocked        error = errors.New("user has been locked")
-	ErrPasswordNotMatch  error = errors.New("password not match")
-	ErrDuplicateUsername error = errors.New("user with same username exists")
-	ErrDuplicateEmail    error = errors.New("user with same email exists")
-	ErrInvalidToken      error = errors.New("invalid token")
-	ErrTokenExpired      error = errors.New("token has expired")
+	ErrUserNotFound            error = errors.New("user not found")
+	ErrUserLocked              error = errors.New("user has been locked")
+	ErrUserDisabled            error = errors.New("user has been disabled")
+	ErrPasswordNotMatch        error = errors.New("password not match")
+	ErrDuplicateUsername       error = errors.New("user with same username exists")
+	ErrDuplicateEmail          error = errors.New("user with same email exists")
+	ErrInvalidToken            error = errors.New("invalid token")
+	ErrTokenExpired            error = errors.New("token has expired")
+	ErrUserAlreadyActive       error = errors.New("user has already been activated")
+	ErrMfaAlreadyEnabled       error = errors.New("multi-factor authentication already enabled")
+	ErrMfaNotEnabled           error = errors.New("multi-factor authentication not enabled")
+	ErrMfaNotEnrolled          error = errors.New("multi-factor authentication enrolment not started")
+	ErrInvalidOtp              error = errors.New("invalid one-time password")
+	ErrVerificationRateLimited error = errors.New("verification code requested too recently")
+	ErrVerificationLimited     error = errors.New("too many verification codes, try again later")
+	ErrNoEmail                 error = errors.New("user has no email address")
+	ErrNoPhone                 error = errors.New("user has no phone number")
+	ErrAlreadyVerified         error = errors.New("already verified")
+	ErrRefreshTokenNotFound    error = errors.New("refresh token not found")
+	ErrApiKeyNotFound          error = errors.New("api key not found")
+	ErrApiKeyOwnerInvalid      error = errors.New("api key must belong to either a user or a client")
+	ErrApiKeyClientNotFound    error = errors.New("api key client not configured")
+	ErrApiKeyPrefixConflict    error = errors.New("api key prefix already in use")
+	ErrScopeNotAllowed         error = errors.New("scope not allowed")
+	ErrMfaRequired             error = errors.New("multi-factor authentication required")
+	ErrRoleNotFound            error = errors.New("role not found")
+	ErrDuplicateRoleName       error = errors.New("role with same name exists")
 )
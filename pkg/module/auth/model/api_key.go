@@ -0,0 +1,64 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+type ApiKey struct {
+	Id         string
+	Name       string
+	UserId     string
+	ClientId   string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+}
+
+type ApiKeyFilter struct {
+	UserId   string
+	ClientId string
+}
+
+// Subject returns the user or client the key was issued to.
+func (m *ApiKey) Subject() string {
+	if m.UserId != "" {
+		return m.UserId
+	}
+	return m.ClientId
+}
+
+func (m *ApiKey) HasExpired() bool {
+	if m == nil {
+		return true
+	}
+	if m.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().UTC().After(m.ExpiresAt)
+}
+
+func (m *ApiKey) IsTransient() bool {
+	return m.Id == ""
+}
+
+func (m *ApiKey) Clone() *ApiKey {
+	if m == nil {
+		return nil
+	}
+	return &ApiKey{
+		Id:         m.Id,
+		Name:       m.Name,
+		UserId:     m.UserId,
+		ClientId:   m.ClientId,
+		Prefix:     m.Prefix,
+		KeyHash:    m.KeyHash,
+		Scopes:     slices.Clone(m.Scopes),
+		CreatedAt:  m.CreatedAt,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+	}
+}
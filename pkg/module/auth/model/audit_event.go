@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditEventLogin           AuditEventType = "login.password"
+	AuditEventLoginOtp        AuditEventType = "login.otp"
+	AuditEventUserLocked      AuditEventType = "user.locked"
+	AuditEventUserUnlocked    AuditEventType = "user.unlocked"
+	AuditEventUserCreated     AuditEventType = "user.created"
+	AuditEventUserUpdated     AuditEventType = "user.updated"
+	AuditEventUserDeleted     AuditEventType = "user.deleted"
+	AuditEventUserActivated   AuditEventType = "user.activated"
+	AuditEventRoleAdded       AuditEventType = "user.role_added"
+	AuditEventRoleRemoved     AuditEventType = "user.role_removed"
+	AuditEventMfaReset        AuditEventType = "user.mfa_reset"
+	AuditEventPasswordSet     AuditEventType = "password.set"
+	AuditEventPasswordChanged AuditEventType = "password.changed"
+	AuditEventPasswordReset   AuditEventType = "password.reset"
+	AuditEventEmailVerified   AuditEventType = "email.verified"
+	AuditEventPhoneVerified   AuditEventType = "phone.verified"
+	AuditEventCodeSent        AuditEventType = "verification.sent"
+	AuditEventTokenIssued     AuditEventType = "token.issued"
+	AuditEventTokenRefreshed  AuditEventType = "token.refreshed"
+	AuditEventTokenRevoked    AuditEventType = "token.revoked"
+	AuditEventApiKeyCreated   AuditEventType = "apikey.created"
+	AuditEventApiKeyRevoked   AuditEventType = "apikey.revoked"
+)
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// AuditEvent records a security relevant action. The actor performed the action, the subject
+// is the user it was performed on, which is the same user for self-service actions.
+type AuditEvent struct {
+	Id        string
+	Type      AuditEventType
+	ActorId   string
+	SubjectId string
+	IpAddress string
+	UserAgent string
+	Outcome   AuditOutcome
+	Reason    string
+	Details   map[string]string
+	Timestamp time.Time
+}
+
+type AuditEventFilter struct {
+	Type      AuditEventType
+	ActorId   string
+	SubjectId string
+	IpAddress string
+	Outcome   AuditOutcome
+	From      time.Time
+	To        time.Time
+}
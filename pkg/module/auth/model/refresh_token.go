@@ -0,0 +1,51 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+type RefreshToken struct {
+	Id          string
+	UserId      string
+	ClientId    string
+	TokenHash   string
+	Scopes      []string
+	AuthMethods []string
+	AuthTime    time.Time
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+type RefreshTokenFilter struct {
+	UserId        string
+	ExpiresBefore time.Time
+}
+
+func (m *RefreshToken) HasExpired() bool {
+	if m == nil {
+		return true
+	}
+	return time.Now().UTC().After(m.ExpiresAt)
+}
+
+func (m *RefreshToken) IsTransient() bool {
+	return m.Id == ""
+}
+
+func (m *RefreshToken) Clone() *RefreshToken {
+	if m == nil {
+		return nil
+	}
+	return &RefreshToken{
+		Id:          m.Id,
+		UserId:      m.UserId,
+		ClientId:    m.ClientId,
+		TokenHash:   m.TokenHash,
+		Scopes:      slices.Clone(m.Scopes),
+		AuthMethods: slices.Clone(m.AuthMethods),
+		AuthTime:    m.AuthTime,
+		CreatedAt:   m.CreatedAt,
+		ExpiresAt:   m.ExpiresAt,
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/util"
+)
+
+type Role struct {
+	Id             string
+	Name           string
+	NormalizedName string
+	Description    string
+	Scopes         []string
+}
+
+type RoleFilter struct {
+	Name string
+}
+
+func (m *Role) Normalize(normalizer util.UserNormalizer) {
+	m.NormalizedName = normalizer.NormalizeRoleName(m.Name)
+	scopes := make([]string, 0)
+	for _, scope := range m.Scopes {
+		scope = strings.TrimSpace(scope)
+		if scope != "" && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	m.Scopes = scopes
+}
+
+func (m *Role) UpdateModel(other *Role) {
+	m.Name = other.Name
+	m.NormalizedName = other.NormalizedName
+	m.Description = other.Description
+	m.Scopes = slices.Clone(other.Scopes)
+}
+
+func (m *Role) HasScope(scope string) bool {
+	return slices.Contains(m.Scopes, scope)
+}
+
+func (m *Role) IsTransient() bool {
+	return m.Id == ""
+}
+
+func (m *Role) Clone() *Role {
+	if m == nil {
+		return nil
+	}
+	return &Role{
+		Id:             m.Id,
+		Name:           m.Name,
+		NormalizedName: m.NormalizedName,
+		Description:    m.Description,
+		Scopes:         slices.Clone(m.Scopes),
+	}
+}
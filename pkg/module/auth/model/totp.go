@@ -0,0 +1,6 @@
+package model
+
+type TotpEnrollment struct {
+	Secret          string
+	ProvisioningUri string
+}
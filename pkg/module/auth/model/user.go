@@ -1,6 +1,7 @@
 package model
 
 import (
+	"slices"
 	"time"
 
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
@@ -8,11 +9,13 @@ import (
 )
 
 type User struct {
-	Id                 string
-	Username           string
-	PasswordHash       string
-	Email              string
-	EmailVerified      bool
+	Id            string
+	Username      string
+	PasswordHash  string
+	Email         string
+	EmailVerified bool
+	// A changed email only replaces the email once it is verified
+	PendingEmail       string
 	Phone              string
 	PhoneVerified      bool
 	NormalizedUsername string
@@ -21,6 +24,11 @@ type User struct {
 	IsLocked           bool
 	IsEnabled          bool
 	LockEnd            time.Time
+	MfaEnabled         bool
+	TotpSecret         string
+	TotpLastCounter    int64
+	RecoveryCodes      []string
+	Roles              []string
 	Tokens             []*UserToken
 }
 
@@ -33,7 +41,7 @@ func (m *User) Locked() bool {
 	if !m.IsLocked {
 		return false
 	}
-	return time.Now().UTC().After(m.LockEnd)
+	return time.Now().UTC().Before(m.LockEnd)
 }
 
 func (m *User) Normalize(normalizer util.UserNormalizer) {
@@ -57,6 +65,7 @@ func (m *User) Lock(duration time.Duration) {
 func (m *User) Unlock() {
 	m.IsLocked = false
 	m.LockEnd = time.Now().UTC()
+	m.LoginFailures = 0
 }
 
 func (m *User) VerifyPassword(hasher security.PasswordHasher, password string) bool {
@@ -67,6 +76,45 @@ func (m *User) VerifyPassword(hasher security.PasswordHasher, password string) b
 	return valid
 }
 
+// ResetLoginFailures clears the failure count and an expired lock after a successful login.
+func (m *User) ResetLoginFailures() bool {
+	if m.LoginFailures == 0 && !m.IsLocked {
+		return false
+	}
+	m.LoginFailures = 0
+	m.IsLocked = false
+	return true
+}
+
+func (m *User) ResetMfa() {
+	m.MfaEnabled = false
+	m.TotpSecret = ""
+	m.TotpLastCounter = 0
+	m.RecoveryCodes = nil
+}
+
+func (m *User) HasRole(roleId string) bool {
+	return slices.Contains(m.Roles, roleId)
+}
+
+func (m *User) AddRole(roleId string) {
+	if !m.HasRole(roleId) {
+		m.Roles = append(m.Roles, roleId)
+	}
+}
+
+func (m *User) RemoveRole(roleId string) {
+	m.Roles = slices.DeleteFunc(m.Roles, func(id string) bool {
+		return id == roleId
+	})
+}
+
+func (m *User) RemoveToken(token *UserToken) {
+	m.Tokens = slices.DeleteFunc(m.Tokens, func(t *UserToken) bool {
+		return t == token
+	})
+}
+
 func (m *User) IsTransient() bool {
 	return m.Id == ""
 }
@@ -81,6 +129,7 @@ func (m *User) Clone() *User {
 		PasswordHash:       m.PasswordHash,
 		Email:              m.Email,
 		EmailVerified:      m.EmailVerified,
+		PendingEmail:       m.PendingEmail,
 		Phone:              m.Phone,
 		PhoneVerified:      m.PhoneVerified,
 		NormalizedUsername: m.NormalizedUsername,
@@ -89,6 +138,11 @@ func (m *User) Clone() *User {
 		IsLocked:           m.IsLocked,
 		IsEnabled:          m.IsEnabled,
 		LockEnd:            m.LockEnd,
+		MfaEnabled:         m.MfaEnabled,
+		TotpSecret:         m.TotpSecret,
+		TotpLastCounter:    m.TotpLastCounter,
+		RecoveryCodes:      slices.Clone(m.RecoveryCodes),
+		Roles:              slices.Clone(m.Roles),
 		Tokens:             make([]*UserToken, 0),
 	}
 	for _, token := range m.Tokens {
@@ -10,6 +10,8 @@ const (
 	UserTokenType_Undefined UserTokenType = iota
 	UserTokenType_ActivationToken
 	UserTokenType_PasswordResetToken
+	UserTokenType_EmailVerificationCode
+	UserTokenType_PhoneVerificationCode
 )
 
 type UserToken struct {
@@ -17,6 +19,11 @@ type UserToken struct {
 	Type       UserTokenType
 	Token      string
 	Expiration time.Time
+	CreatedAt  time.Time
+	// The email address or phone number the token was sent to
+	Target string
+	// Number of wrong codes entered, a short code can only be guessed a few times
+	Attempts int
 }
 
 func (m *UserToken) UpdateModel(other *UserToken) {
@@ -25,6 +32,8 @@ func (m *UserToken) UpdateModel(other *UserToken) {
 	}
 	m.Token = other.Token
 	m.Expiration = other.Expiration
+	m.Target = other.Target
+	m.Attempts = other.Attempts
 }
 
 func (m *UserToken) SetExpiration(duration time.Duration) {
@@ -53,6 +62,9 @@ func (m *UserToken) Clone() *UserToken {
 		Type:       m.Type,
 		Token:      m.Token,
 		Expiration: m.Expiration,
+		CreatedAt:  m.CreatedAt,
+		Target:     m.Target,
+		Attempts:   m.Attempts,
 	}
 }
 
@@ -62,6 +74,10 @@ func (t UserTokenType) String() string {
 		return "ActivationToken"
 	case UserTokenType_PasswordResetToken:
 		return "PasswordResetToken"
+	case UserTokenType_EmailVerificationCode:
+		return "EmailVerificationCode"
+	case UserTokenType_PhoneVerificationCode:
+		return "PhoneVerificationCode"
 	default:
 		return "Undefined"
 	}
@@ -73,6 +89,10 @@ func ParseUserTokenType(value string) UserTokenType {
 		return UserTokenType_ActivationToken
 	case "PasswordResetToken":
 		return UserTokenType_PasswordResetToken
+	case "EmailVerificationCode":
+		return UserTokenType_EmailVerificationCode
+	case "PhoneVerificationCode":
+		return UserTokenType_PhoneVerificationCode
 	default:
 		return UserTokenType_Undefined
 	}
@@ -0,0 +1,39 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/go-router/authentication"
+)
+
+// Authentication method reported in the amr claim for requests authenticated with an api key
+const AuthMethodApiKey string = "apikey"
+
+type ApiKeyValidator struct {
+	service auth.Service
+}
+
+func NewApiKeyValidator(service auth.Service) *ApiKeyValidator {
+	return &ApiKeyValidator{
+		service: service,
+	}
+}
+
+func (v *ApiKeyValidator) GetApiKeyAuthenticationData(apiKey string) (authentication.ClaimMap, error) {
+	data, err := v.service.ValidateApiKey(context.Background(), apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := authentication.ClaimMap{}
+	claims.SetSubjectId(data.Subject())
+	claims.SetClaimSingleValue("sub", data.Subject())
+	claims.SetClaimSingleValue("amr", AuthMethodApiKey)
+	claims.SetClaimSingleValue("jti", data.Id)
+	if data.ClientId != "" {
+		claims.SetClaimSingleValue("client_id", data.ClientId)
+	}
+	claims.AddScopes(data.Scopes...)
+	return claims, nil
+}
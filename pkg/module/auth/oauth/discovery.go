@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func (h *TokenHandler) DiscoveryEndpoint(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.KeyManager().VerificationKeys(r.Context())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	algorithms := make([]string, 0)
+	for _, key := range keys {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	document := &DiscoveryDocument{
+		Issuer:                            h.options.Issuer,
+		TokenEndpoint:                     h.options.Issuer + "/oauth/token",
+		UserInfoEndpoint:                  h.options.Issuer + "/userinfo",
+		JwksUri:                           h.options.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenId, ScopeProfile, ScopeEmail, ScopePhone},
+		ResponseTypesSupported:            []string{"token"},
+		GrantTypesSupported:               []string{"password", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr",
+			"name", "email", "email_verified", "phone", "phone_verified",
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(document)
+}
@@ -0,0 +1,35 @@
+package oauth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+)
+
+func (h *TokenHandler) JwksEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	keys, err := h.service.KeyManager().VerificationKeys(ctx)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	keySet, err := security.NewJSONWebKeySet(keys)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to build json web key set",
+			slog.Any("error", err),
+		)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the cache short, verifiers must pick up rotated keys
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keySet)
+}
@@ -0,0 +1,49 @@
+package oauth
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	DEFAULT_ISSUER   string = "https://localhost:8000"
+	DEFAULT_AUDIENCE string = "cloudbm"
+)
+
+type TokenHandlerOptions struct {
+	Issuer                     string `yaml:"issuer"`
+	Audience                   string `yaml:"audience"`
+	AccessTokenLifetimeSeconds int    `yaml:"access_token_lifetime_seconds"`
+	IdTokenLifetimeSeconds     int    `yaml:"id_token_lifetime_seconds"`
+	// Scopes which are only granted after multi-factor authentication
+	MfaRequiredScopes []string `yaml:"mfa_required_scopes"`
+}
+
+func (opts *TokenHandlerOptions) LoadEnvironment() {
+	issuer, ok := os.LookupEnv("OAUTH_ISSUER")
+	if ok {
+		slog.InfoContext(context.Background(), "Override oauth issuer from environment")
+		opts.Issuer = issuer
+	}
+}
+
+func (opts *TokenHandlerOptions) EnsureDefaults() {
+	if opts.Issuer == "" {
+		opts.Issuer = DEFAULT_ISSUER
+	}
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+	if opts.Audience == "" {
+		opts.Audience = DEFAULT_AUDIENCE
+	}
+	if opts.AccessTokenLifetimeSeconds <= 0 {
+		opts.AccessTokenLifetimeSeconds = TokenLifetimeSeconds
+	}
+	if opts.IdTokenLifetimeSeconds <= 0 {
+		opts.IdTokenLifetimeSeconds = TokenLifetimeSeconds
+	}
+	if opts.MfaRequiredScopes == nil {
+		opts.MfaRequiredScopes = []string{"user.delete"}
+	}
+}
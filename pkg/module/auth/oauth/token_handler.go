@@ -1,20 +1,46 @@
 package oauth
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
 	"github.com/deb-ict/go-router"
+	"github.com/deb-ict/go-router/authorization"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 const TokenLifetimeSeconds = 3600
 
-var TokenSecret = []byte("your-256-bit-secret")
+const PolicyUserInfo = "oauth:UserInfo"
+
+const (
+	AuthMethodPassword string = "pwd"
+	AuthMethodOtp      string = "otp"
+)
+
+// Token types in the typ header, resource servers only accept access tokens as bearer token
+const (
+	TokenTypeAccessToken string = "at+jwt"
+	TokenTypeIdToken     string = "JWT"
+)
+
+const (
+	ScopeOpenId  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
+	ScopePhone   string = "phone"
+)
 
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
@@ -22,7 +48,7 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
-	//example parameter
+	IdToken      string `json:"id_token,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -49,12 +75,41 @@ func (e *ErrorResponse) Send(w http.ResponseWriter) error {
 
 type TokenHandler struct {
 	service auth.Service
+	options TokenHandlerOptions
+}
+
+func NewTokenHandler(service auth.Service, opts *TokenHandlerOptions) *TokenHandler {
+	if opts == nil {
+		opts = &TokenHandlerOptions{}
+	}
+	opts.EnsureDefaults()
+
+	return &TokenHandler{
+		service: service,
+		options: *opts,
+	}
+}
+
+func (api *TokenHandler) RegisterAuthorizationPolicies(middleware *authorization.Middleware) {
+	middleware.SetPolicy(authorization.NewPolicy(PolicyUserInfo,
+		authorization.NewScopeRequirement(ScopeOpenId),
+	))
 }
 
 func (api *TokenHandler) RegisterRoutes(r *router.Router) {
 	r.HandleFunc("/oauth/token", api.TokenEndpoint,
 		router.AllowedMethod(http.MethodPost),
 	)
+	r.HandleFunc("/userinfo", api.UserInfoEndpoint,
+		router.AllowedMethods(http.MethodGet, http.MethodPost),
+		router.Authorized(PolicyUserInfo),
+	)
+	r.HandleFunc("/.well-known/jwks.json", api.JwksEndpoint,
+		router.AllowedMethod(http.MethodGet),
+	)
+	r.HandleFunc("/.well-known/openid-configuration", api.DiscoveryEndpoint,
+		router.AllowedMethod(http.MethodGet),
+	)
 }
 
 func (h *TokenHandler) TokenEndpoint(w http.ResponseWriter, r *http.Request) {
@@ -109,13 +164,15 @@ func (h *TokenHandler) TokenEndpoint(w http.ResponseWriter, r *http.Request) {
 	// Handle the grant type
 	switch grantType[0] {
 	case "password":
-		h.passwordTokenHandler(w, r)
+		h.passwordTokenHandler(w, r, clientId)
+	case "refresh_token":
+		h.refreshTokenHandler(w, r, clientId)
 	default:
 		h.tokenHandlerError(w, "unsupported_grant_type")
 	}
 }
 
-func (h *TokenHandler) passwordTokenHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TokenHandler) passwordTokenHandler(w http.ResponseWriter, r *http.Request, clientId string) {
 	usernameParam := r.Form["username"]
 	if len(usernameParam) != 1 {
 		h.tokenHandlerError(w, "invalid_request")
@@ -131,6 +188,20 @@ func (h *TokenHandler) passwordTokenHandler(w http.ResponseWriter, r *http.Reque
 	password := passwordParam[0]
 
 	user, err := h.service.GetUserByUsername(r.Context(), username)
+	if err == auth.ErrUserNotFound {
+		// There is no user to attach the failed login to, record the attempted username instead
+		h.service.CreateAuditEvent(r.Context(), &model.AuditEvent{
+			Type:    model.AuditEventLogin,
+			Outcome: model.AuditOutcomeFailure,
+			Reason:  auth.ErrUserNotFound.Error(),
+			Details: map[string]string{
+				"client_id": clientId,
+				"username":  username,
+			},
+		})
+		h.tokenHandlerError(w, "access_denied")
+		return
+	}
 	if err != nil {
 		h.tokenHandlerError(w, "access_denied")
 		return
@@ -140,21 +211,111 @@ func (h *TokenHandler) passwordTokenHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if !user.VerifyPassword(h.service.PasswordHasher(), password) {
+	err = h.service.VerifyPassword(r.Context(), user, password)
+	if err != nil {
 		h.tokenHandlerError(w, "access_denied")
 		return
 	}
 
-	tokenString, err := h.generateJwtToken(user)
+	authMethods := []string{AuthMethodPassword}
+	if user.MfaEnabled {
+		otp := r.Form.Get("otp")
+		if otp == "" {
+			h.tokenHandlerError(w, "mfa_required")
+			return
+		}
+		err = h.service.VerifyOtp(r.Context(), user, otp)
+		if err != nil {
+			h.tokenHandlerError(w, "access_denied")
+			return
+		}
+		authMethods = append(authMethods, AuthMethodOtp)
+	}
+
+	h.issueTokens(w, r, clientId, user, h.parseScopes(r), authMethods, time.Now().UTC())
+}
+
+func (h *TokenHandler) refreshTokenHandler(w http.ResponseWriter, r *http.Request, clientId string) {
+	refreshTokenParam := r.Form["refresh_token"]
+	if len(refreshTokenParam) != 1 {
+		h.tokenHandlerError(w, "invalid_request")
+		return
+	}
+
+	refreshToken, err := h.service.RedeemRefreshToken(r.Context(), refreshTokenParam[0], clientId)
+	if err != nil {
+		h.tokenHandlerError(w, "invalid_grant")
+		return
+	}
+
+	user, err := h.service.GetUserById(r.Context(), refreshToken.UserId)
+	if err != nil {
+		h.tokenHandlerError(w, "invalid_grant")
+		return
+	}
+	if !user.IsEnabled || user.Locked() {
+		h.tokenHandlerError(w, "invalid_grant")
+		return
+	}
+
+	// The client can narrow down the scopes, but not request more than originally granted
+	scopes := refreshToken.Scopes
+	requested := h.parseScopes(r)
+	if len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(refreshToken.Scopes, scope) {
+				h.tokenHandlerError(w, "invalid_scope")
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	h.issueTokens(w, r, clientId, user, scopes, refreshToken.AuthMethods, refreshToken.AuthTime)
+}
+
+func (h *TokenHandler) issueTokens(w http.ResponseWriter, r *http.Request, clientId string, user *model.User, requested []string, authMethods []string, authTime time.Time) {
+	roles, err := h.service.GetUserRoles(r.Context(), user.Id)
+	if err != nil {
+		h.tokenHandlerError(w, "server_error")
+		return
+	}
+	scopes := grantScopes(roles, requested)
+	if !slices.Contains(authMethods, AuthMethodOtp) {
+		scopes = withoutScopes(scopes, h.options.MfaRequiredScopes)
+	}
+
+	tokenString, err := h.generateJwtToken(r.Context(), user, roles, scopes, authMethods)
+	if err != nil {
+		h.tokenHandlerError(w, "server_error")
+		return
+	}
+
+	refreshToken, _, err := h.service.CreateRefreshToken(r.Context(), &model.RefreshToken{
+		UserId:      user.Id,
+		ClientId:    clientId,
+		Scopes:      scopes,
+		AuthMethods: authMethods,
+		AuthTime:    authTime,
+	})
 	if err != nil {
 		h.tokenHandlerError(w, "server_error")
 		return
 	}
 
 	response := &TokenResponse{
-		AccessToken: tokenString,
-		TokenType:   "bearer",
-		ExpiresIn:   TokenLifetimeSeconds,
+		AccessToken:  tokenString,
+		TokenType:    "bearer",
+		ExpiresIn:    h.options.AccessTokenLifetimeSeconds,
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}
+	if slices.Contains(scopes, ScopeOpenId) {
+		response.IdToken, err = h.generateIdToken(r.Context(), user, clientId, scopes, authMethods, authTime, r.Form.Get("nonce"), tokenString)
+		if err != nil {
+			h.tokenHandlerError(w, "server_error")
+			return
+		}
 	}
 	response.Send(w)
 }
@@ -166,15 +327,70 @@ func (h *TokenHandler) tokenHandlerError(w http.ResponseWriter, e string) {
 	errorResponse.Send(w)
 }
 
-func (h *TokenHandler) generateJwtToken(user *model.User) (string, error) {
+func (h *TokenHandler) parseScopes(r *http.Request) []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Fields(r.Form.Get("scope")) {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// grantScopes returns the scopes the user is allowed to receive. The OpenID Connect scopes
+// are granted when requested, the api scopes come from the roles of the user. When the
+// client requests api scopes, only those granted by the roles are returned.
+func grantScopes(roles []*model.Role, requested []string) []string {
+	granted := make([]string, 0)
+	requestsApiScopes := false
+	for _, scope := range requested {
+		switch scope {
+		case ScopeOpenId, ScopeProfile, ScopeEmail, ScopePhone:
+			granted = append(granted, scope)
+		default:
+			requestsApiScopes = true
+		}
+	}
+
+	for _, role := range roles {
+		for _, scope := range role.Scopes {
+			if slices.Contains(granted, scope) {
+				continue
+			}
+			if requestsApiScopes && !slices.Contains(requested, scope) {
+				continue
+			}
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// withoutScopes removes the excluded scopes, like the scopes which require multi-factor authentication.
+func withoutScopes(scopes []string, excluded []string) []string {
+	return slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+		return slices.Contains(excluded, scope)
+	})
+}
+
+func roleNames(roles []*model.Role) []string {
+	names := make([]string, 0)
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+func (h *TokenHandler) generateJwtToken(ctx context.Context, user *model.User, roles []*model.Role, scopes []string, authMethods []string) (string, error) {
 	tokenId := uuid.New().String()
+	now := time.Now().UTC()
 
 	claims := jwt.MapClaims{}
-	claims["iss"] = "https://localhost:8000"
-	claims["aud"] = "cloudbm"
-	claims["iat"] = jwt.NewNumericDate(time.Now().UTC())
-	claims["nbf"] = jwt.NewNumericDate(time.Now().UTC())
-	claims["exp"] = jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(TokenLifetimeSeconds) * time.Second))
+	claims["iss"] = h.options.Issuer
+	claims["aud"] = h.options.Audience
+	claims["iat"] = jwt.NewNumericDate(now)
+	claims["nbf"] = jwt.NewNumericDate(now)
+	claims["exp"] = jwt.NewNumericDate(now.Add(time.Duration(h.options.AccessTokenLifetimeSeconds) * time.Second))
 	claims["sub"] = user.Id
 	claims["name"] = user.Username
 	claims["email"] = user.Email
@@ -182,22 +398,85 @@ func (h *TokenHandler) generateJwtToken(user *model.User) (string, error) {
 	claims["phone"] = user.Phone
 	claims["phone_verified"] = user.PhoneVerified
 	claims["jti"] = tokenId
-	claims["role"] = "user admin"
-	claims["scope"] = "product:read product:write"
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(TokenSecret)
-	if err != nil {
-		return "", err
-	}
+	claims["role"] = strings.Join(roleNames(roles), " ")
+	claims["scope"] = strings.Join(scopes, " ")
+	claims["amr"] = authMethods
 
 	//TODO: We should store the token in the database, so we can revoke it if needed
 
 	//	/token/introspect
-	//	/token
 	//	/token/revoke
-	//	/userinfo
 	//	/logout
 
-	return tokenString, nil
+	signingKey, err := h.service.KeyManager().SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return signToken(signingKey, TokenTypeAccessToken, claims)
+}
+
+func (h *TokenHandler) generateIdToken(ctx context.Context, user *model.User, clientId string, scopes []string, authMethods []string, authTime time.Time, nonce string, accessToken string) (string, error) {
+	now := time.Now().UTC()
+
+	claims := jwt.MapClaims{}
+	claims["iss"] = h.options.Issuer
+	claims["aud"] = clientId
+	claims["iat"] = jwt.NewNumericDate(now)
+	claims["exp"] = jwt.NewNumericDate(now.Add(time.Duration(h.options.IdTokenLifetimeSeconds) * time.Second))
+	claims["auth_time"] = jwt.NewNumericDate(authTime)
+	claims["amr"] = authMethods
+	claims["sub"] = user.Id
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for key, value := range userClaims(user, scopes) {
+		claims[key] = value
+	}
+
+	signingKey, err := h.service.KeyManager().SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	claims["at_hash"] = accessTokenHash(signingKey.Algorithm, accessToken)
+
+	return signToken(signingKey, TokenTypeIdToken, claims)
+}
+
+func signToken(signingKey *security.SigningKey, tokenType string, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(signingKey.SigningMethod(), claims)
+	token.Header["kid"] = signingKey.Id
+	token.Header["typ"] = tokenType
+	return token.SignedString(signingKey.PrivateKey)
+}
+
+// userClaims returns the standard claims of the user which are released for the given scopes.
+func userClaims(user *model.User, scopes []string) map[string]any {
+	claims := make(map[string]any)
+	if slices.Contains(scopes, ScopeProfile) {
+		claims["name"] = user.Username
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if slices.Contains(scopes, ScopePhone) {
+		claims["phone"] = user.Phone
+		claims["phone_verified"] = user.PhoneVerified
+	}
+	return claims
+}
+
+// accessTokenHash computes the at_hash claim: the left half of the access token hash,
+// using the hash function of the signing algorithm.
+func accessTokenHash(algorithm string, accessToken string) string {
+	var hash []byte
+	switch algorithm {
+	case security.SigningAlgorithmEdDSA:
+		sum := sha512.Sum512([]byte(accessToken))
+		hash = sum[:]
+	default:
+		sum := sha256.Sum256([]byte(accessToken))
+		hash = sum[:]
+	}
+	return base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2])
 }
@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"testing"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantScopes(t *testing.T) {
+	roles := []*model.Role{
+		{Name: "editor", Scopes: []string{"product.read", "product.update"}},
+		{Name: "admin", Scopes: []string{"product.read", "user.delete"}},
+	}
+
+	tests := []struct {
+		requested []string
+		expected  []string
+	}{
+		{[]string{}, []string{"product.read", "product.update", "user.delete"}},
+		{[]string{"openid", "email"}, []string{"openid", "email", "product.read", "product.update", "user.delete"}},
+		{[]string{"openid", "product.read"}, []string{"openid", "product.read"}},
+		{[]string{"session.read"}, []string{}},
+	}
+
+	for _, test := range tests {
+		result := grantScopes(roles, test.requested)
+		assert.Equal(t, test.expected, result, "grantScopes(%v)", test.requested)
+	}
+}
+
+func TestWithoutScopes(t *testing.T) {
+	scopes := []string{"openid", "user.read", "user.delete"}
+
+	result := withoutScopes(scopes, []string{"user.delete"})
+	assert.Equal(t, []string{"openid", "user.read"}, result)
+	assert.Equal(t, []string{"openid", "user.read", "user.delete"}, scopes, "Input must not be modified")
+}
@@ -0,0 +1,42 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deb-ict/go-router/authentication"
+)
+
+func (h *TokenHandler) UserInfoEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	auth := authentication.GetContext(ctx)
+	subject := auth.GetClaimValue("sub", 0)
+	if subject == "" {
+		h.userInfoError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+
+	user, err := h.service.GetUserById(ctx, subject)
+	if err != nil {
+		h.userInfoError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+
+	response := userClaims(user, auth.GetScopes())
+	response["sub"] = user.Id
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *TokenHandler) userInfoError(w http.ResponseWriter, statusCode int, e string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="`+e+`"`)
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&ErrorResponse{Error: e})
+}
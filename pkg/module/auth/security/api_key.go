@@ -0,0 +1,38 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Api keys have the format cbm_<prefix>_<secret>. The prefix identifies the key, so it can be
+// looked up and shown to the user, only the hash of the full key is stored.
+const ApiKeyPrefix string = "cbm"
+
+func GenerateApiKey() (string, string, string, error) {
+	prefixData := make([]byte, 8)
+	_, err := rand.Read(prefixData)
+	if err != nil {
+		return "", "", "", err
+	}
+	secretData := make([]byte, 32)
+	_, err = rand.Read(secretData)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix := hex.EncodeToString(prefixData)
+	key := ApiKeyPrefix + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretData)
+	return key, prefix, HashUserToken(key), nil
+}
+
+// ParseApiKeyPrefix returns the prefix of the api key, or false when the key has an invalid format.
+func ParseApiKeyPrefix(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != ApiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,39 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateApiKey(t *testing.T) {
+	key, prefix, hash, err := GenerateApiKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "cbm_"+prefix+"_"))
+	assert.True(t, VerifyUserToken(key, hash))
+
+	parsed, ok := ParseApiKeyPrefix(key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, parsed)
+}
+
+func TestParseApiKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		valid  bool
+	}{
+		{"cbm_0a1b2c3d_c2VjcmV0_with_underscores", "0a1b2c3d", true},
+		{"cbm_0a1b2c3d_", "", false},
+		{"cbm__secret", "", false},
+		{"xyz_0a1b2c3d_secret", "", false},
+		{"eyJhbGciOiJSUzI1NiJ9.e30.c2ln", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		prefix, ok := ParseApiKeyPrefix(tt.key)
+		assert.Equal(t, tt.valid, ok, "Key: %s", tt.key)
+		assert.Equal(t, tt.prefix, prefix, "Key: %s", tt.key)
+	}
+}
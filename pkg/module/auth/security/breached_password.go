@@ -0,0 +1,111 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+)
+
+// Hashes are grouped by the first 5 hex characters of the hash, like the k-anonymity range api
+const breachedHashPrefixLength int = 5
+const breachedHashPrefixCount int = 1 << (4 * breachedHashPrefixLength)
+
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// breachedHashFile looks up passwords in a sorted file of SHA-1 hashes. An index with the file
+// offset of each hash prefix is built on first use, so only the lines of one prefix are read per lookup.
+type breachedHashFile struct {
+	path    string
+	once    sync.Once
+	offsets []int64
+	err     error
+}
+
+func NewBreachedHashFile(path string) BreachedPasswordChecker {
+	return &breachedHashFile{
+		path: core.FixUserFolder(path),
+	}
+}
+
+func (f *breachedHashFile) IsBreached(password string) (bool, error) {
+	f.once.Do(f.buildIndex)
+	if f.err != nil {
+		return false, f.err
+	}
+
+	hash := sha1.Sum([]byte(password))
+	hexHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+	prefix, _ := strconv.ParseUint(hexHash[:breachedHashPrefixLength], 16, 32)
+	suffix := hexHash[breachedHashPrefixLength:]
+
+	start := f.offsets[prefix]
+	end := f.offsets[prefix+1]
+	if start == end {
+		return false, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(io.NewSectionReader(file, start, end-start))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < len(hexHash) {
+			continue
+		}
+		if strings.EqualFold(line[breachedHashPrefixLength:len(hexHash)], suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func (f *breachedHashFile) buildIndex() {
+	file, err := os.Open(f.path)
+	if err != nil {
+		f.err = err
+		return
+	}
+	defer file.Close()
+
+	offsets := make([]int64, breachedHashPrefixCount+1)
+	next := 0
+	offset := int64(0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) >= breachedHashPrefixLength {
+			prefix, parseErr := strconv.ParseUint(line[:breachedHashPrefixLength], 16, 32)
+			if parseErr == nil {
+				// Every prefix up to this one starts at this line, the file is sorted
+				for ; next <= int(prefix); next++ {
+					offsets[next] = offset
+				}
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.err = err
+			return
+		}
+	}
+	for ; next <= breachedHashPrefixCount; next++ {
+		offsets[next] = offset
+	}
+	f.offsets = offsets
+}
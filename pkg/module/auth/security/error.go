@@ -0,0 +1,11 @@
+package security
+
+import "errors"
+
+var (
+	ErrSigningKeyNotFound       error = errors.New("signing key not found")
+	ErrSigningKeyInvalid        error = errors.New("signing key invalid")
+	ErrSigningAlgorithmInvalid  error = errors.New("signing algorithm not supported")
+	ErrSigningAlgorithmMismatch error = errors.New("signing algorithm does not match key")
+	ErrPasswordHashInvalid      error = errors.New("password hash invalid")
+)
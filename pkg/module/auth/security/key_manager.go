@@ -0,0 +1,243 @@
+package security
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DEFAULT_SIGNING_ALGORITHM   string = SigningAlgorithmRS256
+	DEFAULT_KEY_ROTATION_HOURS  int64  = 24 * 30
+	DEFAULT_KEY_RETENTION_HOURS int64  = 24
+)
+
+type KeyManager interface {
+	SigningKey(ctx context.Context) (*SigningKey, error)
+	VerificationKey(ctx context.Context, id string) (*SigningKey, error)
+	VerificationKeys(ctx context.Context) ([]*SigningKey, error)
+	RotateKeys(ctx context.Context) error
+	RotateDueKeys(ctx context.Context) error
+}
+
+type KeyManagerOptions struct {
+	Algorithm             string              `yaml:"algorithm"`
+	RotationIntervalHours int64               `yaml:"rotation_interval_hours"`
+	RetentionHours        int64               `yaml:"retention_hours"`
+	Keys                  []SigningKeyOptions `yaml:"keys"`
+}
+
+type SigningKeyOptions struct {
+	Id        string `yaml:"id"`
+	Algorithm string `yaml:"algorithm"`
+	File      string `yaml:"file"`
+	Pem       string `yaml:"pem"`
+}
+
+type keyManager struct {
+	mutex            sync.Mutex
+	algorithm        string
+	rotationInterval time.Duration
+	retention        time.Duration
+	activeKey        *SigningKey
+	previousKeys     []*SigningKey
+}
+
+func DefaultKeyManager() KeyManager {
+	// Without configured keys there is nothing to load, a failure to generate the key is retried on first use
+	opts := &KeyManagerOptions{}
+	m, err := NewKeyManager(opts)
+	if err != nil {
+		return newKeyManager(opts)
+	}
+	return m
+}
+
+// NewKeyManager loads the configured keys. The first configured key is used for signing,
+// the other keys are only used to verify tokens issued before they were replaced.
+// When no keys are configured, a signing key is generated which only lives as long as the process.
+func NewKeyManager(opts *KeyManagerOptions) (KeyManager, error) {
+	if opts == nil {
+		opts = &KeyManagerOptions{}
+	}
+	opts.EnsureDefaults()
+
+	m := newKeyManager(opts)
+
+	for i := range opts.Keys {
+		key, err := LoadSigningKey(&opts.Keys[i])
+		if err != nil {
+			slog.ErrorContext(context.Background(), "Failed to load signing key",
+				slog.String("id", opts.Keys[i].Id),
+				slog.String("file", opts.Keys[i].File),
+				slog.Any("error", err),
+			)
+			return nil, err
+		}
+		if m.activeKey == nil {
+			m.activeKey = key
+		} else {
+			m.previousKeys = append(m.previousKeys, key)
+		}
+	}
+
+	if m.activeKey == nil {
+		slog.WarnContext(context.Background(), "No signing keys configured, using a generated key. Tokens are rejected after a restart and by other instances")
+		err := m.rotate(context.Background())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func newKeyManager(opts *KeyManagerOptions) *keyManager {
+	return &keyManager{
+		algorithm:        opts.Algorithm,
+		rotationInterval: time.Duration(opts.RotationIntervalHours) * time.Hour,
+		retention:        time.Duration(opts.RetentionHours) * time.Hour,
+		previousKeys:     make([]*SigningKey, 0),
+	}
+}
+
+func KeyFunc(manager KeyManager) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		keyId, _ := token.Header["kid"].(string)
+		key, err := manager.VerificationKey(context.Background(), keyId)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrSigningAlgorithmMismatch
+		}
+		return key.PublicKey(), nil
+	}
+}
+
+func (m *keyManager) SigningKey(ctx context.Context) (*SigningKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err := m.ensureActiveKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.activeKey, nil
+}
+
+func (m *keyManager) VerificationKey(ctx context.Context, id string) (*SigningKey, error) {
+	keys, err := m.VerificationKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Id == id {
+			return key, nil
+		}
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+func (m *keyManager) VerificationKeys(ctx context.Context) ([]*SigningKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err := m.ensureActiveKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.prune()
+
+	keys := make([]*SigningKey, 0)
+	if m.activeKey != nil {
+		keys = append(keys, m.activeKey)
+	}
+	keys = append(keys, m.previousKeys...)
+	return keys, nil
+}
+
+func (m *keyManager) RotateKeys(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.rotate(ctx)
+}
+
+// RotateDueKeys replaces the signing key once the rotation interval has passed, it is run by a
+// background job so requests never wait for a key to be generated.
+func (m *keyManager) RotateDueKeys(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.activeKey != nil && !m.rotationDue() {
+		m.prune()
+		return nil
+	}
+	return m.rotate(ctx)
+}
+
+func (m *keyManager) ensureActiveKey(ctx context.Context) error {
+	if m.activeKey != nil {
+		return nil
+	}
+	return m.rotate(ctx)
+}
+
+func (m *keyManager) rotationDue() bool {
+	if m.rotationInterval <= 0 {
+		return false
+	}
+	return time.Now().UTC().After(m.activeKey.CreatedAt.Add(m.rotationInterval))
+}
+
+func (m *keyManager) rotate(ctx context.Context) error {
+	key, err := GenerateSigningKey(m.algorithm)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate signing key",
+			slog.String("algorithm", m.algorithm),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	if m.activeKey != nil {
+		m.activeKey.ExpiresAt = time.Now().UTC().Add(m.retention)
+		m.previousKeys = append([]*SigningKey{m.activeKey}, m.previousKeys...)
+	}
+	m.activeKey = key
+	m.prune()
+
+	logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Rotated signing key",
+		slog.String("kid", key.Id),
+		slog.String("algorithm", key.Algorithm),
+	)
+	return nil
+}
+
+func (m *keyManager) prune() {
+	keys := make([]*SigningKey, 0, len(m.previousKeys))
+	for _, key := range m.previousKeys {
+		if !key.HasExpired() {
+			keys = append(keys, key)
+		}
+	}
+	m.previousKeys = keys
+}
+
+func (opts *KeyManagerOptions) EnsureDefaults() {
+	if opts.Algorithm == "" {
+		opts.Algorithm = DEFAULT_SIGNING_ALGORITHM
+	}
+	if opts.RotationIntervalHours == 0 && len(opts.Keys) == 0 {
+		opts.RotationIntervalHours = DEFAULT_KEY_ROTATION_HOURS
+	}
+	if opts.RetentionHours <= 0 {
+		opts.RetentionHours = DEFAULT_KEY_RETENTION_HOURS
+	}
+}
@@ -0,0 +1,141 @@
+package security
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyManager_SignAndVerify(t *testing.T) {
+	algorithms := []string{
+		SigningAlgorithmRS256,
+		SigningAlgorithmES256,
+		SigningAlgorithmEdDSA,
+	}
+
+	for _, algorithm := range algorithms {
+		manager, err := NewKeyManager(&KeyManagerOptions{Algorithm: algorithm})
+		assert.NoError(t, err)
+
+		key, err := manager.SigningKey(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, algorithm, key.Algorithm)
+		assert.NotEmpty(t, key.Id)
+
+		token := jwt.NewWithClaims(key.SigningMethod(), jwt.MapClaims{"sub": "1234"})
+		token.Header["kid"] = key.Id
+		tokenString, err := token.SignedString(key.PrivateKey)
+		assert.NoError(t, err)
+
+		parsed, err := jwt.Parse(tokenString, KeyFunc(manager))
+		assert.NoError(t, err, "Token signed with %s should verify", algorithm)
+		assert.True(t, parsed.Valid)
+	}
+}
+
+func TestKeyManager_RotateKeys(t *testing.T) {
+	manager, err := NewKeyManager(&KeyManagerOptions{Algorithm: SigningAlgorithmES256})
+	assert.NoError(t, err)
+
+	previous, err := manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+
+	err = manager.RotateKeys(context.Background())
+	assert.NoError(t, err)
+
+	current, err := manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+	assert.NotEqual(t, previous.Id, current.Id, "Rotation should activate a new key")
+
+	key, err := manager.VerificationKey(context.Background(), previous.Id)
+	assert.NoError(t, err, "Previous key should remain valid for verification")
+	assert.Equal(t, previous.Id, key.Id)
+
+	previous.ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	_, err = manager.VerificationKey(context.Background(), previous.Id)
+	assert.Equal(t, ErrSigningKeyNotFound, err, "Expired key should be removed")
+}
+
+func TestKeyManager_RotateDueKeys(t *testing.T) {
+	manager, err := NewKeyManager(&KeyManagerOptions{Algorithm: SigningAlgorithmES256, RotationIntervalHours: 1})
+	assert.NoError(t, err)
+
+	previous, err := manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+
+	err = manager.RotateDueKeys(context.Background())
+	assert.NoError(t, err)
+	current, err := manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, previous.Id, current.Id, "Key should not rotate before the interval")
+
+	previous.CreatedAt = time.Now().UTC().Add(-2 * time.Hour)
+	current, err = manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, previous.Id, current.Id, "Requests should not rotate the key")
+
+	err = manager.RotateDueKeys(context.Background())
+	assert.NoError(t, err)
+	current, err = manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+	assert.NotEqual(t, previous.Id, current.Id, "Key should rotate after the interval")
+}
+
+func TestNewKeyManager_ConfiguredKeys(t *testing.T) {
+	first, err := GenerateSigningKey(SigningAlgorithmES256)
+	assert.NoError(t, err)
+	second, err := GenerateSigningKey(SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+
+	manager, err := NewKeyManager(&KeyManagerOptions{
+		Keys: []SigningKeyOptions{
+			{Id: "current", Pem: encodePrivateKeyPem(t, first)},
+			{Id: "previous", Pem: encodePrivateKeyPem(t, second)},
+		},
+	})
+	assert.NoError(t, err)
+
+	key, err := manager.SigningKey(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "current", key.Id)
+	assert.Equal(t, SigningAlgorithmES256, key.Algorithm)
+
+	keys, err := manager.VerificationKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestNewKeyManager_AlgorithmMismatch(t *testing.T) {
+	key, err := GenerateSigningKey(SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+
+	_, err = NewKeyManager(&KeyManagerOptions{
+		Keys: []SigningKeyOptions{
+			{Algorithm: SigningAlgorithmRS256, Pem: encodePrivateKeyPem(t, key)},
+		},
+	})
+	assert.Equal(t, ErrSigningAlgorithmMismatch, err)
+}
+
+func TestNewJSONWebKeySet(t *testing.T) {
+	key, err := GenerateSigningKey(SigningAlgorithmRS256)
+	assert.NoError(t, err)
+
+	set, err := NewJSONWebKeySet([]*SigningKey{key})
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "RSA", set.Keys[0].KeyType)
+	assert.Equal(t, key.Id, set.Keys[0].KeyId)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+}
+
+func encodePrivateKeyPem(t *testing.T, key *SigningKey) string {
+	data, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	assert.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}))
+}
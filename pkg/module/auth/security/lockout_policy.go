@@ -0,0 +1,60 @@
+package security
+
+import (
+	"time"
+)
+
+const (
+	DEFAULT_LOCKOUT_THRESHOLD            int32 = 5
+	DEFAULT_LOCKOUT_DURATION_SECONDS     int64 = 300
+	DEFAULT_LOCKOUT_BACKOFF_MULTIPLIER   int64 = 2
+	DEFAULT_LOCKOUT_MAX_DURATION_SECONDS int64 = 24 * 3600
+)
+
+type LockoutPolicy struct {
+	// Number of consecutive login failures before the user is locked. Zero uses the default, a
+	// negative value disables the lockout
+	Threshold int32 `yaml:"threshold"`
+	// Duration of the first lock
+	DurationSeconds int64 `yaml:"duration_seconds"`
+	// Each next lock lasts this many times longer than the previous one
+	BackoffMultiplier int64 `yaml:"backoff_multiplier"`
+	// Upper limit for the lock duration
+	MaxDurationSeconds int64 `yaml:"max_duration_seconds"`
+}
+
+// LockDuration returns how long the user must be locked after the given number of consecutive
+// login failures, or zero when the user should not be locked. The user is locked again each time
+// another threshold of failures is reached, with a longer duration every time.
+func (p *LockoutPolicy) LockDuration(failures int32) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold || failures%p.Threshold != 0 {
+		return 0
+	}
+
+	seconds := p.DurationSeconds
+	for i := int32(1); i < failures/p.Threshold; i++ {
+		seconds *= p.BackoffMultiplier
+		if seconds >= p.MaxDurationSeconds {
+			break
+		}
+	}
+	if seconds > p.MaxDurationSeconds {
+		seconds = p.MaxDurationSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (p *LockoutPolicy) EnsureDefaults() {
+	if p.Threshold == 0 {
+		p.Threshold = DEFAULT_LOCKOUT_THRESHOLD
+	}
+	if p.DurationSeconds <= 0 {
+		p.DurationSeconds = DEFAULT_LOCKOUT_DURATION_SECONDS
+	}
+	if p.BackoffMultiplier <= 0 {
+		p.BackoffMultiplier = DEFAULT_LOCKOUT_BACKOFF_MULTIPLIER
+	}
+	if p.MaxDurationSeconds <= 0 {
+		p.MaxDurationSeconds = DEFAULT_LOCKOUT_MAX_DURATION_SECONDS
+	}
+}
@@ -0,0 +1,41 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy_LockDuration(t *testing.T) {
+	policy := &LockoutPolicy{
+		Threshold:          3,
+		DurationSeconds:    60,
+		BackoffMultiplier:  2,
+		MaxDurationSeconds: 300,
+	}
+
+	tests := []struct {
+		failures int32
+		expected time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 0},
+		{6, 2 * time.Minute},
+		{9, 4 * time.Minute},
+		{12, 5 * time.Minute},
+		{300, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, policy.LockDuration(tt.failures), "Failures: %d", tt.failures)
+	}
+}
+
+func TestLockoutPolicy_Disabled(t *testing.T) {
+	policy := &LockoutPolicy{Threshold: -1}
+	policy.EnsureDefaults()
+
+	assert.Equal(t, time.Duration(0), policy.LockDuration(100))
+}
@@ -1,27 +1,228 @@
 package security
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PasswordHashAlgorithmArgon2id string = "argon2id"
+	PasswordHashAlgorithmBcrypt   string = "bcrypt"
+)
+
+// Defaults follow the OWASP password storage recommendations
+const (
+	DEFAULT_PASSWORD_HASH_ALGORITHM string = PasswordHashAlgorithmArgon2id
+	DEFAULT_ARGON2_MEMORY_KIB       uint32 = 19 * 1024
+	DEFAULT_ARGON2_ITERATIONS       uint32 = 2
+	DEFAULT_ARGON2_PARALLELISM      uint8  = 1
+	DEFAULT_ARGON2_SALT_LENGTH      uint32 = 16
+	DEFAULT_ARGON2_KEY_LENGTH       uint32 = 32
+	DEFAULT_BCRYPT_COST             int    = 12
+
+	// bcrypt refuses passwords longer than 72 bytes
+	BCRYPT_MAX_PASSWORD_LENGTH int = 72
+)
+
 type PasswordHasher interface {
 	HashPassword(password string) (string, error)
 	VerifyPassword(password string, hash string) bool
+	// NeedsRehash reports whether the hash was made with another algorithm or weaker parameters
+	// than currently configured, so it should be replaced after the next successful login.
+	NeedsRehash(hash string) bool
+}
+
+type PasswordHasherOptions struct {
+	Algorithm string        `yaml:"algorithm"`
+	Argon2id  Argon2Options `yaml:"argon2id"`
+	Bcrypt    BcryptOptions `yaml:"bcrypt"`
+}
+
+type Argon2Options struct {
+	MemoryKiB   uint32 `yaml:"memory_kib"`
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+	SaltLength  uint32 `yaml:"salt_length"`
+	KeyLength   uint32 `yaml:"key_length"`
+}
+
+type BcryptOptions struct {
+	Cost int `yaml:"cost"`
 }
 
-type defaultPasswordHasher struct {
+type argon2idPasswordHasher struct {
+	options Argon2Options
+}
+
+type bcryptPasswordHasher struct {
+	options BcryptOptions
 }
 
 func DefaultPasswordHasher() PasswordHasher {
-	return &defaultPasswordHasher{}
+	return NewPasswordHasher(&PasswordHasherOptions{})
+}
+
+// NewPasswordHasher returns the hasher for the configured algorithm. Both hashers can verify
+// the hashes of the other algorithm, so the algorithm can be changed without resetting passwords.
+func NewPasswordHasher(opts *PasswordHasherOptions) PasswordHasher {
+	if opts == nil {
+		opts = &PasswordHasherOptions{}
+	}
+	opts.EnsureDefaults()
+
+	switch opts.Algorithm {
+	case PasswordHashAlgorithmBcrypt:
+		return NewBcryptPasswordHasher(&opts.Bcrypt)
+	default:
+		return NewArgon2idPasswordHasher(&opts.Argon2id)
+	}
 }
 
-func (h *defaultPasswordHasher) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 8)
+func NewArgon2idPasswordHasher(opts *Argon2Options) PasswordHasher {
+	opts.EnsureDefaults()
+	return &argon2idPasswordHasher{
+		options: *opts,
+	}
+}
+
+func NewBcryptPasswordHasher(opts *BcryptOptions) PasswordHasher {
+	opts.EnsureDefaults()
+	return &bcryptPasswordHasher{
+		options: *opts,
+	}
+}
+
+// HashPassword returns the hash in the PHC string format: $argon2id$v=19$m=<kib>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func (h *argon2idPasswordHasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, h.options.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.options.Iterations, h.options.MemoryKiB, h.options.Parallelism, h.options.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.options.MemoryKiB,
+		h.options.Iterations,
+		h.options.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idPasswordHasher) VerifyPassword(password string, hash string) bool {
+	return verifyPasswordHash(password, hash)
+}
+
+func (h *argon2idPasswordHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return params.MemoryKiB < h.options.MemoryKiB ||
+		params.Iterations < h.options.Iterations ||
+		params.Parallelism < h.options.Parallelism ||
+		uint32(len(key)) < h.options.KeyLength
+}
+
+func (h *bcryptPasswordHasher) HashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.options.Cost)
 	return string(bytes), err
 }
 
-func (h *defaultPasswordHasher) VerifyPassword(password string, hash string) bool {
+func (h *bcryptPasswordHasher) VerifyPassword(password string, hash string) bool {
+	return verifyPasswordHash(password, hash)
+}
+
+func (h *bcryptPasswordHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < h.options.Cost
+}
+
+func verifyPasswordHash(password string, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func parseArgon2idHash(hash string) (*Argon2Options, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashAlgorithmArgon2id {
+		return nil, nil, nil, ErrPasswordHashInvalid
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrPasswordHashInvalid
+	}
+
+	params := &Argon2Options{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, nil, nil, ErrPasswordHashInvalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrPasswordHashInvalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrPasswordHashInvalid
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func (opts *PasswordHasherOptions) EnsureDefaults() {
+	if opts.Algorithm == "" {
+		opts.Algorithm = DEFAULT_PASSWORD_HASH_ALGORITHM
+	}
+	opts.Argon2id.EnsureDefaults()
+	opts.Bcrypt.EnsureDefaults()
+}
+
+func (opts *Argon2Options) EnsureDefaults() {
+	if opts.MemoryKiB == 0 {
+		opts.MemoryKiB = DEFAULT_ARGON2_MEMORY_KIB
+	}
+	if opts.Iterations == 0 {
+		opts.Iterations = DEFAULT_ARGON2_ITERATIONS
+	}
+	if opts.Parallelism == 0 {
+		opts.Parallelism = DEFAULT_ARGON2_PARALLELISM
+	}
+	if opts.SaltLength == 0 {
+		opts.SaltLength = DEFAULT_ARGON2_SALT_LENGTH
+	}
+	if opts.KeyLength == 0 {
+		opts.KeyLength = DEFAULT_ARGON2_KEY_LENGTH
+	}
+}
+
+func (opts *BcryptOptions) EnsureDefaults() {
+	if opts.Cost < bcrypt.MinCost {
+		opts.Cost = DEFAULT_BCRYPT_COST
+	}
+}
@@ -1 +1,72 @@
 package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Options = Argon2Options{MemoryKiB: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idPasswordHasher(t *testing.T) {
+	opts := testArgon2Options
+	hasher := NewArgon2idPasswordHasher(&opts)
+
+	hash, err := hasher.HashPassword("secret-password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	assert.True(t, hasher.VerifyPassword("secret-password", hash))
+	assert.False(t, hasher.VerifyPassword("other-password", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	other, err := hasher.HashPassword("secret-password")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other, "Hashes must be salted")
+}
+
+func TestArgon2idPasswordHasher_NeedsRehash(t *testing.T) {
+	weakOpts := testArgon2Options
+	weakHash, err := NewArgon2idPasswordHasher(&weakOpts).HashPassword("secret-password")
+	assert.NoError(t, err)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	strongOpts := testArgon2Options
+	strongOpts.Iterations = 2
+	hasher := NewArgon2idPasswordHasher(&strongOpts)
+
+	assert.True(t, hasher.NeedsRehash(weakHash), "Weaker parameters need a rehash")
+	assert.True(t, hasher.NeedsRehash(string(bcryptHash)), "Other algorithms need a rehash")
+	assert.True(t, hasher.VerifyPassword("secret-password", weakHash))
+	assert.True(t, hasher.VerifyPassword("secret-password", string(bcryptHash)), "Legacy bcrypt hashes must still verify")
+}
+
+func TestBcryptPasswordHasher(t *testing.T) {
+	hasher := NewBcryptPasswordHasher(&BcryptOptions{Cost: bcrypt.MinCost + 1})
+
+	hash, err := hasher.HashPassword("secret-password")
+	assert.NoError(t, err)
+	assert.True(t, hasher.VerifyPassword("secret-password", hash))
+	assert.False(t, hasher.VerifyPassword("other-password", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	weakHash, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.True(t, hasher.NeedsRehash(string(weakHash)))
+}
+
+func TestVerifyPasswordHash_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"plain-text",
+		"$argon2id$v=19$m=1024,t=1,p=1$invalid",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+	}
+	for _, hash := range tests {
+		assert.False(t, verifyPasswordHash("secret-password", hash), "Hash: %s", hash)
+	}
+}
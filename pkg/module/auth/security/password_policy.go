@@ -0,0 +1,140 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DEFAULT_PASSWORD_MIN_LENGTH int = 8
+	// Long enough for passphrases, while bounding the input of the password hash. Lowered to
+	// BCRYPT_MAX_PASSWORD_LENGTH when passwords are hashed with bcrypt.
+	DEFAULT_PASSWORD_MAX_LENGTH int = 128
+)
+
+type PasswordPolicy interface {
+	// ValidatePassword checks the password against the policy. The user inputs, like the username
+	// and email, must not be part of the password. A *PasswordPolicyError is returned on violations.
+	ValidatePassword(password string, userInputs ...string) error
+}
+
+type PasswordPolicyOptions struct {
+	MinLength        int  `yaml:"min_length"`
+	MaxLength        int  `yaml:"max_length"`
+	RequireUppercase bool `yaml:"require_uppercase"`
+	RequireLowercase bool `yaml:"require_lowercase"`
+	RequireDigit     bool `yaml:"require_digit"`
+	RequireSymbol    bool `yaml:"require_symbol"`
+	// Sorted file with the uppercase SHA-1 hashes of compromised passwords, one HASH[:COUNT] per line
+	BreachedHashFile string `yaml:"breached_hash_file"`
+}
+
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+type passwordPolicy struct {
+	options  PasswordPolicyOptions
+	breached BreachedPasswordChecker
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return NewPasswordPolicy(&PasswordPolicyOptions{})
+}
+
+func NewPasswordPolicy(opts *PasswordPolicyOptions) PasswordPolicy {
+	if opts == nil {
+		opts = &PasswordPolicyOptions{}
+	}
+	opts.EnsureDefaults()
+
+	p := &passwordPolicy{
+		options: *opts,
+	}
+	if opts.BreachedHashFile != "" {
+		p.breached = NewBreachedHashFile(opts.BreachedHashFile)
+	}
+	return p
+}
+
+func (p *passwordPolicy) ValidatePassword(password string, userInputs ...string) error {
+	violations := make([]string, 0)
+
+	length := utf8.RuneCountInString(password)
+	if length < p.options.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.options.MinLength))
+	}
+	if len(password) > p.options.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long", p.options.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.options.RequireUppercase && !hasUpper {
+		violations = append(violations, "password must contain an uppercase letter")
+	}
+	if p.options.RequireLowercase && !hasLower {
+		violations = append(violations, "password must contain a lowercase letter")
+	}
+	if p.options.RequireDigit && !hasDigit {
+		violations = append(violations, "password must contain a digit")
+	}
+	if p.options.RequireSymbol && !hasSymbol {
+		violations = append(violations, "password must contain a symbol")
+	}
+
+	lowerPassword := strings.ToLower(password)
+	for _, input := range userInputs {
+		if len(input) >= 3 && strings.Contains(lowerPassword, strings.ToLower(input)) {
+			violations = append(violations, "password must not contain the username or email")
+			break
+		}
+	}
+
+	if p.breached != nil && length >= p.options.MinLength {
+		breached, err := p.breached.IsBreached(password)
+		if err != nil {
+			// An unavailable breach list must not block all password changes
+			slog.WarnContext(context.Background(), "Failed to check password against breached passwords",
+				slog.Any("error", err),
+			)
+		} else if breached {
+			violations = append(violations, "password appears in a list of compromised passwords")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{
+			Violations: violations,
+		}
+	}
+	return nil
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the password policy"
+}
+
+func (opts *PasswordPolicyOptions) EnsureDefaults() {
+	if opts.MinLength <= 0 {
+		opts.MinLength = DEFAULT_PASSWORD_MIN_LENGTH
+	}
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = DEFAULT_PASSWORD_MAX_LENGTH
+	}
+}
@@ -0,0 +1,83 @@
+package security
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_ValidatePassword(t *testing.T) {
+	policy := NewPasswordPolicy(&PasswordPolicyOptions{
+		MinLength:        10,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+	})
+
+	tests := []struct {
+		name       string
+		password   string
+		violations int
+	}{
+		{"Valid", "Correct-Horse-9", 0},
+		{"TooShort", "Sh0rt-pw", 1},
+		{"NoUppercase", "correct-horse-9", 1},
+		{"NoLowercase", "CORRECT-HORSE-9", 1},
+		{"NoDigit", "Correct-Horse-X", 1},
+		{"NoSymbol", "CorrectHorse99", 1},
+		{"ContainsUsername", "Johnny-Horse-9", 1},
+		{"Empty", "", 5},
+		{"Passphrase", "Aa1-" + strings.Repeat("x", 100), 0},
+		{"TooLong", "Aa1-" + strings.Repeat("x", 130), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.ValidatePassword(tt.password, "johnny", "john@example.com")
+			if tt.violations == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			policyErr, ok := err.(*PasswordPolicyError)
+			if assert.True(t, ok, "Expected a PasswordPolicyError") {
+				assert.Len(t, policyErr.Violations, tt.violations, "Violations: %v", policyErr.Violations)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_BreachedPassword(t *testing.T) {
+	breached := []string{"password123", "letmein-now", "qwertyuiop"}
+	lines := make([]string, 0)
+	for i, password := range breached {
+		hash := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(hash[:]))+":"+string(rune('1'+i)))
+	}
+	lines = append(lines, "0000000000000000000000000000000000000000:1", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1")
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600)
+	assert.NoError(t, err)
+
+	policy := NewPasswordPolicy(&PasswordPolicyOptions{BreachedHashFile: path})
+	for _, password := range breached {
+		assert.Error(t, policy.ValidatePassword(password), "Password %s should be breached", password)
+	}
+	assert.NoError(t, policy.ValidatePassword("not-in-the-list"))
+}
+
+func TestPasswordPolicy_MissingBreachedHashFile(t *testing.T) {
+	policy := NewPasswordPolicy(&PasswordPolicyOptions{
+		BreachedHashFile: filepath.Join(t.TempDir(), "missing.txt"),
+	})
+
+	assert.NoError(t, policy.ValidatePassword("password123"))
+}
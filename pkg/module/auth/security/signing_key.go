@@ -0,0 +1,169 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	SigningAlgorithmRS256 string = "RS256"
+	SigningAlgorithmES256 string = "ES256"
+	SigningAlgorithmEdDSA string = "EdDSA"
+)
+
+type SigningKey struct {
+	Id         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+
+	switch algorithm {
+	case SigningAlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case SigningAlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case SigningAlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrSigningAlgorithmInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey("", algorithm, privateKey)
+}
+
+func LoadSigningKey(opts *SigningKeyOptions) (*SigningKey, error) {
+	data := []byte(opts.Pem)
+	if opts.File != "" {
+		fileData, err := os.ReadFile(core.FixUserFolder(opts.File))
+		if err != nil {
+			return nil, err
+		}
+		data = fileData
+	}
+
+	privateKey, err := ParsePrivateKeyPem(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(opts.Id, opts.Algorithm, privateKey)
+}
+
+func NewSigningKey(id string, algorithm string, privateKey crypto.Signer) (*SigningKey, error) {
+	keyAlgorithm, err := signingAlgorithmForKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm == "" {
+		algorithm = keyAlgorithm
+	}
+	if algorithm != keyAlgorithm {
+		return nil, ErrSigningAlgorithmMismatch
+	}
+
+	key := &SigningKey{
+		Id:         id,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if key.Id == "" {
+		key.Id, err = key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func ParsePrivateKeyPem(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrSigningKeyInvalid
+	}
+
+	var privateKey any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, ErrSigningKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, ErrSigningKeyInvalid
+	}
+	return signer, nil
+}
+
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+func (k *SigningKey) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *SigningKey) HasExpired() bool {
+	if k.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().UTC().After(k.ExpiresAt)
+}
+
+func (k *SigningKey) Thumbprint() (string, error) {
+	jwk, err := NewJSONWebKey(k)
+	if err != nil {
+		return "", err
+	}
+	return jwk.Thumbprint()
+}
+
+func signingAlgorithmForKey(privateKey crypto.Signer) (string, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return "", ErrSigningKeyInvalid
+		}
+		return SigningAlgorithmRS256, nil
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", ErrSigningAlgorithmInvalid
+		}
+		return SigningAlgorithmES256, nil
+	case ed25519.PrivateKey:
+		return SigningAlgorithmEdDSA, nil
+	default:
+		return "", ErrSigningAlgorithmInvalid
+	}
+}
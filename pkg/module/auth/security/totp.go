@@ -0,0 +1,111 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TotpDigits        int   = 6
+	TotpPeriodSeconds int64 = 30
+	TotpSecretSize    int   = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret returns a new random base32 encoded secret.
+func GenerateTotpSecret() (string, error) {
+	data := make([]byte, TotpSecretSize)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(data), nil
+}
+
+// TotpCounter returns the RFC 6238 time step for the given time.
+func TotpCounter(t time.Time) int64 {
+	return t.Unix() / TotpPeriodSeconds
+}
+
+// TotpCode computes the RFC 4226 code for the given counter.
+func TotpCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TotpDigits, value%1000000), nil
+}
+
+// ValidateTotp checks the code against the time steps around the given time, allowing the given
+// number of steps of clock skew. Codes for counters up to lastCounter are rejected, so a code
+// can only be used once. The matching counter is returned.
+func ValidateTotp(secret string, code string, t time.Time, skew int64, lastCounter int64) (int64, bool) {
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+
+	current := TotpCounter(t)
+	for counter := current - skew; counter <= current+skew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		expected, err := TotpCode(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// TotpProvisioningUri returns the otpauth uri used by authenticator apps, usually shown as QR code.
+func TotpProvisioningUri(issuer string, accountName string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TotpDigits))
+	query.Set("period", fmt.Sprint(TotpPeriodSeconds))
+
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenerateRecoveryCodes returns single-use codes to sign in when the authenticator is lost,
+// together with the hashes to store.
+func GenerateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		data := make([]byte, 5)
+		_, err := rand.Read(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(totpEncoding.EncodeToString(data))
+		code := encoded[:4] + "-" + encoded[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashUserToken(code))
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,76 @@
+package security
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotpCode_Rfc6238(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		time     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := TotpCode(secret, TotpCounter(time.Unix(tt.time, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, code, "Time: %d", tt.time)
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	secret, err := GenerateTotpSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	counter := TotpCounter(now)
+	code, err := TotpCode(secret, counter)
+	assert.NoError(t, err)
+
+	matched, ok := ValidateTotp(secret, code, now, 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, counter, matched)
+
+	_, ok = ValidateTotp(secret, code, now, 1, counter)
+	assert.False(t, ok, "Code must not be accepted twice")
+
+	_, ok = ValidateTotp(secret, code, now.Add(5*time.Minute), 1, 0)
+	assert.False(t, ok, "Code must not be accepted outside the skew window")
+
+	previous, err := TotpCode(secret, counter-1)
+	assert.NoError(t, err)
+	_, ok = ValidateTotp(secret, previous, now, 1, 0)
+	assert.True(t, ok, "Code of the previous step must be accepted")
+}
+
+func TestTotpProvisioningUri(t *testing.T) {
+	uri := TotpProvisioningUri("CloudBM", "john@example.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/CloudBM:john@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=CloudBM")
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, hashes, 10)
+	for i, code := range codes {
+		assert.Len(t, code, 9)
+		assert.True(t, VerifyUserToken(code, hashes[i]))
+	}
+}
@@ -0,0 +1,59 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+// GenerateUserToken returns a random token to send to the user, together with the hash to store.
+func GenerateUserToken() (string, string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	return token, HashUserToken(token), nil
+}
+
+// GenerateVerificationCode returns a random numeric code with the given number of digits, short
+// enough to type over from a text message, together with the hash to store.
+func GenerateVerificationCode(digits int, key []byte) (string, string, error) {
+	var builder strings.Builder
+	for i := 0; i < digits; i++ {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", "", err
+		}
+		builder.WriteByte(byte('0' + digit.Int64()))
+	}
+	code := builder.String()
+	return code, HashVerificationCode(code, key), nil
+}
+
+func HashUserToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func VerifyUserToken(token string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashUserToken(token)), []byte(hash)) == 1
+}
+
+// HashVerificationCode hashes the code with a secret key. A short code could be found from a plain
+// hash by trying every possible code, without the key the hash is useless.
+func HashVerificationCode(code string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyVerificationCode(code string, hash string, key []byte) bool {
+	return subtle.ConstantTimeCompare([]byte(HashVerificationCode(code, key)), []byte(hash)) == 1
+}
@@ -0,0 +1,34 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserToken(t *testing.T) {
+	token, hash, err := GenerateUserToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, token, hash)
+	assert.True(t, VerifyUserToken(token, hash))
+	assert.False(t, VerifyUserToken(token+"x", hash))
+
+	other, _, err := GenerateUserToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestVerificationCode(t *testing.T) {
+	key := []byte("test key")
+	tests := []int{4, 6, 8}
+	for _, digits := range tests {
+		code, hash, err := GenerateVerificationCode(digits, key)
+		assert.NoError(t, err)
+		assert.Len(t, code, digits)
+		assert.Regexp(t, "^[0-9]+$", code)
+		assert.True(t, VerifyVerificationCode(code, hash, key))
+		assert.False(t, VerifyVerificationCode(code, hash, []byte("other key")), "Hash should depend on the key")
+		assert.NotEqual(t, HashUserToken(code), hash, "Code should not be stored as a plain hash")
+	}
+}
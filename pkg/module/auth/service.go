@@ -13,17 +13,66 @@ import (
 type Service interface {
 	UserNormalizer() util.UserNormalizer
 	PasswordHasher() security.PasswordHasher
+	PasswordPolicy() security.PasswordPolicy
+	KeyManager() security.KeyManager
 	FeatureProvider() core.FeatureProvider
 
 	GetUsers(ctx context.Context, offset int64, limit int64, filter *model.UserFilter, sort *core.Sort) ([]*model.User, int64, error)
 	GetUserById(ctx context.Context, id string) (*model.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
-	CreateUser(ctx context.Context, model *model.User) (*model.User, error)
+	CreateUser(ctx context.Context, model *model.User, password string) (*model.User, error)
 	UpdateUser(ctx context.Context, id string, model *model.User) (*model.User, error)
 	DeleteUser(ctx context.Context, id string) error
 
 	LockUser(ctx context.Context, user *model.User, duration time.Duration) (*model.User, error)
 	UnlockUser(ctx context.Context, user *model.User) (*model.User, error)
 	VerifyPassword(ctx context.Context, user *model.User, password string) error
+	SetPassword(ctx context.Context, userId string, password string) error
+	ChangePassword(ctx context.Context, userId string, currentPassword string, newPassword string) error
+	ChangeEmail(ctx context.Context, userId string, currentPassword string, email string) (*model.User, error)
+	ChangePhone(ctx context.Context, userId string, phone string) (*model.User, error)
+
+	SendActivation(ctx context.Context, userId string) error
+	ActivateUser(ctx context.Context, userId string, token string) (*model.User, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, userId string, token string, password string) error
+
+	EnrollTotp(ctx context.Context, userId string) (*model.TotpEnrollment, error)
+	ConfirmTotp(ctx context.Context, userId string, code string) ([]string, error)
+	DisableTotp(ctx context.Context, userId string, code string) error
+	ResetMfa(ctx context.Context, userId string) error
+	VerifyOtp(ctx context.Context, user *model.User, code string) error
+
+	GetRefreshTokens(ctx context.Context, offset int64, limit int64, filter *model.RefreshTokenFilter, sort *core.Sort) ([]*model.RefreshToken, int64, error)
+	CreateRefreshToken(ctx context.Context, model *model.RefreshToken) (string, *model.RefreshToken, error)
+	RedeemRefreshToken(ctx context.Context, token string, clientId string) (*model.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, userId string, id string) error
+	RevokeUserRefreshTokens(ctx context.Context, userId string) error
+
+	SendEmailVerification(ctx context.Context, userId string) error
+	VerifyEmail(ctx context.Context, userId string, code string) (*model.User, error)
+	SendPhoneVerification(ctx context.Context, userId string) error
+	VerifyPhone(ctx context.Context, userId string, code string) (*model.User, error)
+
+	GetApiKeys(ctx context.Context, offset int64, limit int64, filter *model.ApiKeyFilter, sort *core.Sort) ([]*model.ApiKey, int64, error)
+	GetApiKeyById(ctx context.Context, id string) (*model.ApiKey, error)
+	CreateApiKey(ctx context.Context, model *model.ApiKey) (string, *model.ApiKey, error)
+	DeleteApiKey(ctx context.Context, id string) error
+	RevokeUserApiKey(ctx context.Context, userId string, id string) error
+	ValidateApiKey(ctx context.Context, key string) (*model.ApiKey, error)
+
+	GetAuditEvents(ctx context.Context, offset int64, limit int64, filter *model.AuditEventFilter, sort *core.Sort) ([]*model.AuditEvent, int64, error)
+	CreateAuditEvent(ctx context.Context, model *model.AuditEvent) error
+
+	GetRoles(ctx context.Context, offset int64, limit int64, filter *model.RoleFilter, sort *core.Sort) ([]*model.Role, int64, error)
+	GetRoleById(ctx context.Context, id string) (*model.Role, error)
+	GetRoleByName(ctx context.Context, name string) (*model.Role, error)
+	CreateRole(ctx context.Context, model *model.Role) (*model.Role, error)
+	UpdateRole(ctx context.Context, id string, model *model.Role) (*model.Role, error)
+	DeleteRole(ctx context.Context, id string) error
+
+	GetUserRoles(ctx context.Context, userId string) ([]*model.Role, error)
+	AddUserRole(ctx context.Context, userId string, roleId string) (*model.User, error)
+	RemoveUserRole(ctx context.Context, userId string, roleId string) (*model.User, error)
 }
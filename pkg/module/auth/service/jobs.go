@@ -0,0 +1,21 @@
+package service
+
+import (
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/hosting"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+)
+
+// Rotation intervals are configured in hours, checking every hour is precise enough
+const keyRotationCheckInterval time.Duration = time.Hour
+
+// RegisterJobs registers the periodic jobs of the auth module.
+func RegisterJobs(registry hosting.JobRegistry, keyManager security.KeyManager) error {
+	return registry.RegisterJob(&hosting.Job{
+		Name:     "auth.rotate_signing_keys",
+		Schedule: hosting.Every(keyRotationCheckInterval),
+		Jitter:   time.Minute,
+		Run:      keyManager.RotateDueKeys,
+	})
+}
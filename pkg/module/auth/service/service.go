@@ -1,22 +1,119 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"os"
+
 	"github.com/deb-ict/cloudbm-community/pkg/core"
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
 	"github.com/deb-ict/cloudbm-community/pkg/module/auth/util"
+	"github.com/deb-ict/cloudbm-community/pkg/notification"
+)
+
+const (
+	DEFAULT_ACTIVATION_LIFETIME_HOURS       int64  = 72
+	DEFAULT_PASSWORD_RESET_LIFETIME_MINUTES int64  = 60
+	DEFAULT_REFRESH_TOKEN_LIFETIME_HOURS    int64  = 24 * 30
+	DEFAULT_MFA_ISSUER                      string = "CloudBM"
+	DEFAULT_MFA_SKEW_STEPS                  int64  = 1
+	DEFAULT_MFA_RECOVERY_CODE_COUNT         int    = 10
+	DEFAULT_ACTIVATION_URL                  string = "https://localhost:8000/activate"
+	DEFAULT_PASSWORD_RESET_URL              string = "https://localhost:8000/password/reset"
+	DEFAULT_VERIFICATION_CODE_LENGTH        int    = 6
+	DEFAULT_EMAIL_CODE_LIFETIME_MINUTES     int64  = 30
+	DEFAULT_PHONE_CODE_LIFETIME_MINUTES     int64  = 10
+	DEFAULT_VERIFICATION_RESEND_SECONDS     int64  = 60
+	DEFAULT_VERIFICATION_MAX_ATTEMPTS       int    = 5
+	DEFAULT_VERIFICATION_MAX_CODES_PER_DAY  int    = 10
+	DEFAULT_VERIFICATION_MAX_FAILED_PER_DAY int    = 20
+	DEFAULT_EMAIL_VERIFICATION_URL          string = "https://localhost:8000/verify/email"
 )
 
 type ServiceOptions struct {
 	FeatureProvider core.FeatureProvider
 	UserNormalizer  util.UserNormalizer
 	PasswordHasher  security.PasswordHasher
+	PasswordPolicy  security.PasswordPolicy
+	KeyManager      security.KeyManager
+	EmailSender     notification.EmailSender
+	SmsSender       notification.SmsSender
+	SigningKeys     security.KeyManagerOptions     `yaml:"signing_keys"`
+	UserTokens      UserTokenOptions               `yaml:"user_tokens"`
+	Verification    VerificationOptions            `yaml:"verification"`
+	RefreshTokens   RefreshTokenOptions            `yaml:"refresh_tokens"`
+	Lockout         security.LockoutPolicy         `yaml:"lockout"`
+	Mfa             MfaOptions                     `yaml:"mfa"`
+	ApiKeys         ApiKeyOptions                  `yaml:"api_keys"`
+	PasswordRules   security.PasswordPolicyOptions `yaml:"password_policy"`
+	PasswordHashing security.PasswordHasherOptions `yaml:"password_hashing"`
+	Smtp            notification.SmtpOptions       `yaml:"smtp"`
+	Outbox          notification.OutboxOptions     `yaml:"outbox"`
+}
+
+type UserTokenOptions struct {
+	ActivationLifetimeHours      int64  `yaml:"activation_lifetime_hours"`
+	PasswordResetLifetimeMinutes int64  `yaml:"password_reset_lifetime_minutes"`
+	ActivationUrl                string `yaml:"activation_url"`
+	PasswordResetUrl             string `yaml:"password_reset_url"`
+}
+
+type VerificationOptions struct {
+	CodeLength               int   `yaml:"code_length"`
+	EmailCodeLifetimeMinutes int64 `yaml:"email_code_lifetime_minutes"`
+	PhoneCodeLifetimeMinutes int64 `yaml:"phone_code_lifetime_minutes"`
+	ResendIntervalSeconds    int64 `yaml:"resend_interval_seconds"`
+	MaxAttempts              int   `yaml:"max_attempts"`
+	// Limits the codes sent to a user, and the wrong email or phone codes entered, within the last 24 hours
+	MaxCodesPerDay       int    `yaml:"max_codes_per_day"`
+	MaxFailedPerDay      int    `yaml:"max_failed_per_day"`
+	EmailVerificationUrl string `yaml:"email_verification_url"`
+	// Key to hash the codes, read from VERIFICATION_CODE_HASH_KEY. A random key is used when empty, codes
+	// are then only valid on this instance until it restarts
+	CodeHashKey string `yaml:"code_hash_key"`
+}
+
+type RefreshTokenOptions struct {
+	LifetimeHours int64 `yaml:"lifetime_hours"`
+}
+
+type MfaOptions struct {
+	// Issuer shown in the authenticator app
+	Issuer string `yaml:"issuer"`
+	// Number of time steps a code may be off, to allow for clock skew
+	SkewSteps         int64 `yaml:"skew_steps"`
+	RecoveryCodeCount int   `yaml:"recovery_code_count"`
+}
+
+type ApiKeyOptions struct {
+	// Clients which can receive api keys, keys of other clients are refused
+	Clients []ApiKeyClientOptions `yaml:"clients"`
+}
+
+type ApiKeyClientOptions struct {
+	ClientId string `yaml:"client_id"`
+	// Scopes the keys of the client can grant
+	Scopes []string `yaml:"scopes"`
 }
 
 type service struct {
 	featureProvider core.FeatureProvider
 	userNormalizer  util.UserNormalizer
 	passwordHasher  security.PasswordHasher
+	passwordPolicy  security.PasswordPolicy
+	keyManager      security.KeyManager
+	emailSender     notification.EmailSender
+	smsSender       notification.SmsSender
+	userTokens      UserTokenOptions
+	verification    VerificationOptions
+	codeHashKey     []byte
+	refreshTokens   RefreshTokenOptions
+	lockout         security.LockoutPolicy
+	mfa             MfaOptions
+	apiKeys         ApiKeyOptions
 	database        auth.Database
 }
 
@@ -30,8 +127,22 @@ func NewService(database auth.Database, opts *ServiceOptions) auth.Service {
 		featureProvider: opts.FeatureProvider,
 		userNormalizer:  opts.UserNormalizer,
 		passwordHasher:  opts.PasswordHasher,
+		passwordPolicy:  opts.PasswordPolicy,
+		keyManager:      opts.KeyManager,
+		emailSender:     opts.EmailSender,
+		smsSender:       opts.SmsSender,
+		userTokens:      opts.UserTokens,
+		verification:    opts.Verification,
+		codeHashKey:     []byte(opts.Verification.CodeHashKey),
+		refreshTokens:   opts.RefreshTokens,
+		lockout:         opts.Lockout,
+		mfa:             opts.Mfa,
+		apiKeys:         opts.ApiKeys,
 		database:        database,
 	}
+	if len(svc.codeHashKey) == 0 {
+		slog.WarnContext(context.Background(), "No verification code hash key configured, codes are hashed without a key")
+	}
 
 	return svc
 }
@@ -48,6 +159,14 @@ func (svc *service) PasswordHasher() security.PasswordHasher {
 	return svc.passwordHasher
 }
 
+func (svc *service) PasswordPolicy() security.PasswordPolicy {
+	return svc.passwordPolicy
+}
+
+func (svc *service) KeyManager() security.KeyManager {
+	return svc.keyManager
+}
+
 func (opts *ServiceOptions) EnsureDefaults() {
 	if opts.FeatureProvider == nil {
 		opts.FeatureProvider = core.DefaultFeatureProvider()
@@ -56,6 +175,122 @@ func (opts *ServiceOptions) EnsureDefaults() {
 		opts.UserNormalizer = util.DefaultUserNormalizer()
 	}
 	if opts.PasswordHasher == nil {
-		opts.PasswordHasher = security.DefaultPasswordHasher()
+		opts.PasswordHasher = security.NewPasswordHasher(&opts.PasswordHashing)
+	}
+	if opts.PasswordPolicy == nil {
+		// A longer password could be set, but never hashed
+		if opts.PasswordHashing.Algorithm == security.PasswordHashAlgorithmBcrypt && (opts.PasswordRules.MaxLength <= 0 || opts.PasswordRules.MaxLength > security.BCRYPT_MAX_PASSWORD_LENGTH) {
+			opts.PasswordRules.MaxLength = security.BCRYPT_MAX_PASSWORD_LENGTH
+		}
+		opts.PasswordPolicy = security.NewPasswordPolicy(&opts.PasswordRules)
+	}
+	opts.SigningKeys.EnsureDefaults()
+	if opts.KeyManager == nil {
+		opts.KeyManager = security.DefaultKeyManager()
+	}
+	opts.Smtp.EnsureDefaults()
+	if opts.EmailSender == nil {
+		if opts.Smtp.Host != "" {
+			opts.EmailSender = notification.NewSmtpEmailSender(&opts.Smtp)
+		} else if opts.Outbox.EmailFile != "" {
+			opts.EmailSender = notification.NewOutboxEmailSender(opts.Outbox.EmailFile)
+		} else {
+			opts.EmailSender = notification.LogEmailSender()
+		}
+	}
+	if opts.SmsSender == nil {
+		if opts.Outbox.SmsFile != "" {
+			opts.SmsSender = notification.NewOutboxSmsSender(opts.Outbox.SmsFile)
+		} else {
+			opts.SmsSender = notification.LogSmsSender()
+		}
+	}
+	opts.UserTokens.EnsureDefaults()
+	opts.Verification.EnsureDefaults()
+	opts.RefreshTokens.EnsureDefaults()
+	opts.Lockout.EnsureDefaults()
+	opts.Mfa.EnsureDefaults()
+}
+
+func (opts *UserTokenOptions) EnsureDefaults() {
+	if opts.ActivationLifetimeHours <= 0 {
+		opts.ActivationLifetimeHours = DEFAULT_ACTIVATION_LIFETIME_HOURS
+	}
+	if opts.PasswordResetLifetimeMinutes <= 0 {
+		opts.PasswordResetLifetimeMinutes = DEFAULT_PASSWORD_RESET_LIFETIME_MINUTES
+	}
+	if opts.ActivationUrl == "" {
+		opts.ActivationUrl = DEFAULT_ACTIVATION_URL
+	}
+	if opts.PasswordResetUrl == "" {
+		opts.PasswordResetUrl = DEFAULT_PASSWORD_RESET_URL
+	}
+}
+
+func (opts *VerificationOptions) EnsureDefaults() {
+	if opts.CodeLength <= 0 {
+		opts.CodeLength = DEFAULT_VERIFICATION_CODE_LENGTH
+	}
+	if opts.EmailCodeLifetimeMinutes <= 0 {
+		opts.EmailCodeLifetimeMinutes = DEFAULT_EMAIL_CODE_LIFETIME_MINUTES
+	}
+	if opts.PhoneCodeLifetimeMinutes <= 0 {
+		opts.PhoneCodeLifetimeMinutes = DEFAULT_PHONE_CODE_LIFETIME_MINUTES
+	}
+	if opts.ResendIntervalSeconds <= 0 {
+		opts.ResendIntervalSeconds = DEFAULT_VERIFICATION_RESEND_SECONDS
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DEFAULT_VERIFICATION_MAX_ATTEMPTS
+	}
+	if opts.MaxCodesPerDay <= 0 {
+		opts.MaxCodesPerDay = DEFAULT_VERIFICATION_MAX_CODES_PER_DAY
+	}
+	if opts.MaxFailedPerDay <= 0 {
+		opts.MaxFailedPerDay = DEFAULT_VERIFICATION_MAX_FAILED_PER_DAY
+	}
+	if opts.EmailVerificationUrl == "" {
+		opts.EmailVerificationUrl = DEFAULT_EMAIL_VERIFICATION_URL
+	}
+}
+
+func (opts *VerificationOptions) LoadEnvironment() {
+	key, ok := os.LookupEnv("VERIFICATION_CODE_HASH_KEY")
+	if ok {
+		slog.InfoContext(context.Background(), "Override verification code hash key from environment")
+		opts.CodeHashKey = key
+	}
+}
+
+// EnsureCodeHashKey generates a random key when none is configured.
+func (opts *VerificationOptions) EnsureCodeHashKey() error {
+	if opts.CodeHashKey != "" {
+		return nil
+	}
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return err
+	}
+	slog.WarnContext(context.Background(), "No verification code hash key configured, using a random key. Codes are rejected after a restart and by other instances")
+	opts.CodeHashKey = base64.RawStdEncoding.EncodeToString(key)
+	return nil
+}
+
+func (opts *RefreshTokenOptions) EnsureDefaults() {
+	if opts.LifetimeHours <= 0 {
+		opts.LifetimeHours = DEFAULT_REFRESH_TOKEN_LIFETIME_HOURS
+	}
+}
+
+func (opts *MfaOptions) EnsureDefaults() {
+	if opts.Issuer == "" {
+		opts.Issuer = DEFAULT_MFA_ISSUER
+	}
+	if opts.SkewSteps <= 0 {
+		opts.SkewSteps = DEFAULT_MFA_SKEW_STEPS
+	}
+	if opts.RecoveryCodeCount <= 0 {
+		opts.RecoveryCodeCount = DEFAULT_MFA_RECOVERY_CODE_COUNT
 	}
 }
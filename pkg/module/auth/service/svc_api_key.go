@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+)
+
+// Storing the last use on every request would write to the database for each api call
+const apiKeyLastUsedInterval time.Duration = time.Minute
+
+// The prefix identifies the key, a collision is very unlikely but would make the older key unusable
+const apiKeyPrefixAttempts int = 3
+
+func (svc *service) GetApiKeys(ctx context.Context, offset int64, limit int64, filter *model.ApiKeyFilter, sort *core.Sort) ([]*model.ApiKey, int64, error) {
+	data, count, err := svc.database.ApiKeys().GetApiKeys(ctx, offset, limit, filter, sort)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get api keys from database",
+			slog.Any("error", err),
+		)
+		return nil, 0, err
+	}
+
+	return data, count, nil
+}
+
+func (svc *service) GetApiKeyById(ctx context.Context, id string) (*model.ApiKey, error) {
+	data, err := svc.database.ApiKeys().GetApiKeyById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get api key from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil {
+		return nil, auth.ErrApiKeyNotFound
+	}
+
+	return data, nil
+}
+
+// CreateApiKey stores a new api key and returns the key to hand out, which is only available once.
+// Keys of a user can only receive the scopes granted by the roles of the user, keys of a client the
+// scopes configured for the client.
+func (svc *service) CreateApiKey(ctx context.Context, apiKey *model.ApiKey) (string, *model.ApiKey, error) {
+	if (apiKey.UserId == "") == (apiKey.ClientId == "") {
+		return "", nil, auth.ErrApiKeyOwnerInvalid
+	}
+	allowed, err := svc.apiKeyScopes(ctx, apiKey)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, scope := range apiKey.Scopes {
+		if !slices.Contains(allowed, scope) {
+			return "", nil, auth.ErrScopeNotAllowed
+		}
+	}
+
+	key, prefix, hash, err := svc.generateApiKey(ctx)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate api key",
+			slog.String("subject", apiKey.Subject()),
+			slog.Any("error", err),
+		)
+		return "", nil, err
+	}
+
+	apiKey.Id = ""
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = hash
+	apiKey.CreatedAt = time.Now().UTC()
+	apiKey.LastUsedAt = time.Time{}
+
+	newId, err := svc.database.ApiKeys().CreateApiKey(ctx, apiKey)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create api key in database",
+			slog.String("subject", apiKey.Subject()),
+			slog.Any("error", err),
+		)
+		return "", nil, err
+	}
+	apiKey.Id = newId
+	svc.audit(ctx, model.AuditEventApiKeyCreated, apiKey.Subject(), nil, map[string]string{
+		"api_key_id": apiKey.Id,
+		"prefix":     apiKey.Prefix,
+		"scope":      strings.Join(apiKey.Scopes, " "),
+	})
+
+	return key, apiKey, nil
+}
+
+func (svc *service) DeleteApiKey(ctx context.Context, id string) error {
+	data, err := svc.GetApiKeyById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return svc.deleteApiKey(ctx, data)
+}
+
+func (svc *service) RevokeUserApiKey(ctx context.Context, userId string, id string) error {
+	data, err := svc.database.ApiKeys().GetApiKeyById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get api key from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	// Users can only revoke their own keys, don't reveal the keys of others
+	if data == nil || data.UserId != userId {
+		return auth.ErrApiKeyNotFound
+	}
+
+	return svc.deleteApiKey(ctx, data)
+}
+
+// ValidateApiKey returns the api key with the scopes it currently grants. The scopes of a user
+// key are limited to the current roles of the user, so removing a role also applies to its keys.
+// The same applies to the configured scopes of a client.
+func (svc *service) ValidateApiKey(ctx context.Context, key string) (*model.ApiKey, error) {
+	prefix, ok := security.ParseApiKeyPrefix(key)
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	data, err := svc.database.ApiKeys().GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get api key from database by prefix",
+			slog.String("prefix", prefix),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil || !security.VerifyUserToken(key, data.KeyHash) {
+		return nil, auth.ErrInvalidToken
+	}
+	if data.HasExpired() {
+		return nil, auth.ErrTokenExpired
+	}
+
+	result := data.Clone()
+	if data.UserId != "" {
+		user, err := svc.GetUserById(ctx, data.UserId)
+		if err != nil {
+			return nil, err
+		}
+		if !user.IsEnabled {
+			return nil, auth.ErrUserDisabled
+		}
+		if user.Locked() {
+			return nil, auth.ErrUserLocked
+		}
+	}
+	allowed, err := svc.apiKeyScopes(ctx, data)
+	if err == auth.ErrApiKeyClientNotFound {
+		return nil, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Scopes = slices.DeleteFunc(result.Scopes, func(scope string) bool {
+		return !slices.Contains(allowed, scope)
+	})
+
+	now := time.Now().UTC()
+	if now.Sub(data.LastUsedAt) >= apiKeyLastUsedInterval {
+		data.LastUsedAt = now
+		err = svc.database.ApiKeys().UpdateApiKey(ctx, data)
+		if err != nil {
+			// Failing to track the last use should not deny access
+			logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to update api key in database",
+				slog.String("id", data.Id),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	return result, nil
+}
+
+func (svc *service) deleteApiKey(ctx context.Context, apiKey *model.ApiKey) error {
+	err := svc.database.ApiKeys().DeleteApiKey(ctx, apiKey)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete api key in database",
+			slog.String("id", apiKey.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	svc.audit(ctx, model.AuditEventApiKeyRevoked, apiKey.Subject(), nil, map[string]string{
+		"api_key_id": apiKey.Id,
+		"prefix":     apiKey.Prefix,
+	})
+	return nil
+}
+
+// generateApiKey generates a key with a prefix which isn't used by another key yet.
+func (svc *service) generateApiKey(ctx context.Context) (string, string, string, error) {
+	for range apiKeyPrefixAttempts {
+		key, prefix, hash, err := security.GenerateApiKey()
+		if err != nil {
+			return "", "", "", err
+		}
+		existing, err := svc.database.ApiKeys().GetApiKeyByPrefix(ctx, prefix)
+		if err != nil {
+			return "", "", "", err
+		}
+		if existing == nil {
+			return key, prefix, hash, nil
+		}
+	}
+	return "", "", "", auth.ErrApiKeyPrefixConflict
+}
+
+// apiKeyScopes returns the scopes the key can grant, from the roles of the user or the configuration
+// of the client.
+func (svc *service) apiKeyScopes(ctx context.Context, apiKey *model.ApiKey) ([]string, error) {
+	if apiKey.UserId != "" {
+		return svc.userScopes(ctx, apiKey.UserId)
+	}
+	for _, client := range svc.apiKeys.Clients {
+		if client.ClientId == apiKey.ClientId {
+			return client.Scopes, nil
+		}
+	}
+	return nil, auth.ErrApiKeyClientNotFound
+}
+
+// userScopes returns the api scopes granted by the roles of the user.
+func (svc *service) userScopes(ctx context.Context, userId string) ([]string, error) {
+	roles, err := svc.GetUserRoles(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, 0)
+	for _, role := range roles {
+		for _, scope := range role.Scopes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes, nil
+}
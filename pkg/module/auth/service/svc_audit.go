@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/http/clientinfo"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/go-router/authentication"
+)
+
+func (svc *service) GetAuditEvents(ctx context.Context, offset int64, limit int64, filter *model.AuditEventFilter, sort *core.Sort) ([]*model.AuditEvent, int64, error) {
+	data, count, err := svc.database.AuditEvents().GetAuditEvents(ctx, offset, limit, filter, sort)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get audit events from database",
+			slog.Any("error", err),
+		)
+		return nil, 0, err
+	}
+
+	return data, count, nil
+}
+
+// CreateAuditEvent appends the event to the audit log. The actor, client and timestamp are taken
+// from the context when not set. Without an authenticated actor, the subject acted itself, like on login.
+func (svc *service) CreateAuditEvent(ctx context.Context, model *model.AuditEvent) error {
+	model.Id = ""
+	if model.ActorId == "" {
+		model.ActorId = authentication.GetContext(ctx).GetSubjectId()
+	}
+	if model.ActorId == "" {
+		model.ActorId = model.SubjectId
+	}
+	client := clientinfo.GetClientInfoFromContext(ctx)
+	if model.IpAddress == "" {
+		model.IpAddress = client.IpAddress
+	}
+	if model.UserAgent == "" {
+		model.UserAgent = client.UserAgent
+	}
+	if model.Timestamp.IsZero() {
+		model.Timestamp = time.Now().UTC()
+	}
+
+	_, err := svc.database.AuditEvents().CreateAuditEvent(ctx, model)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create audit event in database",
+			slog.String("type", string(model.Type)),
+			slog.String("subject", model.SubjectId),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
+
+// audit records the outcome of an action on the subject. Failing to write the audit log is
+// logged, but doesn't fail the action itself.
+func (svc *service) audit(ctx context.Context, eventType model.AuditEventType, subjectId string, result error, details map[string]string) {
+	event := &model.AuditEvent{
+		Type:      eventType,
+		SubjectId: subjectId,
+		Outcome:   model.AuditOutcomeSuccess,
+		Details:   details,
+	}
+	if result != nil {
+		event.Outcome = model.AuditOutcomeFailure
+		event.Reason = result.Error()
+	}
+
+	_ = svc.CreateAuditEvent(ctx, event)
+}
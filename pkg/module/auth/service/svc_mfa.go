@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+)
+
+// EnrollTotp generates a new secret for the user. Multi-factor authentication is only enabled
+// once the first code is confirmed with ConfirmTotp.
+func (svc *service) EnrollTotp(ctx context.Context, userId string) (*model.TotpEnrollment, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnabled {
+		return nil, auth.ErrMfaAlreadyEnabled
+	}
+
+	secret, err := security.GenerateTotpSecret()
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate totp secret",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	user.TotpSecret = secret
+	user.TotpLastCounter = 0
+
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	accountName := user.Email
+	if accountName == "" {
+		accountName = user.Username
+	}
+	return &model.TotpEnrollment{
+		Secret:          secret,
+		ProvisioningUri: security.TotpProvisioningUri(svc.mfa.Issuer, accountName, secret),
+	}, nil
+}
+
+// ConfirmTotp enables multi-factor authentication and returns the recovery codes. The codes
+// are only stored as hash, so this is the only time they can be shown to the user.
+func (svc *service) ConfirmTotp(ctx context.Context, userId string, code string) ([]string, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnabled {
+		return nil, auth.ErrMfaAlreadyEnabled
+	}
+	if user.TotpSecret == "" {
+		return nil, auth.ErrMfaNotEnrolled
+	}
+
+	counter, ok := security.ValidateTotp(user.TotpSecret, code, time.Now(), svc.mfa.SkewSteps, user.TotpLastCounter)
+	if !ok {
+		return nil, auth.ErrInvalidOtp
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := security.GenerateRecoveryCodes(svc.mfa.RecoveryCodeCount)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate recovery codes",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	user.MfaEnabled = true
+	user.TotpLastCounter = counter
+	user.RecoveryCodes = recoveryCodeHashes
+
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (svc *service) DisableTotp(ctx context.Context, userId string, code string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = svc.VerifyOtp(ctx, user, code)
+	if err != nil {
+		return err
+	}
+
+	user.ResetMfa()
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	svc.audit(ctx, model.AuditEventMfaReset, userId, nil, nil)
+
+	return nil
+}
+
+// ResetMfa disables multi-factor authentication without a code, for users who lost both their
+// authenticator and their recovery codes.
+func (svc *service) ResetMfa(ctx context.Context, userId string) error {
+	err := svc.resetMfa(ctx, userId)
+	svc.audit(ctx, model.AuditEventMfaReset, userId, err, nil)
+	return err
+}
+
+func (svc *service) resetMfa(ctx context.Context, userId string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !user.MfaEnabled && user.TotpSecret == "" {
+		return auth.ErrMfaNotEnabled
+	}
+
+	user.ResetMfa()
+	return svc.updateUser(ctx, user)
+}
+
+// VerifyOtp accepts either a code of the authenticator app or one of the recovery codes.
+// Both can only be used once.
+func (svc *service) VerifyOtp(ctx context.Context, user *model.User, code string) error {
+	err := svc.verifyOtp(ctx, user, code)
+	svc.audit(ctx, model.AuditEventLoginOtp, user.Id, err, nil)
+	return err
+}
+
+func (svc *service) verifyOtp(ctx context.Context, user *model.User, code string) error {
+	if !user.MfaEnabled {
+		return auth.ErrMfaNotEnabled
+	}
+	if user.Locked() {
+		return auth.ErrUserLocked
+	}
+
+	code = strings.TrimSpace(code)
+	counter, ok := security.ValidateTotp(user.TotpSecret, code, time.Now(), svc.mfa.SkewSteps, user.TotpLastCounter)
+	if ok {
+		user.TotpLastCounter = counter
+		user.ResetLoginFailures()
+		return svc.updateUser(ctx, user)
+	}
+
+	code = strings.ToLower(code)
+	index := slices.IndexFunc(user.RecoveryCodes, func(hash string) bool {
+		return security.VerifyUserToken(code, hash)
+	})
+	if index >= 0 {
+		user.RecoveryCodes = slices.Delete(user.RecoveryCodes, index, index+1)
+		logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Recovery code used",
+			slog.String("id", user.Id),
+			slog.Int("remaining", len(user.RecoveryCodes)),
+		)
+		user.ResetLoginFailures()
+		return svc.updateUser(ctx, user)
+	}
+
+	logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Invalid one-time password",
+		slog.String("id", user.Id),
+	)
+	user.LoginFailures++
+	return svc.loginFailed(ctx, user, auth.ErrInvalidOtp)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+)
+
+func (svc *service) GetRefreshTokens(ctx context.Context, offset int64, limit int64, filter *model.RefreshTokenFilter, sort *core.Sort) ([]*model.RefreshToken, int64, error) {
+	data, count, err := svc.database.RefreshTokens().GetRefreshTokens(ctx, offset, limit, filter, sort)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get refresh tokens from database",
+			slog.Any("error", err),
+		)
+		return nil, 0, err
+	}
+
+	return data, count, nil
+}
+
+// CreateRefreshToken stores a new refresh token and returns the token to hand out to the client.
+// Only the hash of the token is stored.
+func (svc *service) CreateRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) (string, *model.RefreshToken, error) {
+	token, hash, err := security.GenerateUserToken()
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate refresh token",
+			slog.String("user", refreshToken.UserId),
+			slog.Any("error", err),
+		)
+		return "", nil, err
+	}
+
+	refreshToken.Id = ""
+	refreshToken.TokenHash = hash
+	refreshToken.CreatedAt = time.Now().UTC()
+	refreshToken.ExpiresAt = refreshToken.CreatedAt.Add(time.Duration(svc.refreshTokens.LifetimeHours) * time.Hour)
+
+	newId, err := svc.database.RefreshTokens().CreateRefreshToken(ctx, refreshToken)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create refresh token in database",
+			slog.String("user", refreshToken.UserId),
+			slog.Any("error", err),
+		)
+		return "", nil, err
+	}
+	refreshToken.Id = newId
+	svc.audit(ctx, model.AuditEventTokenIssued, refreshToken.UserId, nil, map[string]string{
+		"client_id": refreshToken.ClientId,
+		"token_id":  refreshToken.Id,
+		"scope":     strings.Join(refreshToken.Scopes, " "),
+	})
+
+	return token, refreshToken, nil
+}
+
+// RedeemRefreshToken validates the refresh token and deletes it. Refresh tokens are rotated,
+// the caller issues a new one.
+func (svc *service) RedeemRefreshToken(ctx context.Context, token string, clientId string) (*model.RefreshToken, error) {
+	data, err := svc.database.RefreshTokens().GetRefreshTokenByHash(ctx, security.HashUserToken(token))
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get refresh token from database by hash",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil || data.ClientId != clientId {
+		return nil, auth.ErrRefreshTokenNotFound
+	}
+
+	err = svc.deleteRefreshToken(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if data.HasExpired() {
+		err = auth.ErrTokenExpired
+	}
+	svc.audit(ctx, model.AuditEventTokenRefreshed, data.UserId, err, map[string]string{
+		"client_id": data.ClientId,
+		"token_id":  data.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (svc *service) RevokeRefreshToken(ctx context.Context, userId string, id string) error {
+	data, err := svc.database.RefreshTokens().GetRefreshTokenById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get refresh token from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	// Users can only revoke their own tokens, don't reveal the tokens of others
+	if data == nil || data.UserId != userId {
+		return auth.ErrRefreshTokenNotFound
+	}
+
+	return svc.revokeRefreshToken(ctx, data)
+}
+
+func (svc *service) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	filter := &model.RefreshTokenFilter{
+		UserId: userId,
+	}
+
+	for {
+		tokens, _, err := svc.GetRefreshTokens(ctx, 0, 100, filter, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(tokens) == 0 {
+			break
+		}
+
+		for _, token := range tokens {
+			err = svc.revokeRefreshToken(ctx, token)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (svc *service) revokeRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) error {
+	err := svc.deleteRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	svc.audit(ctx, model.AuditEventTokenRevoked, refreshToken.UserId, nil, map[string]string{
+		"client_id": refreshToken.ClientId,
+		"token_id":  refreshToken.Id,
+	})
+	return nil
+}
+
+func (svc *service) deleteRefreshToken(ctx context.Context, refreshToken *model.RefreshToken) error {
+	err := svc.database.RefreshTokens().DeleteRefreshToken(ctx, refreshToken)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete refresh token in database",
+			slog.String("id", refreshToken.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+)
+
+func (svc *service) GetRoles(ctx context.Context, offset int64, limit int64, filter *model.RoleFilter, sort *core.Sort) ([]*model.Role, int64, error) {
+	data, count, err := svc.database.Roles().GetRoles(ctx, offset, limit, filter, sort)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get roles from database",
+			slog.Any("error", err),
+		)
+		return nil, 0, err
+	}
+
+	return data, count, nil
+}
+
+func (svc *service) GetRoleById(ctx context.Context, id string) (*model.Role, error) {
+	data, err := svc.database.Roles().GetRoleById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get role from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil {
+		return nil, auth.ErrRoleNotFound
+	}
+
+	return data, nil
+}
+
+func (svc *service) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	normalizedName := svc.userNormalizer.NormalizeRoleName(name)
+	data, err := svc.database.Roles().GetRoleByName(ctx, normalizedName)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get role from database by name",
+			slog.String("name", name),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil {
+		return nil, auth.ErrRoleNotFound
+	}
+
+	return data, nil
+}
+
+func (svc *service) CreateRole(ctx context.Context, model *model.Role) (*model.Role, error) {
+	model.Normalize(svc.userNormalizer)
+	model.Id = ""
+
+	if err := svc.checkDuplicateRoleName(ctx, model); err != nil {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to create role cause of duplicate name",
+			slog.String("name", model.Name),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	newId, err := svc.database.Roles().CreateRole(ctx, model)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create role in database",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	return svc.GetRoleById(ctx, newId)
+}
+
+func (svc *service) UpdateRole(ctx context.Context, id string, model *model.Role) (*model.Role, error) {
+	model.Normalize(svc.userNormalizer)
+	model.Id = id
+
+	if err := svc.checkDuplicateRoleName(ctx, model); err != nil {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to update role cause of duplicate name",
+			slog.String("name", model.Name),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	data, err := svc.database.Roles().GetRoleById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get role from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	if data == nil {
+		return nil, auth.ErrRoleNotFound
+	}
+	data.UpdateModel(model)
+
+	err = svc.database.Roles().UpdateRole(ctx, data)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update role in database",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	return svc.GetRoleById(ctx, id)
+}
+
+func (svc *service) DeleteRole(ctx context.Context, id string) error {
+	data, err := svc.database.Roles().GetRoleById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get role from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	if data == nil {
+		return auth.ErrRoleNotFound
+	}
+
+	err = svc.database.Roles().DeleteRole(ctx, data)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete role in database",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (svc *service) GetUserRoles(ctx context.Context, userId string) ([]*model.Role, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*model.Role, 0)
+	for _, roleId := range user.Roles {
+		role, err := svc.GetRoleById(ctx, roleId)
+		if err == auth.ErrRoleNotFound {
+			// The role has been deleted, it no longer grants anything
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (svc *service) AddUserRole(ctx context.Context, userId string, roleId string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	_, err = svc.GetRoleById(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasRole(roleId) {
+		return user, nil
+	}
+	user.AddRole(roleId)
+
+	err = svc.database.Users().UpdateUser(ctx, user)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user roles in database",
+			slog.String("id", userId),
+			slog.String("role", roleId),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	svc.audit(ctx, model.AuditEventRoleAdded, userId, nil, map[string]string{
+		"role_id": roleId,
+	})
+
+	return svc.GetUserById(ctx, userId)
+}
+
+func (svc *service) RemoveUserRole(ctx context.Context, userId string, roleId string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasRole(roleId) {
+		return user, nil
+	}
+	user.RemoveRole(roleId)
+
+	err = svc.database.Users().UpdateUser(ctx, user)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user roles in database",
+			slog.String("id", userId),
+			slog.String("role", roleId),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	svc.audit(ctx, model.AuditEventRoleRemoved, userId, nil, map[string]string{
+		"role_id": roleId,
+	})
+
+	return svc.GetUserById(ctx, userId)
+}
+
+func (svc *service) checkDuplicateRoleName(ctx context.Context, role *model.Role) error {
+	existing, err := svc.database.Roles().GetRoleByName(ctx, role.NormalizedName)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Id != role.Id {
+		return auth.ErrDuplicateRoleName
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/deb-ict/cloudbm-community/pkg/core"
@@ -73,39 +74,49 @@ func (svc *service) GetUserByEmail(ctx context.Context, email string) (*model.Us
 	return data, nil
 }
 
-func (svc *service) CreateUser(ctx context.Context, model *model.User) (*model.User, error) {
-	model.Normalize(svc.userNormalizer)
-	model.Id = ""
+// CreateUser creates the user. The password is optional, users without password can set one
+// using the password reset flow.
+func (svc *service) CreateUser(ctx context.Context, user *model.User, password string) (*model.User, error) {
+	user.Normalize(svc.userNormalizer)
+	user.Id = ""
+	user.PasswordHash = ""
 
-	if err := svc.checkDuplicateUsername(ctx, model); err != nil {
+	if err := svc.checkDuplicateUsername(ctx, user); err != nil {
 		slog.WarnContext(ctx, "Failed to create user cause of duplicate username",
-			slog.String("username", model.Username),
+			slog.String("username", user.Username),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
-	if err := svc.checkDuplicateEmail(ctx, model); err != nil {
+	if err := svc.checkDuplicateEmail(ctx, user); err != nil {
 		slog.WarnContext(ctx, "Failed to create user cause of duplicate email",
-			slog.String("email", model.Email),
+			slog.String("email", user.Email),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
+	if password != "" {
+		err := svc.hashPassword(ctx, user, password)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	newId, err := svc.database.Users().CreateUser(ctx, model)
+	newId, err := svc.database.Users().CreateUser(ctx, user)
 	if err != nil {
 		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create user in database",
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
+	svc.audit(ctx, model.AuditEventUserCreated, newId, nil, nil)
 
 	return svc.GetUserById(ctx, newId)
 }
 
-func (svc *service) UpdateUser(ctx context.Context, id string, model *model.User) (*model.User, error) {
-	model.Normalize(svc.userNormalizer)
-	model.Id = id
+func (svc *service) UpdateUser(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	user.Normalize(svc.userNormalizer)
+	user.Id = id
 
 	data, err := svc.database.Users().GetUserById(ctx, id)
 	if err != nil {
@@ -118,9 +129,9 @@ func (svc *service) UpdateUser(ctx context.Context, id string, model *model.User
 	if data == nil {
 		return nil, auth.ErrUserNotFound
 	}
-	data.UpdateModel(model)
+	data.UpdateModel(user)
 
-	err = svc.database.Users().UpdateUser(ctx, model)
+	err = svc.database.Users().UpdateUser(ctx, data)
 	if err != nil {
 		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user in database",
 			slog.String("id", id),
@@ -128,6 +139,7 @@ func (svc *service) UpdateUser(ctx context.Context, id string, model *model.User
 		)
 		return nil, err
 	}
+	svc.audit(ctx, model.AuditEventUserUpdated, id, nil, nil)
 
 	return svc.GetUserById(ctx, id)
 }
@@ -153,6 +165,7 @@ func (svc *service) DeleteUser(ctx context.Context, id string) error {
 		)
 		return err
 	}
+	svc.audit(ctx, model.AuditEventUserDeleted, id, nil, nil)
 
 	return nil
 }
@@ -162,6 +175,10 @@ func (svc *service) LockUser(ctx context.Context, user *model.User, duration tim
 
 	err := svc.database.Users().UpdateUser(ctx, user)
 	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user in database",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
 		return nil, err
 	}
 
@@ -173,17 +190,252 @@ func (svc *service) UnlockUser(ctx context.Context, user *model.User) (*model.Us
 
 	err := svc.database.Users().UpdateUser(ctx, user)
 	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user in database",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
 		return nil, err
 	}
+	svc.audit(ctx, model.AuditEventUserUnlocked, user.Id, nil, nil)
 
 	return svc.GetUserById(ctx, user.Id)
 }
 
+// VerifyPassword checks the password of the user and applies the lockout policy. Disabled and
+// locked users are refused without checking the password.
 func (svc *service) VerifyPassword(ctx context.Context, user *model.User, password string) error {
-	if user.VerifyPassword(svc.passwordHasher, password) {
+	err := svc.verifyPassword(ctx, user, password)
+	svc.audit(ctx, model.AuditEventLogin, user.Id, err, nil)
+	return err
+}
+
+func (svc *service) verifyPassword(ctx context.Context, user *model.User, password string) error {
+	if !user.IsEnabled {
+		return auth.ErrUserDisabled
+	}
+	if user.Locked() {
+		return auth.ErrUserLocked
+	}
+
+	if !user.VerifyPassword(svc.passwordHasher, password) {
+		return svc.loginFailed(ctx, user, auth.ErrPasswordNotMatch)
+	}
+
+	changed := svc.rehashPassword(ctx, user, password)
+	// With multi-factor authentication the failures are reset once the second factor is verified
+	if !user.MfaEnabled && user.ResetLoginFailures() {
+		changed = true
+	}
+	if !changed {
 		return nil
 	}
-	return auth.ErrPasswordNotMatch
+	return svc.updateUser(ctx, user)
+}
+
+// rehashPassword upgrades a hash made with an older algorithm or weaker parameters, while the
+// plain password is known after a successful login. The password policy is not applied here.
+func (svc *service) rehashPassword(ctx context.Context, user *model.User, password string) bool {
+	if !svc.passwordHasher.NeedsRehash(user.PasswordHash) {
+		return false
+	}
+
+	hash, err := svc.passwordHasher.HashPassword(password)
+	if err != nil {
+		// The login itself succeeded, the hash is upgraded on a next login
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to rehash password",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
+		return false
+	}
+	user.PasswordHash = hash
+
+	logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Upgraded password hash",
+		slog.String("id", user.Id),
+	)
+	return true
+}
+
+func (svc *service) SetPassword(ctx context.Context, userId string, password string) error {
+	err := svc.setPassword(ctx, userId, password)
+	svc.audit(ctx, model.AuditEventPasswordSet, userId, err, nil)
+	return err
+}
+
+func (svc *service) setPassword(ctx context.Context, userId string, password string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = svc.hashPassword(ctx, user, password)
+	if err != nil {
+		return err
+	}
+	return svc.updateUser(ctx, user)
+}
+
+func (svc *service) ChangePassword(ctx context.Context, userId string, currentPassword string, newPassword string) error {
+	err := svc.changePassword(ctx, userId, currentPassword, newPassword)
+	svc.audit(ctx, model.AuditEventPasswordChanged, userId, err, nil)
+	return err
+}
+
+func (svc *service) changePassword(ctx context.Context, userId string, currentPassword string, newPassword string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = svc.verifyPassword(ctx, user, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	err = svc.hashPassword(ctx, user, newPassword)
+	if err != nil {
+		return err
+	}
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	// Sign out the other devices
+	return svc.RevokeUserRefreshTokens(ctx, user.Id)
+}
+
+// ChangeEmail requests a change of the email of the user. The new address is kept as pending email
+// and a verification code is sent to it, it only replaces the email once the code is verified. Until
+// then password reset links keep going to the current address.
+func (svc *service) ChangeEmail(ctx context.Context, userId string, currentPassword string, email string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.verifyPassword(ctx, user, currentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if svc.userNormalizer.NormalizeEmail(email) == user.NormalizedEmail {
+		// Requesting the current address cancels a pending change
+		if user.PendingEmail != "" {
+			user.PendingEmail = ""
+			err = svc.updateUser(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return svc.GetUserById(ctx, user.Id)
+	}
+
+	candidate := &model.User{Email: email}
+	candidate.Normalize(svc.userNormalizer)
+	if err := svc.checkDuplicateEmail(ctx, candidate); err != nil {
+		slog.WarnContext(ctx, "Failed to change email cause of duplicate email",
+			slog.String("email", email),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	// The code is sent before the pending email is stored, so a refused code leaves nothing behind
+	user.PendingEmail = email
+	err = svc.sendEmailVerification(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.GetUserById(ctx, user.Id)
+}
+
+func (svc *service) ChangePhone(ctx context.Context, userId string, phone string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if phone == user.Phone {
+		return user, nil
+	}
+
+	user.Phone = phone
+	user.PhoneVerified = false
+
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Phone != "" {
+		err = svc.SendPhoneVerification(ctx, user.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return svc.GetUserById(ctx, user.Id)
+}
+
+// hashPassword validates the password against the password policy and sets the password hash of the user.
+func (svc *service) hashPassword(ctx context.Context, user *model.User, password string) error {
+	err := svc.passwordPolicy.ValidatePassword(password, user.Username, user.Email)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Password rejected by password policy",
+			slog.String("id", user.Id),
+		)
+		return err
+	}
+
+	user.PasswordHash, err = svc.passwordHasher.HashPassword(password)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to hash password",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
+
+// loginFailed applies the lockout policy after a failed login attempt and persists the failure count.
+func (svc *service) loginFailed(ctx context.Context, user *model.User, reason error) error {
+	if duration := svc.lockout.LockDuration(user.LoginFailures); duration > 0 {
+		user.Lock(duration)
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "User locked after too many login failures",
+			slog.String("id", user.Id),
+			slog.Int("failures", int(user.LoginFailures)),
+			slog.Duration("duration", duration),
+		)
+		svc.audit(ctx, model.AuditEventUserLocked, user.Id, nil, map[string]string{
+			"failures": strconv.Itoa(int(user.LoginFailures)),
+			"duration": duration.String(),
+		})
+	}
+
+	err := svc.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return reason
+}
+
+func (svc *service) updateUser(ctx context.Context, user *model.User) error {
+	err := svc.database.Users().UpdateUser(ctx, user)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user in database",
+			slog.String("id", user.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
 }
 
 func (svc *service) checkDuplicateUsername(ctx context.Context, user *model.User) error {
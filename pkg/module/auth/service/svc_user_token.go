@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+	"github.com/deb-ict/cloudbm-community/pkg/notification"
+)
+
+func (svc *service) SendActivation(ctx context.Context, userId string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.IsEnabled && user.EmailVerified {
+		return auth.ErrUserAlreadyActive
+	}
+
+	lifetime := time.Duration(svc.userTokens.ActivationLifetimeHours) * time.Hour
+	token, err := svc.createUserToken(ctx, user, model.UserTokenType_ActivationToken, lifetime)
+	if err != nil {
+		return err
+	}
+
+	return svc.emailSender.SendEmail(ctx, &notification.EmailMessage{
+		To:      user.Email,
+		Subject: "Activate your account",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease activate your account using the link below. The link is valid for %d hours.\n\n%s\n",
+			user.Username,
+			svc.userTokens.ActivationLifetimeHours,
+			userTokenUrl(svc.userTokens.ActivationUrl, user.Id, token),
+		),
+	})
+}
+
+func (svc *service) ActivateUser(ctx context.Context, userId string, token string) (*model.User, error) {
+	user, err := svc.activateUser(ctx, userId, token)
+	svc.audit(ctx, model.AuditEventUserActivated, userId, err, nil)
+	return user, err
+}
+
+func (svc *service) activateUser(ctx context.Context, userId string, token string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.consumeUserToken(ctx, user, model.UserTokenType_ActivationToken, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user.IsEnabled = true
+	user.EmailVerified = true
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.GetUserById(ctx, user.Id)
+}
+
+// RequestPasswordReset sends a password reset link to the user with the given email. To avoid leaking
+// which addresses have an account, no error is returned when the user does not exist, or when a link
+// was sent within the resend interval.
+func (svc *service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := svc.GetUserByEmail(ctx, email)
+	if err == auth.ErrUserNotFound {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Password reset requested for unknown email",
+			slog.String("email", email),
+		)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if svc.hasRecentUserToken(user, model.UserTokenType_PasswordResetToken, "") {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Password reset requested too recently",
+			slog.String("id", user.Id),
+		)
+		return nil
+	}
+
+	lifetime := time.Duration(svc.userTokens.PasswordResetLifetimeMinutes) * time.Minute
+	token, err := svc.createUserToken(ctx, user, model.UserTokenType_PasswordResetToken, lifetime)
+	if err != nil {
+		return err
+	}
+
+	return svc.emailSender.SendEmail(ctx, &notification.EmailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Use the link below to choose a new password. The link is valid for %d minutes.\n\n%s\n\nIf you did not request this, you can ignore this message.\n",
+			user.Username,
+			svc.userTokens.PasswordResetLifetimeMinutes,
+			userTokenUrl(svc.userTokens.PasswordResetUrl, user.Id, token),
+		),
+	})
+}
+
+func (svc *service) ResetPassword(ctx context.Context, userId string, token string, password string) error {
+	err := svc.resetPassword(ctx, userId, token, password)
+	svc.audit(ctx, model.AuditEventPasswordReset, userId, err, nil)
+	return err
+}
+
+func (svc *service) resetPassword(ctx context.Context, userId string, token string, password string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// Validate the password first, so the token is not consumed by a rejected password
+	err = svc.passwordPolicy.ValidatePassword(password, user.Username, user.Email)
+	if err != nil {
+		return err
+	}
+
+	err = svc.consumeUserToken(ctx, user, model.UserTokenType_PasswordResetToken, token)
+	if err != nil {
+		return err
+	}
+
+	err = svc.hashPassword(ctx, user, password)
+	if err != nil {
+		return err
+	}
+	// Proving access to the email ends a lockout, otherwise the new password can't be used until it expires
+	user.Unlock()
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return svc.RevokeUserRefreshTokens(ctx, user.Id)
+}
+
+// createUserToken replaces the tokens of the given type with a new one. Only the hash is stored,
+// the returned token is sent to the user.
+func (svc *service) createUserToken(ctx context.Context, user *model.User, tokenType model.UserTokenType, lifetime time.Duration) (string, error) {
+	err := svc.deleteUserTokens(ctx, user, tokenType)
+	if err != nil {
+		return "", err
+	}
+
+	token, hash, err := security.GenerateUserToken()
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate user token",
+			slog.String("id", user.Id),
+			slog.String("type", tokenType.String()),
+			slog.Any("error", err),
+		)
+		return "", err
+	}
+
+	userToken := &model.UserToken{
+		Type:  tokenType,
+		Token: hash,
+	}
+	userToken.SetExpiration(lifetime)
+	err = svc.insertUserToken(ctx, user, userToken)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// hasRecentUserToken reports whether a token of the type was sent to the target within the resend interval.
+func (svc *service) hasRecentUserToken(user *model.User, tokenType model.UserTokenType, target string) bool {
+	interval := time.Duration(svc.verification.ResendIntervalSeconds) * time.Second
+	for _, userToken := range user.Tokens {
+		if userToken.Type == tokenType && userToken.Target == target && time.Since(userToken.CreatedAt) < interval {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *service) insertUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error {
+	var err error
+	userToken.CreatedAt = time.Now().UTC()
+	userToken.Id, err = svc.database.UserTokens().CreateUserToken(ctx, user, userToken)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to create user token in database",
+			slog.String("id", user.Id),
+			slog.String("type", userToken.Type.String()),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	user.Tokens = append(user.Tokens, userToken)
+	return nil
+}
+
+// consumeUserToken validates the token and deletes it, so it can only be used once.
+func (svc *service) consumeUserToken(ctx context.Context, user *model.User, tokenType model.UserTokenType, token string) error {
+	var userToken *model.UserToken
+	for _, candidate := range user.Tokens {
+		if candidate.Type == tokenType && security.VerifyUserToken(token, candidate.Token) {
+			userToken = candidate
+			break
+		}
+	}
+	if userToken == nil {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Invalid user token",
+			slog.String("id", user.Id),
+			slog.String("type", tokenType.String()),
+		)
+		return auth.ErrInvalidToken
+	}
+
+	err := svc.deleteUserToken(ctx, user, userToken)
+	if err != nil {
+		return err
+	}
+	if userToken.HasExpired() {
+		return auth.ErrTokenExpired
+	}
+
+	return nil
+}
+
+func (svc *service) deleteUserTokens(ctx context.Context, user *model.User, tokenType model.UserTokenType) error {
+	for _, userToken := range slices.Clone(user.Tokens) {
+		if userToken.Type != tokenType {
+			continue
+		}
+		err := svc.deleteUserToken(ctx, user, userToken)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc *service) deleteUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error {
+	err := svc.database.UserTokens().DeleteUserToken(ctx, user, userToken)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete user token in database",
+			slog.String("id", user.Id),
+			slog.String("type", userToken.Type.String()),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	user.RemoveToken(userToken)
+	return nil
+}
+
+func userTokenUrl(baseUrl string, userId string, token string) string {
+	query := url.Values{}
+	query.Set("user", userId)
+	query.Set("token", token)
+	return baseUrl + "?" + query.Encode()
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryUserDatabase keeps a single user, the repositories which aren't needed are left nil
+type memoryUserDatabase struct {
+	auth.UserRepository
+	auth.RefreshTokenRepository
+	auth.AuditEventRepository
+	user *model.User
+}
+
+type memoryUserTokenRepository struct{}
+
+func (db *memoryUserDatabase) Users() auth.UserRepository { return db }
+func (db *memoryUserDatabase) UserTokens() auth.UserTokenRepository {
+	return &memoryUserTokenRepository{}
+}
+func (db *memoryUserDatabase) Roles() auth.RoleRepository                 { return nil }
+func (db *memoryUserDatabase) RefreshTokens() auth.RefreshTokenRepository { return db }
+func (db *memoryUserDatabase) ApiKeys() auth.ApiKeyRepository             { return nil }
+func (db *memoryUserDatabase) AuditEvents() auth.AuditEventRepository     { return db }
+
+func (db *memoryUserDatabase) GetUserById(ctx context.Context, id string) (*model.User, error) {
+	if db.user == nil || db.user.Id != id {
+		return nil, nil
+	}
+	return db.user.Clone(), nil
+}
+
+func (db *memoryUserDatabase) UpdateUser(ctx context.Context, user *model.User) error {
+	db.user = user.Clone()
+	return nil
+}
+
+func (db *memoryUserDatabase) GetRefreshTokens(ctx context.Context, offset int64, limit int64, filter *model.RefreshTokenFilter, sort *core.Sort) ([]*model.RefreshToken, int64, error) {
+	return nil, 0, nil
+}
+
+func (db *memoryUserDatabase) CreateAuditEvent(ctx context.Context, event *model.AuditEvent) (string, error) {
+	return "event", nil
+}
+
+func (r *memoryUserTokenRepository) CreateUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) (string, error) {
+	return "token", nil
+}
+
+func (r *memoryUserTokenRepository) UpdateUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error {
+	return nil
+}
+
+func (r *memoryUserTokenRepository) DeleteUserToken(ctx context.Context, user *model.User, userToken *model.UserToken) error {
+	return nil
+}
+
+func TestResetPasswordUnlocksUser(t *testing.T) {
+	ctx := context.Background()
+	db := &memoryUserDatabase{}
+	svc := NewService(db, nil).(*service)
+
+	user := &model.User{
+		Id:            "user",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		IsEnabled:     true,
+		LoginFailures: 5,
+	}
+	user.Lock(time.Hour)
+	token, err := svc.createUserToken(ctx, user, model.UserTokenType_PasswordResetToken, time.Hour)
+	assert.NoError(t, err)
+	db.user = user.Clone()
+
+	err = svc.ResetPassword(ctx, user.Id, token, "A-new password 42")
+	assert.NoError(t, err)
+	assert.False(t, db.user.Locked())
+	assert.False(t, db.user.IsLocked)
+	assert.Equal(t, int32(0), db.user.LoginFailures)
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/auth/security"
+	"github.com/deb-ict/cloudbm-community/pkg/notification"
+)
+
+// The daily limits of verification codes are counted over a sliding window
+const verificationLimitWindow time.Duration = 24 * time.Hour
+
+// SendEmailVerification sends a code to the email address of the user, both to type over
+// and as part of a link.
+func (svc *service) SendEmailVerification(ctx context.Context, userId string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return svc.sendEmailVerification(ctx, user)
+}
+
+// sendEmailVerification sends the code to the pending email of the user, or to the current email
+// while it isn't verified.
+func (svc *service) sendEmailVerification(ctx context.Context, user *model.User) error {
+	email, target := svc.emailToVerify(user)
+	if email == "" {
+		return auth.ErrNoEmail
+	}
+	if user.PendingEmail == "" && user.EmailVerified {
+		return auth.ErrAlreadyVerified
+	}
+
+	lifetime := time.Duration(svc.verification.EmailCodeLifetimeMinutes) * time.Minute
+	code, err := svc.createVerificationCode(ctx, user, model.UserTokenType_EmailVerificationCode, target, lifetime)
+	if err != nil {
+		return err
+	}
+
+	return svc.emailSender.SendEmail(ctx, &notification.EmailMessage{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nYour verification code is %s. You can also verify your email address using the link below. The code is valid for %d minutes.\n\n%s\n",
+			user.Username,
+			code,
+			svc.verification.EmailCodeLifetimeMinutes,
+			verificationUrl(svc.verification.EmailVerificationUrl, user.Id, code),
+		),
+	})
+}
+
+func (svc *service) VerifyEmail(ctx context.Context, userId string, code string) (*model.User, error) {
+	user, err := svc.verifyEmail(ctx, userId, code)
+	svc.audit(ctx, model.AuditEventEmailVerified, userId, err, nil)
+	return user, err
+}
+
+func (svc *service) verifyEmail(ctx context.Context, userId string, code string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, target := svc.emailToVerify(user)
+	err = svc.consumeVerificationCode(ctx, user, model.UserTokenType_EmailVerificationCode, target, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PendingEmail != "" {
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.Normalize(svc.userNormalizer)
+		// Another account may have taken the address since the change was requested
+		err = svc.checkDuplicateEmail(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+	user.EmailVerified = true
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.GetUserById(ctx, user.Id)
+}
+
+func (svc *service) SendPhoneVerification(ctx context.Context, userId string) error {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Phone == "" {
+		return auth.ErrNoPhone
+	}
+	if user.PhoneVerified {
+		return auth.ErrAlreadyVerified
+	}
+
+	lifetime := time.Duration(svc.verification.PhoneCodeLifetimeMinutes) * time.Minute
+	code, err := svc.createVerificationCode(ctx, user, model.UserTokenType_PhoneVerificationCode, user.Phone, lifetime)
+	if err != nil {
+		return err
+	}
+
+	return svc.smsSender.SendSms(ctx, &notification.SmsMessage{
+		To: user.Phone,
+		Body: fmt.Sprintf("Your verification code is %s. The code is valid for %d minutes.",
+			code,
+			svc.verification.PhoneCodeLifetimeMinutes,
+		),
+	})
+}
+
+func (svc *service) VerifyPhone(ctx context.Context, userId string, code string) (*model.User, error) {
+	user, err := svc.verifyPhone(ctx, userId, code)
+	svc.audit(ctx, model.AuditEventPhoneVerified, userId, err, nil)
+	return user, err
+}
+
+func (svc *service) verifyPhone(ctx context.Context, userId string, code string) (*model.User, error) {
+	user, err := svc.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.consumeVerificationCode(ctx, user, model.UserTokenType_PhoneVerificationCode, user.Phone, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PhoneVerified = true
+	err = svc.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.GetUserById(ctx, user.Id)
+}
+
+// createVerificationCode replaces the code of the given type with a new one. A new code for the
+// same target can only be requested once per resend interval, and only a few codes per day, to
+// limit the messages sent.
+func (svc *service) createVerificationCode(ctx context.Context, user *model.User, tokenType model.UserTokenType, target string, lifetime time.Duration) (string, error) {
+	if svc.hasRecentUserToken(user, tokenType, target) {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Verification code requested too recently",
+			slog.String("id", user.Id),
+			slog.String("type", tokenType.String()),
+		)
+		return "", auth.ErrVerificationRateLimited
+	}
+	err := svc.checkVerificationLimit(ctx, user, model.AuditEventCodeSent, model.AuditOutcomeSuccess, svc.verification.MaxCodesPerDay)
+	if err != nil {
+		return "", err
+	}
+
+	err = svc.deleteUserTokens(ctx, user, tokenType)
+	if err != nil {
+		return "", err
+	}
+
+	code, hash, err := security.GenerateVerificationCode(svc.verification.CodeLength, svc.codeHashKey)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to generate verification code",
+			slog.String("id", user.Id),
+			slog.String("type", tokenType.String()),
+			slog.Any("error", err),
+		)
+		return "", err
+	}
+
+	userToken := &model.UserToken{
+		Type:   tokenType,
+		Token:  hash,
+		Target: target,
+	}
+	userToken.SetExpiration(lifetime)
+	err = svc.insertUserToken(ctx, user, userToken)
+	if err != nil {
+		return "", err
+	}
+	svc.audit(ctx, model.AuditEventCodeSent, user.Id, nil, map[string]string{
+		"type": tokenType.String(),
+	})
+
+	return code, nil
+}
+
+// consumeVerificationCode validates the code and deletes it. The code is only valid for the target
+// it was sent to, and it is discarded after too many wrong attempts. The wrong attempts of all codes
+// of the user are limited per day as well, so requesting new codes doesn't allow more guesses.
+func (svc *service) consumeVerificationCode(ctx context.Context, user *model.User, tokenType model.UserTokenType, target string, code string) error {
+	err := svc.checkVerificationLimit(ctx, user, verificationAuditEvent(tokenType), model.AuditOutcomeFailure, svc.verification.MaxFailedPerDay)
+	if err != nil {
+		return err
+	}
+
+	var userToken *model.UserToken
+	for _, candidate := range user.Tokens {
+		if candidate.Type == tokenType {
+			userToken = candidate
+			break
+		}
+	}
+	if userToken == nil {
+		return auth.ErrInvalidToken
+	}
+	if userToken.Target != target {
+		// The address changed after the code was sent
+		err := svc.deleteUserToken(ctx, user, userToken)
+		if err != nil {
+			return err
+		}
+		return auth.ErrInvalidToken
+	}
+	if userToken.HasExpired() {
+		err := svc.deleteUserToken(ctx, user, userToken)
+		if err != nil {
+			return err
+		}
+		return auth.ErrTokenExpired
+	}
+
+	if !security.VerifyVerificationCode(strings.TrimSpace(code), userToken.Token, svc.codeHashKey) {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Invalid verification code",
+			slog.String("id", user.Id),
+			slog.String("type", tokenType.String()),
+		)
+		userToken.Attempts++
+		if userToken.Attempts >= svc.verification.MaxAttempts {
+			err := svc.deleteUserToken(ctx, user, userToken)
+			if err != nil {
+				return err
+			}
+			return auth.ErrInvalidToken
+		}
+		err := svc.database.UserTokens().UpdateUserToken(ctx, user, userToken)
+		if err != nil {
+			logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update user token in database",
+				slog.String("id", user.Id),
+				slog.String("type", tokenType.String()),
+				slog.Any("error", err),
+			)
+			return err
+		}
+		return auth.ErrInvalidToken
+	}
+
+	return svc.deleteUserToken(ctx, user, userToken)
+}
+
+// checkVerificationLimit counts the audit events of the user in the last 24 hours, the audit log is
+// shared by all instances and survives the codes themselves.
+func (svc *service) checkVerificationLimit(ctx context.Context, user *model.User, eventType model.AuditEventType, outcome model.AuditOutcome, limit int) error {
+	_, count, err := svc.GetAuditEvents(ctx, 0, 1, &model.AuditEventFilter{
+		Type:      eventType,
+		SubjectId: user.Id,
+		Outcome:   outcome,
+		From:      time.Now().UTC().Add(-verificationLimitWindow),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Verification limit reached",
+			slog.String("id", user.Id),
+			slog.String("event", string(eventType)),
+		)
+		return auth.ErrVerificationLimited
+	}
+	return nil
+}
+
+// emailToVerify returns the address to verify and its normalized form, which the code is bound to.
+func (svc *service) emailToVerify(user *model.User) (string, string) {
+	if user.PendingEmail != "" {
+		return user.PendingEmail, svc.userNormalizer.NormalizeEmail(user.PendingEmail)
+	}
+	return user.Email, user.NormalizedEmail
+}
+
+func verificationAuditEvent(tokenType model.UserTokenType) model.AuditEventType {
+	if tokenType == model.UserTokenType_PhoneVerificationCode {
+		return model.AuditEventPhoneVerified
+	}
+	return model.AuditEventEmailVerified
+}
+
+func verificationUrl(baseUrl string, userId string, code string) string {
+	query := url.Values{}
+	query.Set("user", userId)
+	query.Set("code", code)
+	return baseUrl + "?" + query.Encode()
+}
@@ -7,6 +7,7 @@ import (
 type UserNormalizer interface {
 	NormalizeUsername(username string) string
 	NormalizeEmail(email string) string
+	NormalizeRoleName(name string) string
 }
 
 type defaultUserNormalizer struct {
@@ -23,3 +24,7 @@ func (n *defaultUserNormalizer) NormalizeUsername(username string) string {
 func (n *defaultUserNormalizer) NormalizeEmail(email string) string {
 	return strings.ToUpper(email)
 }
+
+func (n *defaultUserNormalizer) NormalizeRoleName(name string) string {
+	return strings.ToUpper(name)
+}
@@ -77,6 +77,10 @@ func (api *apiV1) RegisterRoutes(r *router.Router) {
 		router.AllowedMethod(http.MethodDelete),
 		router.Authorized(PolicyDeleteImages),
 	)
+	r.HandleFunc("/v1/image/{id}/url", api.GetImageUrlHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyReadImages),
+	)
 	r.HandleFunc("/v1/image/{id}/upload", api.UploadImageFileHandlerV1,
 		router.AllowedMethod(http.MethodPost),
 		router.Authorized(PolicyUploadImages),
@@ -99,10 +103,22 @@ func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
 		rest.WriteError(w, http.StatusNotFound, err.Error())
 	case gallery.ErrImageFormatNotSupported:
 		rest.WriteError(w, http.StatusUnsupportedMediaType, err.Error())
+	case gallery.ErrImageFormatMismatch:
+		rest.WriteError(w, http.StatusUnsupportedMediaType, err.Error())
+	case gallery.ErrImageDataInvalid:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case gallery.ErrImageTooLarge:
+		rest.WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
 	case gallery.ErrImageDuplicateName:
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	case gallery.ErrImageDuplicateSlug:
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case gallery.ErrImageVisibilityInvalid:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
+	case gallery.ErrRenditionNotFound:
+		rest.WriteError(w, http.StatusNotFound, err.Error())
+	case gallery.ErrRenditionInvalid:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	case core.ErrTranslationNotFound:
 		rest.WriteError(w, http.StatusNotFound, err.Error())
 	case core.ErrInvalidId:
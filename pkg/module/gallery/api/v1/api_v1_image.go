@@ -2,23 +2,36 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
 	"github.com/deb-ict/cloudbm-community/pkg/localization"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
 	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
 	"github.com/deb-ict/go-router"
 )
 
+// Room for the multipart boundaries and headers around the uploaded file
+const multipartOverhead int64 = 1 << 20
+
 type ImageV1 struct {
 	Id           string                `json:"id"`
 	Translations []*ImageTranslationV1 `json:"translations"`
+	Visibility   string                `json:"visibility"`
 	FileName     string                `json:"fileName"`
 	FileSize     int64                 `json:"fileSize"`
 	MimeType     string                `json:"fileType"`
+	ContentHash  string                `json:"contentHash,omitempty"`
+	UploadedAt   *time.Time            `json:"uploadedAt,omitempty"`
 	Width        int32                 `json:"width"`
 	Height       int32                 `json:"height"`
+	CapturedAt   *time.Time            `json:"capturedAt,omitempty"`
+	CameraMake   string                `json:"cameraMake,omitempty"`
+	CameraModel  string                `json:"cameraModel,omitempty"`
+	Orientation  int32                 `json:"orientation,omitempty"`
 }
 
 type ImageTranslationV1 struct {
@@ -35,25 +48,33 @@ type ImageListV1 struct {
 }
 
 type ImageListItemV1 struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	Slug     string `json:"slug"`
-	Summary  string `json:"summary"`
-	FileName string `json:"fileName"`
-	FileSize int64  `json:"fileSize"`
-	MimeType string `json:"fileType"`
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Summary    string `json:"summary"`
+	Visibility string `json:"visibility"`
+	FileName   string `json:"fileName"`
+	FileSize   int64  `json:"fileSize"`
+	MimeType   string `json:"fileType"`
 }
 
 type CreateImageV1 struct {
 	Translations []*ImageTranslationV1 `json:"translations"`
+	Visibility   string                `json:"visibility"`
 	FileName     string                `json:"fileName"`
 }
 
 type UpdateImageV1 struct {
 	Translations []*ImageTranslationV1 `json:"translations"`
+	Visibility   string                `json:"visibility"`
 	FileName     string                `json:"fileName"`
 }
 
+type ImageUrlV1 struct {
+	Url       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
 func (api *apiV1) GetImagesHandlerV1(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -150,7 +171,13 @@ func (api *apiV1) UploadImageFileHandlerV1(w http.ResponseWriter, r *http.Reques
 
 	id := router.Param(r, "id")
 
+	// The body is limited before it is parsed, otherwise an oversized upload is spooled to disk first
+	r.Body = http.MaxBytesReader(w, r.Body, api.service.MaxFileSize()+multipartOverhead)
 	err := r.ParseMultipartForm(10 << 20)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		err = gallery.ErrImageTooLarge
+	}
 	if api.handleError(w, err) {
 		return
 	}
@@ -187,6 +214,19 @@ func (api *apiV1) DownloadImageFileHandlerV1(w http.ResponseWriter, r *http.Requ
 	_, _ = io.Copy(w, file)
 }
 
+func (api *apiV1) GetImageUrlHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := router.Param(r, "id")
+	result, err := api.service.GetImageUrl(ctx, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := ImageUrlToViewModelV1(result)
+	rest.WriteResult(w, response)
+}
+
 func (api *apiV1) parseImageFilterV1(r *http.Request) *model.ImageFilter {
 	return &model.ImageFilter{
 		Language: localization.GetHttpRequestLanguage(r, api.service.LanguageProvider()),
@@ -198,11 +238,24 @@ func ImageToViewModelV1(model *model.Image) *ImageV1 {
 	viewModel := &ImageV1{
 		Id:           model.Id,
 		Translations: make([]*ImageTranslationV1, 0),
+		Visibility:   string(model.Visibility),
 		FileName:     model.FileName,
 		FileSize:     model.FileSize,
 		MimeType:     model.MimeType,
+		ContentHash:  model.ContentHash,
 		Width:        model.Width,
 		Height:       model.Height,
+		CameraMake:   model.CameraMake,
+		CameraModel:  model.CameraModel,
+		Orientation:  model.Orientation,
+	}
+	if !model.UploadedAt.IsZero() {
+		uploadedAt := model.UploadedAt
+		viewModel.UploadedAt = &uploadedAt
+	}
+	if !model.CapturedAt.IsZero() {
+		capturedAt := model.CapturedAt
+		viewModel.CapturedAt = &capturedAt
 	}
 	for _, translation := range model.Translations {
 		viewModel.Translations = append(viewModel.Translations, ImageTranslationToViewModelV1(translation))
@@ -213,18 +266,20 @@ func ImageToViewModelV1(model *model.Image) *ImageV1 {
 func ImageToListItemViewModelV1(model *model.Image, language string, defaultLanguage string) *ImageListItemV1 {
 	translation := model.GetTranslation(language, defaultLanguage)
 	return &ImageListItemV1{
-		Id:       model.Id,
-		Name:     translation.Name,
-		Slug:     translation.Slug,
-		Summary:  translation.Summary,
-		FileName: model.OriginalFileName,
-		FileSize: model.FileSize,
-		MimeType: model.MimeType,
+		Id:         model.Id,
+		Name:       translation.Name,
+		Slug:       translation.Slug,
+		Summary:    translation.Summary,
+		Visibility: string(model.Visibility),
+		FileName:   model.OriginalFileName,
+		FileSize:   model.FileSize,
+		MimeType:   model.MimeType,
 	}
 }
 
 func ImageFromCreateViewModelV1(viewModel *CreateImageV1) *model.Image {
 	model := &model.Image{
+		Visibility:       model.ImageVisibility(viewModel.Visibility),
 		OriginalFileName: viewModel.FileName,
 		Translations:     make([]*model.ImageTranslation, 0),
 	}
@@ -236,6 +291,7 @@ func ImageFromCreateViewModelV1(viewModel *CreateImageV1) *model.Image {
 
 func ImageFromUpdateViewModelV1(viewModel *UpdateImageV1) *model.Image {
 	model := &model.Image{
+		Visibility:       model.ImageVisibility(viewModel.Visibility),
 		OriginalFileName: viewModel.FileName,
 		Translations:     make([]*model.ImageTranslation, 0),
 	}
@@ -245,6 +301,17 @@ func ImageFromUpdateViewModelV1(viewModel *UpdateImageV1) *model.Image {
 	return model
 }
 
+func ImageUrlToViewModelV1(model *model.ImageUrl) *ImageUrlV1 {
+	viewModel := &ImageUrlV1{
+		Url: model.Url,
+	}
+	if !model.ExpiresAt.IsZero() {
+		expiresAt := model.ExpiresAt
+		viewModel.ExpiresAt = &expiresAt
+	}
+	return viewModel
+}
+
 func ImageTranslationToViewModelV1(model *model.ImageTranslation) *ImageTranslationV1 {
 	return &ImageTranslationV1{
 		Language:    model.Language,
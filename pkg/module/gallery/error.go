@@ -6,6 +6,15 @@ var (
 	ErrImageNotFound           error = errors.New("image not found")
 	ErrImageFileNotFound       error = errors.New("image file not found")
 	ErrImageFormatNotSupported error = errors.New("image format not supported")
+	ErrImageFormatMismatch     error = errors.New("image data does not match the format")
+	ErrImageDataInvalid        error = errors.New("image data invalid")
+	ErrImageTooLarge           error = errors.New("image too large")
 	ErrImageDuplicateName      error = errors.New("image with same name exists")
 	ErrImageDuplicateSlug      error = errors.New("image with same slug exists")
+	ErrImageVisibilityInvalid  error = errors.New("image visibility invalid")
+	ErrImageUrlInvalid         error = errors.New("image url signature invalid")
+	ErrImageUrlExpired         error = errors.New("image url expired")
+	ErrUrlSigningSecretMissing error = errors.New("url signing secret not configured")
+	ErrRenditionNotFound       error = errors.New("image rendition not found")
+	ErrRenditionInvalid        error = errors.New("image rendition invalid")
 )
@@ -0,0 +1,328 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	OrientationNormal         int = 1
+	OrientationFlipHorizontal int = 2
+	OrientationRotate180      int = 3
+	OrientationFlipVertical   int = 4
+	OrientationTranspose      int = 5
+	OrientationRotate90       int = 6
+	OrientationTransverse     int = 7
+	OrientationRotate270      int = 8
+)
+
+const (
+	exifHeader                string = "Exif\x00\x00"
+	exifDateTimeLayout        string = "2006:01:02 15:04:05"
+	exifTagMake               uint16 = 0x010f
+	exifTagModel              uint16 = 0x0110
+	exifTagOrientation        uint16 = 0x0112
+	exifTagDateTime           uint16 = 0x0132
+	exifTagExifIfd            uint16 = 0x8769
+	exifTagDateTimeOriginal   uint16 = 0x9003
+	exifTagOffsetTimeOriginal uint16 = 0x9011
+	exifTypeAscii             uint16 = 2
+	exifTypeShort             uint16 = 3
+	exifTypeLong              uint16 = 4
+	maxExifSegmentLength      int    = 1 << 16
+)
+
+var (
+	ErrExifInvalid error = errors.New("exif invalid")
+)
+
+// Metadata holds the exif fields kept for an image, fields are empty when the image doesn't have them.
+type Metadata struct {
+	// Capture date, in UTC when the camera didn't record the time zone
+	CapturedAt  time.Time
+	CameraMake  string
+	CameraModel string
+	// Exif orientation from 1 to 8, 0 when unknown
+	Orientation int
+}
+
+// IsTransposed returns true when the orientation swaps the width and height of the image.
+func IsTransposed(orientation int) bool {
+	return orientation >= OrientationTranspose && orientation <= OrientationRotate270
+}
+
+// DecodeMetadata reads the exif metadata of a jpeg, png or webp image. Images without exif return
+// empty metadata, other formats aren't read.
+func DecodeMetadata(r io.Reader, mimeType string) (*Metadata, error) {
+	var payload []byte
+	var err error
+	switch mimeType {
+	case MimeTypeJpeg:
+		payload, err = readJpegExif(bufio.NewReader(r))
+	case MimeTypePng:
+		payload, err = readPngChunk(r, "eXIf")
+	case MimeTypeWebp:
+		payload, err = readWebpChunk(r, "EXIF")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		return &Metadata{}, nil
+	}
+	return parseExif(bytes.TrimPrefix(payload, []byte(exifHeader)))
+}
+
+func readJpegExif(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, jpegMarkerSoi} {
+		return nil, ErrExifInvalid
+	}
+	for {
+		marker, data, err := readJpegSegment(r)
+		if err != nil {
+			return nil, err
+		}
+		if marker == jpegMarkerSos || marker == jpegMarkerEoi {
+			return nil, nil
+		}
+		if marker == jpegMarkerApp1 && bytes.HasPrefix(data, []byte(exifHeader)) {
+			return data, nil
+		}
+	}
+}
+
+func readPngChunk(r io.Reader, chunkType string) ([]byte, error) {
+	var signature [8]byte
+	if _, err := io.ReadFull(r, signature[:]); err != nil || string(signature[:]) != pngSignature {
+		return nil, ErrExifInvalid
+	}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, ErrExifInvalid
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		switch string(header[4:]) {
+		case chunkType:
+			if length > int64(maxExifSegmentLength) {
+				return nil, ErrExifInvalid
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, ErrExifInvalid
+			}
+			return data, nil
+		case "IDAT", "IEND":
+			// Exif must come before the image data
+			return nil, nil
+		}
+		if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+			return nil, ErrExifInvalid
+		}
+	}
+}
+
+func readWebpChunk(r io.Reader, chunkType string) ([]byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil, ErrExifInvalid
+	}
+	for {
+		var chunk [8]byte
+		_, err := io.ReadFull(r, chunk[:])
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, ErrExifInvalid
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == chunkType {
+			if length > int64(maxExifSegmentLength) {
+				return nil, ErrExifInvalid
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, ErrExifInvalid
+			}
+			return data, nil
+		}
+		// Chunks are padded to an even length
+		if _, err := io.CopyN(io.Discard, r, length+length%2); err != nil {
+			return nil, ErrExifInvalid
+		}
+	}
+}
+
+// exifReader reads the entries of the image file directories in a tiff structure.
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type exifEntry struct {
+	tag       uint16
+	valueType uint16
+	count     uint32
+	value     []byte
+}
+
+func parseExif(data []byte) (*Metadata, error) {
+	if len(data) < 8 {
+		return nil, ErrExifInvalid
+	}
+	reader := &exifReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return nil, ErrExifInvalid
+	}
+	if reader.order.Uint16(data[2:4]) != 42 {
+		return nil, ErrExifInvalid
+	}
+
+	metadata := &Metadata{}
+	entries, err := reader.readIfd(reader.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+	var dateTime, offsetTime string
+	for _, entry := range entries {
+		switch entry.tag {
+		case exifTagMake:
+			metadata.CameraMake = entry.ascii()
+		case exifTagModel:
+			metadata.CameraModel = entry.ascii()
+		case exifTagOrientation:
+			if orientation := int(reader.short(entry)); orientation >= OrientationNormal && orientation <= OrientationRotate270 {
+				metadata.Orientation = orientation
+			}
+		case exifTagDateTime:
+			dateTime = entry.ascii()
+		case exifTagExifIfd:
+			exifEntries, err := reader.readIfd(reader.long(entry))
+			if err != nil {
+				return nil, err
+			}
+			for _, exifEntry := range exifEntries {
+				switch exifEntry.tag {
+				case exifTagDateTimeOriginal:
+					dateTime = exifEntry.ascii()
+				case exifTagOffsetTimeOriginal:
+					offsetTime = exifEntry.ascii()
+				}
+			}
+		}
+	}
+	metadata.CapturedAt = parseExifDateTime(dateTime, offsetTime)
+
+	return metadata, nil
+}
+
+func (r *exifReader) readIfd(offset uint32) ([]*exifEntry, error) {
+	if int64(offset)+2 > int64(len(r.data)) {
+		return nil, ErrExifInvalid
+	}
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(r.data) {
+		return nil, ErrExifInvalid
+	}
+
+	entries := make([]*exifEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := r.data[start+i*12 : start+i*12+12]
+		entry := &exifEntry{
+			tag:       r.order.Uint16(raw[0:2]),
+			valueType: r.order.Uint16(raw[2:4]),
+			count:     r.order.Uint32(raw[4:8]),
+		}
+		var size int64
+		switch entry.valueType {
+		case exifTypeAscii:
+			size = int64(entry.count)
+		case exifTypeShort:
+			size = 2 * int64(entry.count)
+		case exifTypeLong:
+			size = 4 * int64(entry.count)
+		default:
+			// Other types aren't used
+			continue
+		}
+		// Values up to 4 bytes are stored in the entry, larger values at an offset
+		if size <= 4 {
+			entry.value = raw[8 : 8+size]
+		} else {
+			valueOffset := int64(r.order.Uint32(raw[8:12]))
+			if valueOffset+size > int64(len(r.data)) {
+				continue
+			}
+			entry.value = r.data[valueOffset : valueOffset+size]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (r *exifReader) short(entry *exifEntry) uint16 {
+	if entry.valueType != exifTypeShort || len(entry.value) < 2 {
+		return 0
+	}
+	return r.order.Uint16(entry.value)
+}
+
+func (r *exifReader) long(entry *exifEntry) uint32 {
+	if entry.valueType != exifTypeLong || len(entry.value) < 4 {
+		return 0
+	}
+	return r.order.Uint32(entry.value)
+}
+
+func (e *exifEntry) ascii() string {
+	if e.valueType != exifTypeAscii {
+		return ""
+	}
+	value, _, _ := bytes.Cut(e.value, []byte{0})
+	return strings.TrimSpace(string(value))
+}
+
+func parseExifDateTime(dateTime string, offsetTime string) time.Time {
+	if dateTime == "" {
+		return time.Time{}
+	}
+	if offsetTime != "" {
+		value, err := time.Parse(exifDateTimeLayout+"-07:00", dateTime+offsetTime)
+		if err == nil {
+			return value.UTC()
+		}
+	}
+	value, err := time.Parse(exifDateTimeLayout, dateTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return value
+}
+
+// encodeExif returns a tiff structure with only the orientation.
+func encodeExif(orientation int) []byte {
+	data := make([]byte, 0, 26)
+	data = append(data, "MM\x00\x2a"...)
+	data = binary.BigEndian.AppendUint32(data, 8)
+	data = binary.BigEndian.AppendUint16(data, 1)
+	data = binary.BigEndian.AppendUint16(data, exifTagOrientation)
+	data = binary.BigEndian.AppendUint16(data, exifTypeShort)
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = binary.BigEndian.AppendUint16(data, uint16(orientation))
+	data = binary.BigEndian.AppendUint16(data, 0)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	return data
+}
@@ -0,0 +1,250 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testExifEntry struct {
+	tag       uint16
+	valueType uint16
+	value     []byte
+	// Index of the directory the entry points to, for the exif and gps pointers
+	ifd int
+}
+
+// buildTestExif writes a little endian tiff structure with a camera, capture date and gps location.
+func buildTestExif() []byte {
+	ascii := func(value string) []byte { return append([]byte(value), 0) }
+	short := func(value uint16) []byte { return binary.LittleEndian.AppendUint16(nil, value) }
+	ifds := [][]testExifEntry{
+		{
+			{exifTagMake, exifTypeAscii, ascii("Canon"), 0},
+			{exifTagModel, exifTypeAscii, ascii("EOS R6"), 0},
+			{exifTagOrientation, exifTypeShort, short(6), 0},
+			{exifTagDateTime, exifTypeAscii, ascii("2024:06:01 00:00:00"), 0},
+			{exifTagExifIfd, exifTypeLong, nil, 1},
+			{0x8825, exifTypeLong, nil, 2},
+		},
+		{
+			{exifTagDateTimeOriginal, exifTypeAscii, ascii("2024:05:01 10:20:30"), 0},
+			{exifTagOffsetTimeOriginal, exifTypeAscii, ascii("+02:00"), 0},
+			{0xa431, exifTypeAscii, ascii("SERIAL-123456"), 0},
+		},
+		{
+			{0x0001, exifTypeAscii, ascii("N"), 0},
+			{0x0002, exifTypeAscii, ascii("GPS-LATITUDE"), 0},
+		},
+	}
+
+	offsets := make([]uint32, len(ifds))
+	offset := uint32(8)
+	for i, entries := range ifds {
+		offsets[i] = offset
+		offset += uint32(2 + len(entries)*12 + 4)
+	}
+
+	data := []byte("II\x2a\x00")
+	data = binary.LittleEndian.AppendUint32(data, offsets[0])
+	values := make([]byte, 0)
+	for _, entries := range ifds {
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(entries)))
+		for _, entry := range entries {
+			value := entry.value
+			if entry.ifd > 0 {
+				value = binary.LittleEndian.AppendUint32(nil, offsets[entry.ifd])
+			}
+			count := len(value)
+			if entry.valueType == exifTypeShort {
+				count /= 2
+			} else if entry.valueType == exifTypeLong {
+				count /= 4
+			}
+			data = binary.LittleEndian.AppendUint16(data, entry.tag)
+			data = binary.LittleEndian.AppendUint16(data, entry.valueType)
+			data = binary.LittleEndian.AppendUint32(data, uint32(count))
+			if len(value) <= 4 {
+				data = append(data, append(value, make([]byte, 4-len(value))...)...)
+			} else {
+				data = binary.LittleEndian.AppendUint32(data, offset+uint32(len(values)))
+				values = append(values, value...)
+			}
+		}
+		// No next directory
+		data = binary.LittleEndian.AppendUint32(data, 0)
+	}
+	return append(data, values...)
+}
+
+func newTestPhoto() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 16), G: uint8(y * 32), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeTestJpeg(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buffer, newTestPhoto(), nil))
+	encoded := buffer.Bytes()
+
+	// Insert the exif and a comment after the start of image marker
+	var output bytes.Buffer
+	output.Write(encoded[:2])
+	assert.NoError(t, writeJpegSegment(&output, jpegMarkerApp1, append([]byte(exifHeader), buildTestExif()...)))
+	assert.NoError(t, writeJpegSegment(&output, jpegMarkerCom, []byte("Taken at home")))
+	output.Write(encoded[2:])
+	return output.Bytes()
+}
+
+func encodeTestPng(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	assert.NoError(t, png.Encode(&buffer, newTestPhoto()))
+	encoded := buffer.Bytes()
+
+	// The header chunk ends after the signature, length, type, 13 bytes of data and the crc
+	headerEnd := 8 + 8 + 13 + 4
+	var output bytes.Buffer
+	output.Write(encoded[:headerEnd])
+	assert.NoError(t, writePngChunk(&output, "eXIf", buildTestExif()))
+	assert.NoError(t, writePngChunk(&output, "tEXt", []byte("Author\x00Jane Doe")))
+	output.Write(encoded[headerEnd:])
+	return output.Bytes()
+}
+
+func encodeTestWebp(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	assert.NoError(t, Encode(&buffer, newTestPhoto(), MimeTypeWebp))
+	encoded := buffer.Bytes()
+
+	// Wrap the image in an extended container with exif and xmp
+	extended := []byte{0x0c, 0, 0, 0}
+	extended = append(extended, 15, 0, 0, 7, 0, 0)
+	var chunks bytes.Buffer
+	writeWebpChunk(&chunks, "VP8X", extended)
+	chunks.Write(encoded[12:])
+	writeWebpChunk(&chunks, "EXIF", buildTestExif())
+	writeWebpChunk(&chunks, "XMP ", []byte("<x:xmpmeta>GPS-LATITUDE</x:xmpmeta>"))
+
+	output := []byte("RIFF")
+	output = binary.LittleEndian.AppendUint32(output, uint32(4+chunks.Len()))
+	output = append(output, "WEBP"...)
+	return append(output, chunks.Bytes()...)
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"jpeg", encodeTestJpeg(t), MimeTypeJpeg},
+		{"png", encodeTestPng(t), MimeTypePng},
+		{"webp", encodeTestWebp(t), MimeTypeWebp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := DecodeMetadata(bytes.NewReader(tt.data), tt.mimeType)
+			assert.NoError(t, err)
+			assert.Equal(t, &Metadata{
+				CapturedAt:  time.Date(2024, 5, 1, 8, 20, 30, 0, time.UTC),
+				CameraMake:  "Canon",
+				CameraModel: "EOS R6",
+				Orientation: OrientationRotate90,
+			}, metadata)
+		})
+	}
+
+	var plain bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&plain, newTestPhoto(), nil))
+	metadata, err := DecodeMetadata(&plain, MimeTypeJpeg)
+	assert.NoError(t, err)
+	assert.Equal(t, &Metadata{}, metadata)
+
+	_, err = DecodeMetadata(bytes.NewReader([]byte("\xff\xd8\xff\xe1\x00\x10Exif\x00\x00XX\x2a\x00")), MimeTypeJpeg)
+	assert.Error(t, err)
+}
+
+func TestStripMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"jpeg", encodeTestJpeg(t), MimeTypeJpeg},
+		{"png", encodeTestPng(t), MimeTypePng},
+		{"webp", encodeTestWebp(t), MimeTypeWebp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := StripMetadata(bytes.NewReader(tt.data), &output, tt.mimeType, OrientationRotate90)
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, personal := range []string{"Canon", "SERIAL", "GPS-LATITUDE", "Jane Doe", "Taken at home"} {
+				assert.NotContains(t, output.String(), personal)
+			}
+
+			// Only the orientation is kept
+			metadata, err := DecodeMetadata(bytes.NewReader(output.Bytes()), tt.mimeType)
+			assert.NoError(t, err)
+			assert.Equal(t, &Metadata{Orientation: OrientationRotate90}, metadata)
+
+			img, err := Decode(bytes.NewReader(output.Bytes()))
+			assert.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 16, 8), img.Bounds())
+		})
+	}
+
+	var output bytes.Buffer
+	err := StripMetadata(bytes.NewReader(encodeTestJpeg(t)), &output, MimeTypeJpeg, OrientationNormal)
+	assert.NoError(t, err)
+	assert.NotContains(t, output.String(), exifHeader)
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image with the top left pixel marked
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	marked := color.NRGBA{R: 255, A: 255}
+	src.SetNRGBA(0, 0, marked)
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		x           int
+		y           int
+	}{
+		{OrientationNormal, 3, 2, 0, 0},
+		{OrientationFlipHorizontal, 3, 2, 2, 0},
+		{OrientationRotate180, 3, 2, 2, 1},
+		{OrientationFlipVertical, 3, 2, 0, 1},
+		{OrientationTranspose, 2, 3, 0, 0},
+		{OrientationRotate90, 2, 3, 1, 0},
+		{OrientationTransverse, 2, 3, 1, 2},
+		{OrientationRotate270, 2, 3, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.orientation), func(t *testing.T) {
+			dst := Orient(src, tt.orientation)
+			assert.Equal(t, image.Rect(0, 0, tt.width, tt.height), dst.Bounds())
+			assert.Equal(t, marked, color.NRGBAModel.Convert(dst.At(tt.x, tt.y)))
+		})
+	}
+}
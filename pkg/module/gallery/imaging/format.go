@@ -0,0 +1,224 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MimeTypeJpeg string = "image/jpeg"
+	MimeTypePng  string = "image/png"
+	MimeTypeGif  string = "image/gif"
+	MimeTypeWebp string = "image/webp"
+	MimeTypeSvg  string = "image/svg+xml"
+)
+
+var fileExtensions = map[string]string{
+	MimeTypeJpeg: ".jpg",
+	MimeTypePng:  ".png",
+	MimeTypeGif:  ".gif",
+	MimeTypeWebp: ".webp",
+	MimeTypeSvg:  ".svg",
+}
+
+// Number of bytes DetectFormat needs to recognize every supported format
+const DetectFormatLength int = 512
+
+var magicNumbers = []struct {
+	mimeType string
+	offset   int
+	magic    []byte
+}{
+	{MimeTypeJpeg, 0, []byte{0xff, 0xd8, 0xff}},
+	{MimeTypePng, 0, []byte("\x89PNG\r\n\x1a\n")},
+	{MimeTypeGif, 0, []byte("GIF87a")},
+	{MimeTypeGif, 0, []byte("GIF89a")},
+	{MimeTypeWebp, 8, []byte("WEBP")},
+}
+
+// DetectFormat returns the format of the data from its first bytes, an empty string when the format
+// isn't supported. Svg is recognized by an svg root element near the start of the document.
+func DetectFormat(header []byte) string {
+	for _, magic := range magicNumbers {
+		if len(header) >= magic.offset+len(magic.magic) && bytes.Equal(header[magic.offset:magic.offset+len(magic.magic)], magic.magic) {
+			if magic.mimeType == MimeTypeWebp && !bytes.HasPrefix(header, []byte("RIFF")) {
+				continue
+			}
+			return magic.mimeType
+		}
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(text, []byte("<")) && bytes.Contains(text, []byte("<svg")) {
+		return MimeTypeSvg
+	}
+	return ""
+}
+
+// FileExtension returns the extension files of the format are stored with, false when the format isn't supported.
+func FileExtension(mimeType string) (string, bool) {
+	ext, ok := fileExtensions[mimeType]
+	return ext, ok
+}
+
+// IsVector returns true for formats that scale without rendering, they have no renditions.
+func IsVector(mimeType string) bool {
+	return mimeType == MimeTypeSvg
+}
+
+// RenditionFormat returns the format a rendition is written in. Only jpeg keeps its format, the other
+// formats are written as png, as animations are not kept and webp is only written when requested.
+// Webp is written lossless, which is larger than a jpeg photo, so jpeg renditions are never webp.
+func RenditionFormat(mimeType string, requested string) string {
+	if mimeType == MimeTypeJpeg {
+		return MimeTypeJpeg
+	}
+	if requested == MimeTypeWebp {
+		return MimeTypeWebp
+	}
+	return MimeTypePng
+}
+
+// DecodeConfig reads the dimensions of the image, for a gif the dimensions of the first frame.
+func DecodeConfig(r io.Reader, mimeType string) (image.Config, error) {
+	switch mimeType {
+	case MimeTypeSvg:
+		return DecodeSvgConfig(r)
+	case MimeTypeGif:
+		return decodeGifConfig(r)
+	}
+	if _, ok := FileExtension(mimeType); !ok {
+		return image.Config{}, ErrFormatNotSupported
+	}
+
+	config, format, err := image.DecodeConfig(r)
+	if err == image.ErrFormat {
+		return image.Config{}, ErrFormatNotSupported
+	}
+	if err != nil {
+		return image.Config{}, err
+	}
+	if "image/"+format != mimeType {
+		return image.Config{}, ErrFormatNotSupported
+	}
+	return config, nil
+}
+
+// Blocks of a gif file, see https://www.w3.org/Graphics/GIF/spec-gif89a.txt
+const (
+	gifExtensionIntroducer byte = 0x21
+	gifImageSeparator      byte = 0x2c
+	gifColorTableFlag      byte = 0x80
+)
+
+var (
+	ErrGifInvalid error = errors.New("gif invalid")
+)
+
+// decodeGifConfig reads the logical screen and the descriptor of the first frame without decoding
+// any pixels. Decoding the frame would allocate it at the size the header claims, before it could be
+// checked. Frames outside of the logical screen are refused, as the decoder refuses them as well.
+func decodeGifConfig(r io.Reader) (image.Config, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, 13)
+	_, err := io.ReadFull(br, header)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if !bytes.HasPrefix(header, []byte("GIF87a")) && !bytes.HasPrefix(header, []byte("GIF89a")) {
+		return image.Config{}, ErrFormatNotSupported
+	}
+	screenWidth := int(binary.LittleEndian.Uint16(header[6:8]))
+	screenHeight := int(binary.LittleEndian.Uint16(header[8:10]))
+	palette, err := readGifColorTable(br, header[10])
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	for {
+		block, err := br.ReadByte()
+		if err != nil {
+			return image.Config{}, err
+		}
+		switch block {
+		case gifExtensionIntroducer:
+			// Skip the label and the data sub-blocks
+			_, err = br.ReadByte()
+			if err != nil {
+				return image.Config{}, err
+			}
+			err = skipGifSubBlocks(br)
+			if err != nil {
+				return image.Config{}, err
+			}
+		case gifImageSeparator:
+			descriptor := make([]byte, 9)
+			_, err = io.ReadFull(br, descriptor)
+			if err != nil {
+				return image.Config{}, err
+			}
+			left := int(binary.LittleEndian.Uint16(descriptor[0:2]))
+			top := int(binary.LittleEndian.Uint16(descriptor[2:4]))
+			width := int(binary.LittleEndian.Uint16(descriptor[4:6]))
+			height := int(binary.LittleEndian.Uint16(descriptor[6:8]))
+			if left+width > screenWidth || top+height > screenHeight {
+				return image.Config{}, ErrGifInvalid
+			}
+			if descriptor[8]&gifColorTableFlag != 0 {
+				palette, err = readGifColorTable(br, descriptor[8])
+				if err != nil {
+					return image.Config{}, err
+				}
+			}
+			return image.Config{
+				ColorModel: palette,
+				Width:      width,
+				Height:     height,
+			}, nil
+		default:
+			// The trailer or an unknown block, before any image
+			return image.Config{}, ErrGifInvalid
+		}
+	}
+}
+
+// readGifColorTable reads the color table the flags of a screen or image descriptor announce.
+func readGifColorTable(r io.Reader, flags byte) (color.Palette, error) {
+	if flags&gifColorTableFlag == 0 {
+		return nil, nil
+	}
+	data := make([]byte, 3*(1<<(1+int(flags&0x07))))
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	palette := make(color.Palette, 0, len(data)/3)
+	for i := 0; i < len(data); i += 3 {
+		palette = append(palette, color.RGBA{R: data[i], G: data[i+1], B: data[i+2], A: 0xff})
+	}
+	return palette, nil
+}
+
+func skipGifSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		_, err = r.Discard(int(size))
+		if err != nil {
+			return err
+		}
+	}
+}
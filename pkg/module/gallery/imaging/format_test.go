@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	src.Set(1, 1, color.NRGBA{R: 255, A: 255})
+
+	var webpData bytes.Buffer
+	assert.NoError(t, Encode(&webpData, src, MimeTypeWebp))
+	config, err := DecodeConfig(bytes.NewReader(webpData.Bytes()), MimeTypeWebp)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, config.Width)
+	assert.Equal(t, 30, config.Height)
+
+	decoded, err := Decode(bytes.NewReader(webpData.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, src.Bounds(), decoded.Bounds())
+
+	// The logical screen of the gif is larger than the first frame
+	palette := color.Palette{color.Black, color.White}
+	animation := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 20, 10), palette),
+			image.NewPaletted(image.Rect(0, 0, 50, 50), palette),
+		},
+		Delay: []int{10, 10},
+		Config: image.Config{
+			ColorModel: palette,
+			Width:      50,
+			Height:     50,
+		},
+	}
+	var gifData bytes.Buffer
+	assert.NoError(t, gif.EncodeAll(&gifData, animation))
+	config, err = DecodeConfig(bytes.NewReader(gifData.Bytes()), MimeTypeGif)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, config.Width)
+	assert.Equal(t, 10, config.Height)
+
+	// Only the headers are read, a frame larger than the screen is refused
+	tests := []struct {
+		name     string
+		data     []byte
+		width    int
+		height   int
+		expected error
+	}{
+		{"huge frame", gifHeader(30000, 30000, 30000, 30000), 30000, 30000, nil},
+		{"frame outside screen", gifHeader(10, 10, 30000, 30000), 0, 0, ErrGifInvalid},
+		{"no image", []byte("GIF89a\x0a\x00\x0a\x00\x00\x00\x00\x3b"), 0, 0, ErrGifInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := DecodeConfig(bytes.NewReader(tt.data), MimeTypeGif)
+			assert.Equal(t, tt.expected, err)
+			assert.Equal(t, tt.width, config.Width)
+			assert.Equal(t, tt.height, config.Height)
+		})
+	}
+
+	_, err = DecodeConfig(bytes.NewReader(gifData.Bytes()), MimeTypePng)
+	assert.ErrorIs(t, err, ErrFormatNotSupported, "The data should match the format")
+	_, err = DecodeConfig(bytes.NewReader(gifData.Bytes()), "image/bmp")
+	assert.ErrorIs(t, err, ErrFormatNotSupported)
+}
+
+func TestRenditionFormat(t *testing.T) {
+	assert.Equal(t, MimeTypeJpeg, RenditionFormat(MimeTypeJpeg, ""))
+	assert.Equal(t, MimeTypePng, RenditionFormat(MimeTypePng, ""))
+	assert.Equal(t, MimeTypePng, RenditionFormat(MimeTypeGif, ""))
+	assert.Equal(t, MimeTypePng, RenditionFormat(MimeTypeWebp, ""))
+	assert.Equal(t, MimeTypeJpeg, RenditionFormat(MimeTypeJpeg, MimeTypeWebp), "Lossless webp is larger than a jpeg photo")
+	assert.Equal(t, MimeTypeWebp, RenditionFormat(MimeTypePng, MimeTypeWebp))
+	assert.Equal(t, MimeTypeWebp, RenditionFormat(MimeTypeGif, MimeTypeWebp))
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", MimeTypeJpeg},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00", MimeTypePng},
+		{"gif", "GIF89a\x01\x00", MimeTypeGif},
+		{"webp", "RIFF\x24\x00\x00\x00WEBPVP8L", MimeTypeWebp},
+		{"wave", "RIFF\x24\x00\x00\x00WAVEfmt ", ""},
+		{"svg", "\xef\xbb\xbf\n<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>", MimeTypeSvg},
+		{"html", "<!DOCTYPE html><html></html>", ""},
+		{"text", "hello", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DetectFormat([]byte(tt.header)))
+		})
+	}
+}
+
+// gifHeader returns a gif with the given screen and first frame size, without any pixel data.
+func gifHeader(screenWidth int, screenHeight int, width int, height int) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, uint16(screenWidth))
+	data = binary.LittleEndian.AppendUint16(data, uint16(screenHeight))
+	// No global color table, background color and aspect ratio
+	data = append(data, 0x00, 0x00, 0x00)
+	// A graphic control extension before the frame
+	data = append(data, 0x21, 0xf9, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00)
+	data = append(data, 0x2c, 0x00, 0x00, 0x00, 0x00)
+	data = binary.LittleEndian.AppendUint16(data, uint16(width))
+	data = binary.LittleEndian.AppendUint16(data, uint16(height))
+	return append(data, 0x00)
+}
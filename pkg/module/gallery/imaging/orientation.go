@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Orient returns the image turned upright according to the exif orientation.
+func Orient(src image.Image, orientation int) image.Image {
+	if orientation <= OrientationNormal || orientation > OrientationRotate270 {
+		return src
+	}
+
+	// Work on a copy with a known pixel layout, so pixels can be moved without conversion
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	source := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(source, source.Rect, src, bounds.Min, draw.Src)
+
+	dstWidth, dstHeight := width, height
+	if IsTransposed(orientation) {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			sx, sy := orientSource(orientation, x, y, width, height)
+			srcOffset := source.PixOffset(sx, sy)
+			dstOffset := dst.PixOffset(x, y)
+			copy(dst.Pix[dstOffset:dstOffset+4], source.Pix[srcOffset:srcOffset+4])
+		}
+	}
+	return dst
+}
+
+// orientSource returns the source pixel of a pixel in the upright image.
+func orientSource(orientation int, x int, y int, width int, height int) (int, int) {
+	switch orientation {
+	case OrientationFlipHorizontal:
+		return width - 1 - x, y
+	case OrientationRotate180:
+		return width - 1 - x, height - 1 - y
+	case OrientationFlipVertical:
+		return x, height - 1 - y
+	case OrientationTranspose:
+		return y, x
+	case OrientationRotate90:
+		return y, height - 1 - x
+	case OrientationTransverse:
+		return width - 1 - y, height - 1 - x
+	case OrientationRotate270:
+		return width - 1 - y, x
+	default:
+		return x, y
+	}
+}
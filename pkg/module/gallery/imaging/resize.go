@@ -0,0 +1,112 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Scale the image to fit inside the box, keeping the aspect ratio
+	FitContain string = "contain"
+	// Scale the image to fill the box, cropping the center part that doesn't fit
+	FitCover string = "cover"
+
+	DEFAULT_JPEG_QUALITY int = 85
+)
+
+var (
+	ErrFitInvalid         error = errors.New("fit invalid")
+	ErrFormatNotSupported error = errors.New("image format not supported")
+)
+
+func IsValidFit(fit string) bool {
+	return fit == FitContain || fit == FitCover
+}
+
+// Resize scales the image into the box with Catmull-Rom resampling. When width or height is zero it is
+// calculated from the aspect ratio. Images are never upscaled, a smaller image is returned as is.
+func Resize(src image.Image, width int, height int, fit string) image.Image {
+	srcRect, dstWidth, dstHeight := ResizeBounds(src.Bounds(), width, height, fit)
+	if srcRect == src.Bounds() && dstWidth == srcRect.Dx() && dstHeight == srcRect.Dy() {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, srcRect, draw.Src, nil)
+	return dst
+}
+
+// ResizeBounds returns the part of the source that is used and the size it is scaled to.
+func ResizeBounds(bounds image.Rectangle, width int, height int, fit string) (image.Rectangle, int, int) {
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if srcWidth <= 0 || srcHeight <= 0 || (width <= 0 && height <= 0) {
+		return bounds, srcWidth, srcHeight
+	}
+
+	// A single dimension keeps the aspect ratio, the fit doesn't matter then
+	if width <= 0 {
+		width = scaleDimension(srcWidth, float64(height)/float64(srcHeight))
+		fit = FitContain
+	}
+	if height <= 0 {
+		height = scaleDimension(srcHeight, float64(width)/float64(srcWidth))
+		fit = FitContain
+	}
+
+	if fit == FitCover {
+		// Crop the largest centered part of the source with the aspect ratio of the box
+		cropWidth, cropHeight := srcWidth, scaleDimension(srcWidth, float64(height)/float64(width))
+		if cropHeight > srcHeight {
+			cropWidth, cropHeight = scaleDimension(srcHeight, float64(width)/float64(height)), srcHeight
+		}
+		x := bounds.Min.X + (srcWidth-cropWidth)/2
+		y := bounds.Min.Y + (srcHeight-cropHeight)/2
+		crop := image.Rect(x, y, x+cropWidth, y+cropHeight)
+		if cropWidth <= width {
+			return crop, cropWidth, cropHeight
+		}
+		return crop, width, height
+	}
+
+	scale := math.Min(float64(width)/float64(srcWidth), float64(height)/float64(srcHeight))
+	if scale >= 1 {
+		return bounds, srcWidth, srcHeight
+	}
+	return bounds, scaleDimension(srcWidth, scale), scaleDimension(srcHeight, scale)
+}
+
+// Decode reads an image in one of the supported raster formats, for a gif the first frame.
+func Decode(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(r)
+	if err == image.ErrFormat {
+		return nil, ErrFormatNotSupported
+	}
+	return img, err
+}
+
+// Encode writes the image in the given format, only formats that can be written are supported.
+// Webp is written lossless, there is no lossy encoder without cgo.
+func Encode(w io.Writer, img image.Image, mimeType string) error {
+	switch mimeType {
+	case MimeTypeJpeg:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: DEFAULT_JPEG_QUALITY})
+	case MimeTypePng:
+		encoder := &png.Encoder{CompressionLevel: png.BestCompression}
+		return encoder.Encode(w, img)
+	case MimeTypeWebp:
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return ErrFormatNotSupported
+	}
+}
+
+func scaleDimension(value int, scale float64) int {
+	return max(1, int(math.Round(float64(value)*scale)))
+}
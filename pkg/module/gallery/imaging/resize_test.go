@@ -0,0 +1,69 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResizeBounds(t *testing.T) {
+	bounds := image.Rect(0, 0, 1200, 800)
+
+	tests := []struct {
+		name      string
+		width     int
+		height    int
+		fit       string
+		srcRect   image.Rectangle
+		dstWidth  int
+		dstHeight int
+	}{
+		{"no size", 0, 0, FitContain, bounds, 1200, 800},
+		{"width only", 300, 0, FitCover, bounds, 300, 200},
+		{"height only", 0, 400, FitContain, bounds, 600, 400},
+		{"contain landscape", 200, 200, FitContain, bounds, 200, 133},
+		{"contain no upscale", 2400, 2400, FitContain, bounds, 1200, 800},
+		{"cover square", 200, 200, FitCover, image.Rect(200, 0, 1000, 800), 200, 200},
+		{"cover portrait", 100, 200, FitCover, image.Rect(400, 0, 800, 800), 100, 200},
+		{"cover no upscale", 1000, 1000, FitCover, image.Rect(200, 0, 1000, 800), 800, 800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcRect, dstWidth, dstHeight := ResizeBounds(bounds, tt.width, tt.height, tt.fit)
+			assert.Equal(t, tt.srcRect, srcRect)
+			assert.Equal(t, tt.dstWidth, dstWidth)
+			assert.Equal(t, tt.dstHeight, dstHeight)
+		})
+	}
+}
+
+func TestResizeEncodeDecode(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	resized := Resize(src, 100, 100, FitCover)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), resized.Bounds())
+	assert.Same(t, src, Resize(src, 800, 600, FitContain), "A smaller image should not be scaled")
+
+	for _, mimeType := range []string{"image/jpeg", "image/png"} {
+		var buffer bytes.Buffer
+		err := Encode(&buffer, resized, mimeType)
+		assert.NoError(t, err)
+
+		decoded, err := Decode(&buffer)
+		assert.NoError(t, err)
+		assert.Equal(t, resized.Bounds(), decoded.Bounds())
+	}
+
+	assert.ErrorIs(t, Encode(&bytes.Buffer{}, resized, "image/tiff"), ErrFormatNotSupported)
+	_, err := Decode(bytes.NewReader([]byte("not an image")))
+	assert.ErrorIs(t, err, ErrFormatNotSupported)
+}
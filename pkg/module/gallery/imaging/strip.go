@@ -0,0 +1,249 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	jpegMarkerSoi   byte   = 0xd8
+	jpegMarkerEoi   byte   = 0xd9
+	jpegMarkerSos   byte   = 0xda
+	jpegMarkerApp0  byte   = 0xe0
+	jpegMarkerApp1  byte   = 0xe1
+	jpegMarkerApp2  byte   = 0xe2
+	jpegMarkerApp14 byte   = 0xee
+	jpegMarkerCom   byte   = 0xfe
+	pngSignature    string = "\x89PNG\r\n\x1a\n"
+)
+
+// Png chunks with text, time stamps or exif, they can hold the author, location or camera serial
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// StripMetadata copies the image without exif, xmp, comments and text metadata, which can hold the
+// location, the author or serial numbers. Only the orientation is written back, so the image is still
+// displayed upright. Color profiles are kept. Formats without metadata are copied as is.
+func StripMetadata(r io.Reader, w io.Writer, mimeType string, orientation int) error {
+	switch mimeType {
+	case MimeTypeJpeg:
+		return stripJpegMetadata(bufio.NewReader(r), w, orientation)
+	case MimeTypePng:
+		return stripPngMetadata(r, w, orientation)
+	case MimeTypeWebp:
+		return stripWebpMetadata(r, w, orientation)
+	default:
+		_, err := io.Copy(w, r)
+		return err
+	}
+}
+
+func stripJpegMetadata(r *bufio.Reader, w io.Writer, orientation int) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, jpegMarkerSoi} {
+		return ErrFormatNotSupported
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	exifWritten := orientation <= OrientationNormal
+	for {
+		marker, data, err := readJpegSegment(r)
+		if err != nil {
+			return err
+		}
+
+		// The exif segment follows the jfif segment, which has to be the first
+		if !exifWritten && marker != jpegMarkerApp0 {
+			payload := append([]byte(exifHeader), encodeExif(orientation)...)
+			if err := writeJpegSegment(w, jpegMarkerApp1, payload); err != nil {
+				return err
+			}
+			exifWritten = true
+		}
+
+		switch {
+		case marker == jpegMarkerSos || marker == jpegMarkerEoi:
+			// The compressed image data follows, it's copied as is
+			if err := writeJpegSegment(w, marker, data); err != nil {
+				return err
+			}
+			_, err = io.Copy(w, r)
+			return err
+		case marker == jpegMarkerApp0 || marker == jpegMarkerApp2 || marker == jpegMarkerApp14:
+			// Jfif, icc color profile and adobe color transform are needed to display the image
+		case marker >= jpegMarkerApp0 && marker <= 0xef, marker == jpegMarkerCom:
+			continue
+		}
+		if err := writeJpegSegment(w, marker, data); err != nil {
+			return err
+		}
+	}
+}
+
+// readJpegSegment reads the next marker and its data, markers without a length return no data.
+func readJpegSegment(r *bufio.Reader) (byte, []byte, error) {
+	prefix, err := r.ReadByte()
+	if err != nil || prefix != 0xff {
+		return 0, nil, ErrFormatNotSupported
+	}
+	marker := byte(0xff)
+	// Markers can be preceded by fill bytes
+	for marker == 0xff {
+		marker, err = r.ReadByte()
+		if err != nil {
+			return 0, nil, ErrFormatNotSupported
+		}
+	}
+	if marker == jpegMarkerEoi || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+		return marker, nil, nil
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return 0, nil, ErrFormatNotSupported
+	}
+	size := int(binary.BigEndian.Uint16(length[:]))
+	if size < 2 {
+		return 0, nil, ErrFormatNotSupported
+	}
+	data := make([]byte, size-2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, ErrFormatNotSupported
+	}
+	return marker, data, nil
+}
+
+func writeJpegSegment(w io.Writer, marker byte, data []byte) error {
+	if data == nil {
+		_, err := w.Write([]byte{0xff, marker})
+		return err
+	}
+	header := []byte{0xff, marker}
+	header = binary.BigEndian.AppendUint16(header, uint16(len(data)+2))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func stripPngMetadata(r io.Reader, w io.Writer, orientation int) error {
+	var signature [8]byte
+	if _, err := io.ReadFull(r, signature[:]); err != nil || string(signature[:]) != pngSignature {
+		return ErrFormatNotSupported
+	}
+	if _, err := w.Write(signature[:]); err != nil {
+		return err
+	}
+
+	for {
+		var header [8]byte
+		_, err := io.ReadFull(r, header[:])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ErrFormatNotSupported
+		}
+		chunkType := string(header[4:])
+		// Data and crc
+		length := int64(binary.BigEndian.Uint32(header[:4])) + 4
+
+		if pngMetadataChunks[chunkType] {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return ErrFormatNotSupported
+			}
+			continue
+		}
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, length); err != nil {
+			return err
+		}
+
+		// The exif chunk follows the header chunk, before the image data
+		if chunkType == "IHDR" && orientation > OrientationNormal {
+			if err := writePngChunk(w, "eXIf", encodeExif(orientation)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writePngChunk(w io.Writer, chunkType string, data []byte) error {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
+
+// stripWebpMetadata rewrites the riff container in memory, as the size in the header changes.
+func stripWebpMetadata(r io.Reader, w io.Writer, orientation int) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return ErrFormatNotSupported
+	}
+
+	output := bytes.NewBuffer(make([]byte, 0, len(data)))
+	output.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	hasExif := false
+	for offset := 12; offset+8 <= len(data); {
+		chunkType := string(data[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		end := offset + 8 + length + length%2
+		if length < 0 || end > len(data) {
+			return ErrFormatNotSupported
+		}
+		chunk := data[offset:end]
+		offset = end
+
+		switch chunkType {
+		case "EXIF":
+			hasExif = true
+			if orientation > OrientationNormal {
+				writeWebpChunk(output, "EXIF", encodeExif(orientation))
+			}
+			continue
+		case "XMP ":
+			continue
+		}
+		output.Write(chunk)
+	}
+
+	result := output.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	// Clear the exif and xmp flags of the extended header, the exif flag is kept when written back
+	if len(result) >= 21 && string(result[12:16]) == "VP8X" {
+		result[20] &^= 0x04
+		if !hasExif || orientation <= OrientationNormal {
+			result[20] &^= 0x08
+		}
+	}
+	_, err = w.Write(result)
+	return err
+}
+
+func writeWebpChunk(w *bytes.Buffer, chunkType string, data []byte) {
+	w.WriteString(chunkType)
+	w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
@@ -0,0 +1,251 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSvgInvalid error = errors.New("svg invalid")
+)
+
+// Elements that run scripts, embed other documents or play media are removed with their content
+var svgBlockedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"audio":         true,
+	"video":         true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// Animation elements can change an href into a script url
+var svgAnimationElements = map[string]bool{
+	"animate":          true,
+	"set":              true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+// SanitizeSvg copies the svg document and strips scripts, event handlers and references to anything
+// outside the document. Comments, doctypes and processing instructions are dropped as well, so
+// entities and external stylesheets can't be declared.
+func SanitizeSvg(r io.Reader, w io.Writer) error {
+	decoder := xml.NewDecoder(r)
+	output := bufio.NewWriter(w)
+
+	// Names of the open elements, RawToken doesn't verify the end elements match
+	stack := make([]xml.Name, 0)
+	// Depth of the stack at which the removed element started, zero when nothing is removed
+	skip := 0
+	var style *strings.Builder
+	root := false
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSvgInvalid, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+			if skip > 0 {
+				continue
+			}
+			name := strings.ToLower(t.Name.Local)
+			if !root {
+				if name != "svg" || len(stack) != 1 {
+					return fmt.Errorf("%w: root element is not svg", ErrSvgInvalid)
+				}
+				root = true
+			}
+			if svgBlockedElements[name] || (svgAnimationElements[name] && animatesReference(t.Attr)) {
+				skip = len(stack)
+				continue
+			}
+			writeSvgStartElement(output, t.Name, sanitizeSvgAttributes(t.Attr))
+			if name == "style" {
+				style = &strings.Builder{}
+			}
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name {
+				return fmt.Errorf("%w: unexpected end element %s", ErrSvgInvalid, t.Name.Local)
+			}
+			stack = stack[:len(stack)-1]
+			if skip > 0 {
+				if len(stack) < skip {
+					skip = 0
+				}
+				continue
+			}
+			if style != nil {
+				if !hasExternalReference(style.String()) {
+					xml.EscapeText(output, []byte(style.String()))
+				}
+				style = nil
+			}
+			output.WriteString("</" + svgQualifiedName(t.Name) + ">")
+		case xml.CharData:
+			if skip > 0 || len(stack) == 0 {
+				continue
+			}
+			if style != nil {
+				style.Write(t)
+				continue
+			}
+			xml.EscapeText(output, t)
+		case xml.ProcInst:
+			if t.Target == "xml" && !root {
+				output.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+			}
+		}
+	}
+	if !root || len(stack) != 0 {
+		return fmt.Errorf("%w: incomplete document", ErrSvgInvalid)
+	}
+	return output.Flush()
+}
+
+// DecodeSvgConfig reads the dimensions from the width and height of the root element, falling back to
+// the view box when they are missing or not in pixels. The dimensions are zero when neither is set.
+func DecodeSvgConfig(r io.Reader) (image.Config, error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return image.Config{}, fmt.Errorf("%w: %v", ErrSvgInvalid, err)
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if strings.ToLower(element.Name.Local) != "svg" {
+			return image.Config{}, fmt.Errorf("%w: root element is not svg", ErrSvgInvalid)
+		}
+
+		var width, height, viewBox string
+		for _, attr := range element.Attr {
+			if attr.Name.Space != "" {
+				continue
+			}
+			switch attr.Name.Local {
+			case "width":
+				width = attr.Value
+			case "height":
+				height = attr.Value
+			case "viewBox":
+				viewBox = attr.Value
+			}
+		}
+
+		w, wOk := parseSvgLength(width)
+		h, hOk := parseSvgLength(height)
+		if wOk && hOk {
+			return image.Config{Width: w, Height: h}, nil
+		}
+		fields := strings.FieldsFunc(viewBox, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r'
+		})
+		if len(fields) == 4 {
+			vw, errW := strconv.ParseFloat(fields[2], 64)
+			vh, errH := strconv.ParseFloat(fields[3], 64)
+			if errW == nil && errH == nil && vw > 0 && vh > 0 {
+				return image.Config{Width: int(vw + 0.5), Height: int(vh + 0.5)}, nil
+			}
+		}
+		return image.Config{}, nil
+	}
+}
+
+func parseSvgLength(value string) (int, bool) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	length, err := strconv.ParseFloat(value, 64)
+	if err != nil || length <= 0 {
+		return 0, false
+	}
+	return int(length + 0.5), true
+}
+
+func sanitizeSvgAttributes(attrs []xml.Attr) []xml.Attr {
+	result := make([]xml.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		value := strings.TrimSpace(attr.Value)
+		if strings.HasPrefix(name, "on") {
+			continue
+		}
+		if (name == "href" || name == "src") && !strings.HasPrefix(value, "#") {
+			continue
+		}
+		if name == "style" && strings.Contains(value, "\\") {
+			// Css escapes could hide a url
+			continue
+		}
+		if hasExternalReference(value) {
+			continue
+		}
+		result = append(result, attr)
+	}
+	return result
+}
+
+// animatesReference returns true when the animation targets an href or sets a script url.
+func animatesReference(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		if name == "attributename" && strings.HasSuffix(strings.ToLower(attr.Value), "href") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExternalReference returns true when the value contains a script url, an import or a url()
+// pointing outside the document.
+func hasExternalReference(value string) bool {
+	compact := strings.ToLower(strings.Join(strings.Fields(value), ""))
+	if strings.Contains(compact, "javascript:") || strings.Contains(compact, "@import") || strings.Contains(compact, "expression(") {
+		return true
+	}
+	for {
+		index := strings.Index(compact, "url(")
+		if index < 0 {
+			return false
+		}
+		compact = compact[index+len("url("):]
+		target := strings.TrimLeft(compact, `"'`)
+		if !strings.HasPrefix(target, "#") {
+			return true
+		}
+	}
+}
+
+func writeSvgStartElement(w *bufio.Writer, name xml.Name, attrs []xml.Attr) {
+	w.WriteString("<" + svgQualifiedName(name))
+	for _, attr := range attrs {
+		w.WriteString(" " + svgQualifiedName(attr.Name) + `="`)
+		xml.EscapeText(w, []byte(attr.Value))
+		w.WriteString(`"`)
+	}
+	w.WriteString(">")
+}
+
+func svgQualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
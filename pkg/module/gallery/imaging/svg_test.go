@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeSvg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"keeps drawing",
+			`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="10"><rect x="1" fill="url(#g)"/></svg>`,
+			`<?xml version="1.0" encoding="UTF-8"?><svg xmlns="http://www.w3.org/2000/svg" width="10"><rect x="1" fill="url(#g)"></rect></svg>`,
+		},
+		{
+			"removes scripts",
+			`<svg><script>alert(1)</script><g><script><![CDATA[alert(2)]]></script></g></svg>`,
+			`<svg><g></g></svg>`,
+		},
+		{
+			"removes foreign objects",
+			`<svg><foreignObject><iframe src="https://example.com"/></foreignObject></svg>`,
+			`<svg></svg>`,
+		},
+		{
+			"removes event handlers",
+			`<svg onload="alert(1)"><rect onClick="alert(2)" width="1"/></svg>`,
+			`<svg><rect width="1"></rect></svg>`,
+		},
+		{
+			"removes external references",
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="https://example.com/a.svg#x"/><use href="#local"/><image href="data:image/png;base64,AA"/></svg>`,
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use></use><use href="#local"></use><image></image></svg>`,
+		},
+		{
+			"removes script urls",
+			`<svg><a href="javascript:alert(1)"><rect style="fill: URL( 'https://example.com/x' )" width="1"/></a></svg>`,
+			`<svg><a><rect width="1"></rect></a></svg>`,
+		},
+		{
+			"removes href animations",
+			`<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="opacity"/></a></svg>`,
+			`<svg><a><animate attributeName="opacity"></animate></a></svg>`,
+		},
+		{
+			"removes external styles",
+			`<svg><style>@import url(https://example.com/a.css);</style><style>rect { fill: red; }</style></svg>`,
+			`<svg><style></style><style>rect { fill: red; }</style></svg>`,
+		},
+		{
+			"removes comments and doctypes",
+			`<!DOCTYPE svg [<!ENTITY x "y">]><svg><!-- comment --><?xml-stylesheet href="a.css"?></svg>`,
+			`<svg></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := SanitizeSvg(strings.NewReader(tt.input), &output)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, output.String())
+		})
+	}
+}
+
+func TestSanitizeSvgInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not svg", `<html><body/></html>`},
+		{"mismatched end", `<svg><script></g>alert(1)</script></svg>`},
+		{"incomplete", `<svg><g>`},
+		{"unknown entity", `<svg>&x;</svg>`},
+		{"empty", ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SanitizeSvg(strings.NewReader(tt.input), &bytes.Buffer{})
+			assert.ErrorIs(t, err, ErrSvgInvalid)
+		})
+	}
+}
+
+func TestDecodeSvgConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		width  int
+		height int
+	}{
+		{"width and height", `<svg width="120" height="80px"/>`, 120, 80},
+		{"view box", `<svg viewBox="0 0 64.4,32"/>`, 64, 32},
+		{"relative size", `<svg width="100%" height="2em" viewBox="0 0 16 9"/>`, 16, 9},
+		{"no size", `<?xml version="1.0"?><svg/>`, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := DecodeSvgConfig(strings.NewReader(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.width, config.Width)
+			assert.Equal(t, tt.height, config.Height)
+		})
+	}
+
+	_, err := DecodeSvgConfig(strings.NewReader(`<html/>`))
+	assert.ErrorIs(t, err, ErrSvgInvalid)
+}
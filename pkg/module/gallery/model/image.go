@@ -1,21 +1,42 @@
 package model
 
 import (
+	"time"
+
 	"github.com/deb-ict/cloudbm-community/pkg/core"
 	"github.com/deb-ict/cloudbm-community/pkg/localization"
 	"github.com/gosimple/slug"
 )
 
+type ImageVisibility string
+
+const (
+	// Public images are served to anyone
+	ImageVisibilityPublic ImageVisibility = "public"
+	// Private images are only served with a signed url, which expires
+	ImageVisibilityPrivate ImageVisibility = "private"
+)
+
 type Image struct {
 	Id               string
 	Translations     []*ImageTranslation
+	Visibility       ImageVisibility
 	StorageFolder    string
 	FileName         string
 	OriginalFileName string
 	FileSize         int64
 	MimeType         string
-	Width            int32
-	Height           int32
+	// Hex encoded sha256 of the stored file, used as entity tag when the image is served
+	ContentHash string
+	UploadedAt  time.Time
+	// Dimensions of the upright image, after the exif orientation is applied
+	Width  int32
+	Height int32
+	// Exif metadata, empty when the image has none
+	CapturedAt  time.Time
+	CameraMake  string
+	CameraModel string
+	Orientation int32
 }
 
 type ImageTranslation struct {
@@ -44,9 +65,20 @@ func (m *Image) Normalize(normalizer core.StringNormalizer) {
 	}
 }
 
+// EnsureDefaults sets the defaults of a new image, images are public unless requested otherwise.
+func (m *Image) EnsureDefaults() {
+	if m.Visibility == "" {
+		m.Visibility = ImageVisibilityPublic
+	}
+}
+
 func (m *Image) UpdateModel(other *Image) {
 	m.Translations = make([]*ImageTranslation, 0)
 	m.OriginalFileName = other.OriginalFileName
+	// Clients which don't know the visibility leave it out, which must not make a private image public
+	if other.Visibility != "" {
+		m.Visibility = other.Visibility
+	}
 	for _, translation := range other.Translations {
 		m.Translations = append(m.Translations, translation.Clone())
 	}
@@ -82,6 +114,16 @@ func (m *Image) IsTransient() bool {
 	return m.Id == ""
 }
 
+// IsPrivate returns true when the image is only served with a signed url, images stored before the
+// visibility was introduced are public.
+func (m *Image) IsPrivate() bool {
+	return m.Visibility == ImageVisibilityPrivate
+}
+
+func (v ImageVisibility) IsValid() bool {
+	return v == ImageVisibilityPublic || v == ImageVisibilityPrivate
+}
+
 func (m *Image) Clone() *Image {
 	if m == nil {
 		return nil
@@ -89,13 +131,20 @@ func (m *Image) Clone() *Image {
 	model := &Image{
 		Id:               m.Id,
 		Translations:     make([]*ImageTranslation, 0),
+		Visibility:       m.Visibility,
 		StorageFolder:    m.StorageFolder,
 		FileName:         m.FileName,
 		OriginalFileName: m.OriginalFileName,
 		FileSize:         m.FileSize,
 		MimeType:         m.MimeType,
+		ContentHash:      m.ContentHash,
+		UploadedAt:       m.UploadedAt,
 		Width:            m.Width,
 		Height:           m.Height,
+		CapturedAt:       m.CapturedAt,
+		CameraMake:       m.CameraMake,
+		CameraModel:      m.CameraModel,
+		Orientation:      m.Orientation,
 	}
 	for _, translation := range m.Translations {
 		model.Translations = append(model.Translations, translation.Clone())
@@ -0,0 +1,12 @@
+package model
+
+// ImageRendition selects a resized version of an image, either a configured size by name or a custom size.
+type ImageRendition struct {
+	Name   string
+	Width  int
+	Height int
+	Fit    string
+	// Requested output format, only image/webp is supported and a jpeg image stays jpeg. The format of
+	// the image is kept when empty.
+	Format string
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// ImageUrl is the url an image is served on, the expiration is empty for public images.
+type ImageUrl struct {
+	Url       string
+	ExpiresAt time.Time
+}
@@ -14,6 +14,8 @@ type Service interface {
 	FeatureProvider() core.FeatureProvider
 	StorageProvider() core.StorageProvider
 	LanguageProvider() localization.LanguageProvider
+	ImageBaseUri() string
+	MaxFileSize() int64
 
 	GetImages(ctx context.Context, offset int64, limit int64, filter *model.ImageFilter, sort *core.Sort) ([]*model.Image, int64, error)
 	GetImageById(ctx context.Context, id string) (*model.Image, error)
@@ -24,4 +26,8 @@ type Service interface {
 	DeleteImage(ctx context.Context, id string) error
 	GetImageData(ctx context.Context, id string) (io.ReadCloser, string, string, error)
 	SetImageData(ctx context.Context, id string, file io.Reader, mimeType string, originalFileName string) (*model.Image, error)
+	GetPublicImageData(ctx context.Context, id string) (io.ReadCloser, string, string, error)
+	GetImageRendition(ctx context.Context, id string, rendition *model.ImageRendition) (io.ReadCloser, string, string, error)
+	GetImageUrl(ctx context.Context, id string) (*model.ImageUrl, error)
+	VerifyImageUrl(ctx context.Context, id string, expires string, signature string) error
 }
@@ -1,9 +1,31 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/deb-ict/cloudbm-community/pkg/core"
 	"github.com/deb-ict/cloudbm-community/pkg/localization"
 	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/imaging"
+	"github.com/deb-ict/cloudbm-community/pkg/storage"
+)
+
+const (
+	DEFAULT_MAX_RENDITION_WIDTH  int   = 2400
+	DEFAULT_MAX_RENDITION_HEIGHT int   = 2400
+	DEFAULT_MAX_FILE_SIZE        int64 = 20 << 20
+	DEFAULT_MAX_IMAGE_WIDTH      int   = 8192
+	DEFAULT_MAX_IMAGE_HEIGHT     int   = 8192
+
+	DEFAULT_IMAGE_BASE_URI                string = "/assets/gallery/"
+	DEFAULT_SIGNED_URL_EXPIRATION_MINUTES int    = 15
 )
 
 type ServiceOptions struct {
@@ -11,14 +33,57 @@ type ServiceOptions struct {
 	FeatureProvider  core.FeatureProvider
 	StorageProvider  core.StorageProvider
 	LanguageProvider localization.LanguageProvider
+	// Store for the image files and renditions, a filesystem store under the storage root when nil
+	BlobStore storage.BlobStore `yaml:"-"`
+	// Images are stored in an s3 compatible bucket when a bucket is configured
+	S3 storage.S3Options `yaml:"s3"`
+	// Named sizes, requested by name instead of a custom width and height
+	Renditions []RenditionOptions `yaml:"renditions"`
+	// Limits custom sizes, so the rendition cache can't be filled with huge images
+	MaxRenditionWidth  int `yaml:"max_rendition_width"`
+	MaxRenditionHeight int `yaml:"max_rendition_height"`
+	// Custom sizes are rounded up to the next step, so only a few sizes of an image are rendered and
+	// cached, however many sizes are requested
+	RenditionSteps []int `yaml:"rendition_steps"`
+	// Maximum size of an uploaded file in bytes
+	MaxFileSize int64 `yaml:"max_file_size"`
+	// Limits the dimensions of uploaded images, as they are decoded in memory to render renditions
+	MaxImageWidth  int `yaml:"max_image_width"`
+	MaxImageHeight int `yaml:"max_image_height"`
+	// Uri the static file middleware serves the images on, image urls are built from it. Read from GALLERY_URI.
+	ImageBaseUri string `yaml:"image_base_uri"`
+	// Secret to sign the urls of private images, read from GALLERY_URL_SIGNING_SECRET. Private images
+	// can't be served without a secret, see EnsureUrlSigningSecret
+	UrlSigningSecret           string `yaml:"url_signing_secret"`
+	SignedUrlExpirationMinutes int    `yaml:"signed_url_expiration_minutes"`
+}
+
+type RenditionOptions struct {
+	Name   string `yaml:"name"`
+	Width  int    `yaml:"width"`
+	Height int    `yaml:"height"`
+	// contain or cover
+	Fit string `yaml:"fit"`
 }
 
 type service struct {
-	stringNormalizer core.StringNormalizer
-	featureProvider  core.FeatureProvider
-	storageProvider  core.StorageProvider
-	languageProvider localization.LanguageProvider
-	database         gallery.Database
+	stringNormalizer   core.StringNormalizer
+	featureProvider    core.FeatureProvider
+	storageProvider    core.StorageProvider
+	languageProvider   localization.LanguageProvider
+	blobStore          storage.BlobStore
+	renditions         map[string]RenditionOptions
+	maxRenditionWidth  int
+	maxRenditionHeight int
+	renditionSteps     []int
+	maxFileSize        int64
+	maxImageWidth      int
+	maxImageHeight     int
+	imageBaseUri       string
+	urlSigningKey      []byte
+	signedUrlLifetime  time.Duration
+	now                func() time.Time
+	database           gallery.Database
 }
 
 func NewService(database gallery.Database, opts *ServiceOptions) gallery.Service {
@@ -28,11 +93,29 @@ func NewService(database gallery.Database, opts *ServiceOptions) gallery.Service
 	opts.EnsureDefaults()
 
 	svc := &service{
-		stringNormalizer: opts.StringNormalizer,
-		featureProvider:  opts.FeatureProvider,
-		storageProvider:  opts.StorageProvider,
-		languageProvider: opts.LanguageProvider,
-		database:         database,
+		stringNormalizer:   opts.StringNormalizer,
+		featureProvider:    opts.FeatureProvider,
+		storageProvider:    opts.StorageProvider,
+		languageProvider:   opts.LanguageProvider,
+		blobStore:          opts.BlobStore,
+		renditions:         make(map[string]RenditionOptions),
+		maxRenditionWidth:  opts.MaxRenditionWidth,
+		maxRenditionHeight: opts.MaxRenditionHeight,
+		renditionSteps:     slices.Sorted(slices.Values(opts.RenditionSteps)),
+		maxFileSize:        opts.MaxFileSize,
+		maxImageWidth:      opts.MaxImageWidth,
+		maxImageHeight:     opts.MaxImageHeight,
+		imageBaseUri:       opts.ImageBaseUri,
+		urlSigningKey:      []byte(opts.UrlSigningSecret),
+		signedUrlLifetime:  time.Duration(opts.SignedUrlExpirationMinutes) * time.Minute,
+		now:                time.Now,
+		database:           database,
+	}
+	for _, rendition := range opts.Renditions {
+		svc.renditions[rendition.Name] = rendition
+	}
+	if len(svc.urlSigningKey) == 0 {
+		slog.WarnContext(context.Background(), "No url signing secret configured, private images can't be served")
 	}
 
 	return svc
@@ -54,6 +137,28 @@ func (svc *service) LanguageProvider() localization.LanguageProvider {
 	return svc.languageProvider
 }
 
+func (svc *service) ImageBaseUri() string {
+	return svc.imageBaseUri
+}
+
+func (svc *service) MaxFileSize() int64 {
+	return svc.maxFileSize
+}
+
+func (opts *ServiceOptions) LoadEnvironment() {
+	uri, ok := os.LookupEnv("GALLERY_URI")
+	if ok {
+		slog.InfoContext(context.Background(), "Override gallery uri from environment")
+		opts.ImageBaseUri = uri
+	}
+	secret, ok := os.LookupEnv("GALLERY_URL_SIGNING_SECRET")
+	if ok {
+		slog.InfoContext(context.Background(), "Override gallery url signing secret from environment")
+		opts.UrlSigningSecret = secret
+	}
+	opts.S3.LoadEnvironment()
+}
+
 func (opts *ServiceOptions) EnsureDefaults() {
 	if opts.StringNormalizer == nil {
 		opts.StringNormalizer = core.DefaultStringNormalizer()
@@ -67,4 +172,68 @@ func (opts *ServiceOptions) EnsureDefaults() {
 	if opts.LanguageProvider == nil {
 		opts.LanguageProvider = localization.NewDefaultLanguageProvider()
 	}
+	if opts.BlobStore == nil {
+		opts.BlobStore = storage.NewFilesystemBlobStore(opts.StorageProvider.GetRootFolder(context.Background()))
+	}
+	if opts.Renditions == nil {
+		opts.Renditions = []RenditionOptions{
+			{Name: "thumbnail", Width: 200, Height: 200, Fit: imaging.FitCover},
+			{Name: "medium", Width: 800, Height: 800, Fit: imaging.FitContain},
+			{Name: "large", Width: 1600, Height: 1600, Fit: imaging.FitContain},
+		}
+	}
+	for i := range opts.Renditions {
+		if !imaging.IsValidFit(opts.Renditions[i].Fit) {
+			opts.Renditions[i].Fit = imaging.FitContain
+		}
+	}
+	if opts.MaxRenditionWidth <= 0 {
+		opts.MaxRenditionWidth = DEFAULT_MAX_RENDITION_WIDTH
+	}
+	if opts.MaxRenditionHeight <= 0 {
+		opts.MaxRenditionHeight = DEFAULT_MAX_RENDITION_HEIGHT
+	}
+	opts.RenditionSteps = slices.DeleteFunc(opts.RenditionSteps, func(step int) bool {
+		return step <= 0
+	})
+	if len(opts.RenditionSteps) == 0 {
+		opts.RenditionSteps = []int{100, 200, 400, 800, 1200, 1600, 2400}
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DEFAULT_MAX_FILE_SIZE
+	}
+	if opts.MaxImageWidth <= 0 {
+		opts.MaxImageWidth = DEFAULT_MAX_IMAGE_WIDTH
+	}
+	if opts.MaxImageHeight <= 0 {
+		opts.MaxImageHeight = DEFAULT_MAX_IMAGE_HEIGHT
+	}
+	if opts.ImageBaseUri == "" {
+		opts.ImageBaseUri = DEFAULT_IMAGE_BASE_URI
+	}
+	if !strings.HasPrefix(opts.ImageBaseUri, "/") {
+		opts.ImageBaseUri = "/" + opts.ImageBaseUri
+	}
+	if !strings.HasSuffix(opts.ImageBaseUri, "/") {
+		opts.ImageBaseUri = opts.ImageBaseUri + "/"
+	}
+	if opts.SignedUrlExpirationMinutes <= 0 {
+		opts.SignedUrlExpirationMinutes = DEFAULT_SIGNED_URL_EXPIRATION_MINUTES
+	}
+}
+
+// EnsureUrlSigningSecret generates a random secret when none is configured. Signed urls are then only
+// valid on this instance until it restarts.
+func (opts *ServiceOptions) EnsureUrlSigningSecret() error {
+	if opts.UrlSigningSecret != "" {
+		return nil
+	}
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	slog.WarnContext(context.Background(), "No url signing secret configured, using a random secret. Signed image urls are rejected after a restart and by other instances")
+	opts.UrlSigningSecret = base64.RawStdEncoding.EncodeToString(secret)
+	return nil
 }
@@ -0,0 +1,537 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/imaging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
+	"github.com/deb-ict/cloudbm-community/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryGalleryDatabase struct {
+	mutex  sync.Mutex
+	nextId int
+	images map[string]*model.Image
+}
+
+func newMemoryGalleryDatabase() *memoryGalleryDatabase {
+	return &memoryGalleryDatabase{
+		images: make(map[string]*model.Image),
+	}
+}
+
+func (db *memoryGalleryDatabase) Images() gallery.ImageRepository {
+	return db
+}
+
+func (db *memoryGalleryDatabase) GetImages(ctx context.Context, offset int64, limit int64, filter *model.ImageFilter, sort *core.Sort) ([]*model.Image, int64, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	result := make([]*model.Image, 0)
+	for _, data := range db.images {
+		result = append(result, data.Clone())
+	}
+	return result, int64(len(result)), nil
+}
+
+func (db *memoryGalleryDatabase) GetImageById(ctx context.Context, id string) (*model.Image, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.images[id].Clone(), nil
+}
+
+func (db *memoryGalleryDatabase) GetImageByName(ctx context.Context, language string, name string) (*model.Image, error) {
+	return nil, nil
+}
+
+func (db *memoryGalleryDatabase) GetImageBySlug(ctx context.Context, language string, slug string) (*model.Image, error) {
+	return nil, nil
+}
+
+func (db *memoryGalleryDatabase) CreateImage(ctx context.Context, data *model.Image) (string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.nextId++
+	data = data.Clone()
+	data.Id = "image-" + strconv.Itoa(db.nextId)
+	db.images[data.Id] = data
+	return data.Id, nil
+}
+
+func (db *memoryGalleryDatabase) UpdateImage(ctx context.Context, data *model.Image) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.images[data.Id] = data.Clone()
+	return nil
+}
+
+func (db *memoryGalleryDatabase) DeleteImage(ctx context.Context, data *model.Image) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	delete(db.images, data.Id)
+	return nil
+}
+
+func newTestService(t *testing.T) gallery.Service {
+	svc, _ := newTestServiceWithStore(t)
+	return svc
+}
+
+func newTestServiceWithStore(t *testing.T) (gallery.Service, storage.BlobStore) {
+	store := storage.NewFilesystemBlobStore(t.TempDir())
+	return NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore: store,
+	}), store
+}
+
+func encodeTestImage(t *testing.T, width int, height int) *bytes.Buffer {
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buffer bytes.Buffer
+	assert.NoError(t, png.Encode(&buffer, src))
+	return &buffer
+}
+
+func newTestImage(t *testing.T, svc gallery.Service, width int, height int) *model.Image {
+	data, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+	data, err = svc.SetImageData(context.Background(), data.Id, encodeTestImage(t, width, height), "image/png", "test.png")
+	assert.NoError(t, err)
+	return data
+}
+
+func decodeTestImage(t *testing.T, svc gallery.Service, id string, rendition *model.ImageRendition) image.Config {
+	file, mimeType, _, err := svc.GetImageRendition(context.Background(), id, rendition)
+	if !assert.NoError(t, err) {
+		return image.Config{}
+	}
+	defer file.Close()
+	assert.Equal(t, "image/png", mimeType)
+
+	config, err := png.DecodeConfig(file)
+	assert.NoError(t, err)
+	return config
+}
+
+func TestGetImageRendition(t *testing.T) {
+	svc := newTestService(t)
+	data := newTestImage(t, svc, 600, 400)
+
+	thumbnail := decodeTestImage(t, svc, data.Id, &model.ImageRendition{Name: "thumbnail"})
+	assert.Equal(t, 200, thumbnail.Width)
+	assert.Equal(t, 200, thumbnail.Height)
+
+	// Custom sizes are rounded up to the next step
+	custom := decodeTestImage(t, svc, data.Id, &model.ImageRendition{Width: 300})
+	assert.Equal(t, 400, custom.Width)
+	assert.Equal(t, 267, custom.Height)
+
+	// The cached rendition is served the second time
+	cached := decodeTestImage(t, svc, data.Id, &model.ImageRendition{Width: 300})
+	assert.Equal(t, custom, cached)
+}
+
+func TestGetImageRendition_Steps(t *testing.T) {
+	svc, store := newTestServiceWithStore(t)
+	data := newTestImage(t, svc, 600, 400)
+
+	// Every size between two steps shares the same cached file
+	for width := 201; width <= 400; width += 7 {
+		file, _, _, err := svc.GetImageRendition(context.Background(), data.Id, &model.ImageRendition{Width: width})
+		if assert.NoError(t, err) {
+			file.Close()
+		}
+	}
+	blobs, err := store.List(context.Background(), "gallery/renditions/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	tests := []struct {
+		value    int
+		expected int
+	}{
+		{0, 0},
+		{1, 100},
+		{100, 100},
+		{101, 200},
+		{2400, 2400},
+		{3000, 2400},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			assert.Equal(t, tt.expected, svc.(*service).snapRenditionSize(tt.value))
+		})
+	}
+}
+
+func TestGetImageRendition_Invalid(t *testing.T) {
+	svc := newTestService(t)
+	data := newTestImage(t, svc, 60, 40)
+
+	tests := []struct {
+		name      string
+		rendition *model.ImageRendition
+		err       error
+	}{
+		{"unknown name", &model.ImageRendition{Name: "huge"}, gallery.ErrRenditionNotFound},
+		{"no size", &model.ImageRendition{}, gallery.ErrRenditionInvalid},
+		{"negative", &model.ImageRendition{Width: -1, Height: 10}, gallery.ErrRenditionInvalid},
+		{"too large", &model.ImageRendition{Width: 10000}, gallery.ErrRenditionInvalid},
+		{"unknown fit", &model.ImageRendition{Width: 10, Height: 10, Fit: "stretch"}, gallery.ErrRenditionInvalid},
+		{"unknown format", &model.ImageRendition{Width: 10, Format: "image/avif"}, gallery.ErrRenditionInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := svc.GetImageRendition(context.Background(), data.Id, tt.rendition)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestSetImageData_Formats(t *testing.T) {
+	src := image.NewPaletted(image.Rect(0, 0, 64, 48), color.Palette{color.Black, color.White})
+	var gifData, webpData bytes.Buffer
+	assert.NoError(t, gif.Encode(&gifData, src, nil))
+	assert.NoError(t, imaging.Encode(&webpData, src, imaging.MimeTypeWebp))
+
+	tests := []struct {
+		name     string
+		data     io.Reader
+		mimeType string
+		width    int32
+		height   int32
+	}{
+		{"gif", &gifData, imaging.MimeTypeGif, 64, 48},
+		{"webp", &webpData, imaging.MimeTypeWebp, 64, 48},
+		{"svg", strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 30 20"><rect width="30" height="20"/></svg>`), imaging.MimeTypeSvg, 30, 20},
+	}
+
+	svc := newTestService(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := svc.CreateImage(context.Background(), &model.Image{})
+			assert.NoError(t, err)
+			data, err = svc.SetImageData(context.Background(), data.Id, tt.data, tt.mimeType, "test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.mimeType, data.MimeType)
+			assert.Equal(t, tt.width, data.Width)
+			assert.Equal(t, tt.height, data.Height)
+		})
+	}
+}
+
+func TestSetImageData_SanitizeSvg(t *testing.T) {
+	svc := newTestService(t)
+	data, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+
+	_, err = svc.SetImageData(context.Background(), data.Id, strings.NewReader(`<svg><g></svg>`), imaging.MimeTypeSvg, "test.svg")
+	assert.ErrorIs(t, err, gallery.ErrImageDataInvalid)
+
+	input := `<svg width="10" height="10" onload="alert(1)"><script>alert(2)</script><rect width="10"/></svg>`
+	_, err = svc.SetImageData(context.Background(), data.Id, strings.NewReader(input), imaging.MimeTypeSvg, "test.svg")
+	assert.NoError(t, err)
+
+	// Svg images have no renditions, the sanitized original is returned
+	file, mimeType, _, err := svc.GetImageRendition(context.Background(), data.Id, &model.ImageRendition{Name: "thumbnail"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+	output, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, imaging.MimeTypeSvg, mimeType)
+	assert.Equal(t, `<svg width="10" height="10"><rect width="10"></rect></svg>`, string(output))
+}
+
+func TestGetImageRendition_Webp(t *testing.T) {
+	svc := newTestService(t)
+	data := newTestImage(t, svc, 600, 400)
+
+	file, mimeType, _, err := svc.GetImageRendition(context.Background(), data.Id, &model.ImageRendition{Name: "thumbnail", Format: imaging.MimeTypeWebp})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+	assert.Equal(t, imaging.MimeTypeWebp, mimeType)
+
+	config, err := imaging.DecodeConfig(file, imaging.MimeTypeWebp)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, config.Width)
+	assert.Equal(t, 200, config.Height)
+
+	// The png rendition of the same size is cached separately
+	fallback := decodeTestImage(t, svc, data.Id, &model.ImageRendition{Name: "thumbnail"})
+	assert.Equal(t, 200, fallback.Width)
+}
+
+func TestSetImageData_Validation(t *testing.T) {
+	svc := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore:      storage.NewFilesystemBlobStore(t.TempDir()),
+		MaxFileSize:    4096,
+		MaxImageWidth:  100,
+		MaxImageHeight: 100,
+	})
+	original := newTestImage(t, svc, 20, 20)
+
+	tests := []struct {
+		name     string
+		data     io.Reader
+		mimeType string
+		err      error
+	}{
+		{"mismatch", encodeTestImage(t, 10, 10), imaging.MimeTypeJpeg, gallery.ErrImageFormatMismatch},
+		{"unknown format", strings.NewReader("MZ\x90\x00"), "image/png", gallery.ErrImageFormatNotSupported},
+		{"truncated header", bytes.NewReader(encodeTestImage(t, 10, 10).Bytes()[:20]), imaging.MimeTypePng, gallery.ErrImageDataInvalid},
+		{"file too large", io.MultiReader(encodeTestImage(t, 10, 10), bytes.NewReader(make([]byte, 4096))), imaging.MimeTypePng, gallery.ErrImageTooLarge},
+		{"dimensions too large", encodeTestImage(t, 101, 10), imaging.MimeTypePng, gallery.ErrImageTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SetImageData(context.Background(), original.Id, tt.data, tt.mimeType, "test")
+			assert.ErrorIs(t, err, tt.err)
+
+			// The original file is kept
+			data, err := svc.GetImageById(context.Background(), original.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, original.FileName, data.FileName)
+			assert.Equal(t, original.FileSize, data.FileSize)
+			decodeTestImage(t, svc, original.Id, &model.ImageRendition{Width: 20})
+		})
+	}
+}
+
+func TestSetImageData_GifBomb(t *testing.T) {
+	svc := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore: storage.NewFilesystemBlobStore(t.TempDir()),
+	})
+	data, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+
+	// A tiny gif which claims a 30000x30000 frame, decoding it would allocate about 900 MB
+	bomb := []byte("GIF89a\x30\x75\x30\x75\x00\x00\x00")
+	bomb = append(bomb, 0x2c, 0x00, 0x00, 0x00, 0x00, 0x30, 0x75, 0x30, 0x75, 0x00)
+	bomb = append(bomb, 0x08, 0x02, 0x00, 0x01, 0x00, 0x00, 0x3b)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = svc.SetImageData(context.Background(), data.Id, bytes.NewReader(bomb), imaging.MimeTypeGif, "bomb.gif")
+	runtime.ReadMemStats(&after)
+	assert.ErrorIs(t, err, gallery.ErrImageTooLarge)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20), "The frame should not be allocated")
+}
+
+func TestSetImageData_Replace(t *testing.T) {
+	svc, store := newTestServiceWithStore(t)
+	original := newTestImage(t, svc, 20, 20)
+	rendition, _, _, err := svc.GetImageRendition(context.Background(), original.Id, &model.ImageRendition{Name: "thumbnail"})
+	if assert.NoError(t, err) {
+		rendition.Close()
+	}
+
+	// The format is detected when the client doesn't declare it
+	var gifData bytes.Buffer
+	assert.NoError(t, gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 8, 6), color.Palette{color.Black}), nil))
+	gifSize := int64(gifData.Len())
+	gifHash := sha256.Sum256(gifData.Bytes())
+	data, err := svc.SetImageData(context.Background(), original.Id, &gifData, "application/octet-stream", "test.gif")
+	assert.NoError(t, err)
+	assert.Equal(t, imaging.MimeTypeGif, data.MimeType)
+	assert.Equal(t, int32(8), data.Width)
+	assert.Equal(t, gifSize, data.FileSize)
+	assert.Equal(t, hex.EncodeToString(gifHash[:]), data.ContentHash)
+	assert.NotEqual(t, original.ContentHash, data.ContentHash)
+	assert.False(t, data.UploadedAt.IsZero())
+
+	// The previous file has another extension and is removed, with the outdated renditions
+	blobs, err := store.List(context.Background(), "gallery/")
+	assert.NoError(t, err)
+	if assert.Len(t, blobs, 1) {
+		assert.Equal(t, data.StorageFolder+"/"+data.FileName, blobs[0].Key)
+	}
+}
+
+func TestSetImageData_Orientation(t *testing.T) {
+	svc := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore:      storage.NewFilesystemBlobStore(t.TempDir()),
+		RenditionSteps: []int{20},
+	})
+
+	// A landscape photo taken with the camera turned, it's displayed as portrait
+	var plain, photo bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&plain, image.NewNRGBA(image.Rect(0, 0, 60, 40)), nil))
+	assert.NoError(t, imaging.StripMetadata(&plain, &photo, imaging.MimeTypeJpeg, imaging.OrientationRotate90))
+
+	data, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+	data, err = svc.SetImageData(context.Background(), data.Id, &photo, imaging.MimeTypeJpeg, "photo.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int32(imaging.OrientationRotate90), data.Orientation)
+	assert.Equal(t, int32(40), data.Width)
+	assert.Equal(t, int32(60), data.Height)
+	assert.True(t, data.CapturedAt.IsZero())
+
+	// Renditions are upright and have no exif
+	file, _, _, err := svc.GetImageRendition(context.Background(), data.Id, &model.ImageRendition{Width: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+	rendition, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	config, err := jpeg.DecodeConfig(bytes.NewReader(rendition))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, config.Width)
+	assert.Equal(t, 30, config.Height)
+	metadata, err := imaging.DecodeMetadata(bytes.NewReader(rendition), imaging.MimeTypeJpeg)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, metadata.Orientation)
+
+	// The public original keeps the orientation, so browsers display it upright
+	public, mimeType, _, err := svc.GetPublicImageData(context.Background(), data.Id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer public.Close()
+	assert.Equal(t, imaging.MimeTypeJpeg, mimeType)
+	metadata, err = imaging.DecodeMetadata(public, imaging.MimeTypeJpeg)
+	assert.NoError(t, err)
+	assert.Equal(t, imaging.OrientationRotate90, metadata.Orientation)
+}
+
+func TestCreateImage_Visibility(t *testing.T) {
+	svc := newTestService(t)
+
+	data, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+	assert.Equal(t, model.ImageVisibilityPublic, data.Visibility)
+
+	_, err = svc.CreateImage(context.Background(), &model.Image{Visibility: "hidden"})
+	assert.Equal(t, gallery.ErrImageVisibilityInvalid, err)
+
+	_, err = svc.UpdateImage(context.Background(), data.Id, &model.Image{Visibility: "hidden"})
+	assert.Equal(t, gallery.ErrImageVisibilityInvalid, err)
+
+	data, err = svc.UpdateImage(context.Background(), data.Id, &model.Image{Visibility: model.ImageVisibilityPrivate})
+	assert.NoError(t, err)
+	assert.True(t, data.IsPrivate())
+
+	// An update without visibility keeps the image private
+	data, err = svc.UpdateImage(context.Background(), data.Id, &model.Image{OriginalFileName: "renamed.png"})
+	assert.NoError(t, err)
+	assert.True(t, data.IsPrivate())
+	assert.Equal(t, "renamed.png", data.OriginalFileName)
+}
+
+func TestGetImageUrl(t *testing.T) {
+	svc := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore:        storage.NewFilesystemBlobStore(t.TempDir()),
+		UrlSigningSecret: "test secret",
+	})
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	svc.(*service).now = func() time.Time { return now }
+
+	// Public images aren't signed
+	public, err := svc.CreateImage(context.Background(), &model.Image{})
+	assert.NoError(t, err)
+	imageUrl, err := svc.GetImageUrl(context.Background(), public.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "/assets/gallery/"+public.Id, imageUrl.Url)
+	assert.True(t, imageUrl.ExpiresAt.IsZero())
+
+	private, err := svc.CreateImage(context.Background(), &model.Image{Visibility: model.ImageVisibilityPrivate})
+	assert.NoError(t, err)
+	imageUrl, err = svc.GetImageUrl(context.Background(), private.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(15*time.Minute), imageUrl.ExpiresAt)
+	parsed, err := url.Parse(imageUrl.Url)
+	assert.NoError(t, err)
+	assert.Equal(t, "/assets/gallery/"+private.Id, parsed.Path)
+	expires := parsed.Query().Get("expires")
+	signature := parsed.Query().Get("signature")
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name      string
+		id        string
+		expires   string
+		signature string
+		expected  error
+	}{
+		{"valid", private.Id, expires, signature, nil},
+		{"missing signature", private.Id, expires, "", gallery.ErrImageUrlInvalid},
+		{"other image", public.Id, expires, signature, gallery.ErrImageUrlInvalid},
+		{"extended expiration", private.Id, strconv.FormatInt(now.Add(time.Hour).Unix(), 10), signature, gallery.ErrImageUrlInvalid},
+		{"tampered signature", private.Id, expires, string(tampered), gallery.ErrImageUrlInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, svc.VerifyImageUrl(context.Background(), tt.id, tt.expires, tt.signature))
+		})
+	}
+
+	// The url can't be used after it expires
+	now = now.Add(15 * time.Minute)
+	assert.Equal(t, gallery.ErrImageUrlExpired, svc.VerifyImageUrl(context.Background(), private.Id, expires, signature))
+
+	// Urls signed with another secret aren't valid
+	other := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore:        storage.NewFilesystemBlobStore(t.TempDir()),
+		UrlSigningSecret: "other secret",
+	})
+	assert.Equal(t, gallery.ErrImageUrlInvalid, other.VerifyImageUrl(context.Background(), private.Id, expires, signature))
+
+	// Without a secret private images can't be signed, and an empty key is never accepted
+	unsigned := NewService(newMemoryGalleryDatabase(), &ServiceOptions{
+		BlobStore: storage.NewFilesystemBlobStore(t.TempDir()),
+	})
+	private, err = unsigned.CreateImage(context.Background(), &model.Image{Visibility: model.ImageVisibilityPrivate})
+	assert.NoError(t, err)
+	_, err = unsigned.GetImageUrl(context.Background(), private.Id)
+	assert.Equal(t, gallery.ErrUrlSigningSecretMissing, err)
+	assert.Equal(t, gallery.ErrImageUrlInvalid, unsigned.VerifyImageUrl(context.Background(), private.Id, expires, signature))
+}
+
+func TestEnsureUrlSigningSecret(t *testing.T) {
+	opts := &ServiceOptions{UrlSigningSecret: "configured"}
+	assert.NoError(t, opts.EnsureUrlSigningSecret())
+	assert.Equal(t, "configured", opts.UrlSigningSecret)
+
+	opts = &ServiceOptions{}
+	assert.NoError(t, opts.EnsureUrlSigningSecret())
+	assert.NotEmpty(t, opts.UrlSigningSecret)
+}
@@ -1,14 +1,16 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"time"
 
@@ -16,7 +18,9 @@ import (
 	"github.com/deb-ict/cloudbm-community/pkg/localization"
 	"github.com/deb-ict/cloudbm-community/pkg/logging"
 	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/imaging"
 	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
+	"github.com/deb-ict/cloudbm-community/pkg/storage"
 )
 
 func (svc *service) GetImages(ctx context.Context, offset int64, limit int64, filter *model.ImageFilter, sort *core.Sort) ([]*model.Image, int64, error) {
@@ -101,8 +105,14 @@ func (svc *service) CreateImage(ctx context.Context, model *model.Image) (*model
 	model.FileName = ""
 	model.FileSize = 0
 	model.MimeType = ""
+	model.ContentHash = ""
+	model.UploadedAt = time.Time{}
 	model.Width = 0
 	model.Height = 0
+	model.EnsureDefaults()
+	if !model.Visibility.IsValid() {
+		return nil, gallery.ErrImageVisibilityInvalid
+	}
 
 	err := svc.checkDuplicateImage(ctx, model)
 	if err != nil {
@@ -123,6 +133,9 @@ func (svc *service) CreateImage(ctx context.Context, model *model.Image) (*model
 func (svc *service) UpdateImage(ctx context.Context, id string, model *model.Image) (*model.Image, error) {
 	model.Normalize(svc.stringNormalizer)
 	model.Id = id
+	if model.Visibility != "" && !model.Visibility.IsValid() {
+		return nil, gallery.ErrImageVisibilityInvalid
+	}
 
 	err := svc.checkDuplicateImage(ctx, model)
 	if err != nil {
@@ -175,42 +188,29 @@ func (svc *service) DeleteImage(ctx context.Context, id string) error {
 		)
 		return err
 	}
+	svc.deleteImageRenditions(ctx, id)
 
 	return nil
 }
 
 func (svc *service) GetImageData(ctx context.Context, id string) (io.ReadCloser, string, string, error) {
-	logger := logging.GetLoggerFromContext(ctx)
-
-	data, err := svc.database.Images().GetImageById(ctx, id)
+	data, err := svc.GetImageById(ctx, id)
 	if err != nil {
-		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get image from database by id",
-			slog.String("id", id),
-			slog.Any("error", err),
-		)
 		return nil, "", "", err
 	}
-	if data == nil {
-		return nil, "", "", gallery.ErrImageNotFound
+	if data.FileName == "" {
+		return nil, "", "", gallery.ErrImageFileNotFound
 	}
 
-	localFilePath := svc.StorageProvider().GetPath(ctx, data.StorageFolder, data.FileName)
-
-	_, err = os.Stat(localFilePath)
-	if err != nil {
-		logger.ErrorContext(ctx, "Failed to stat image file",
-			slog.String("id", id),
-			slog.String("path", localFilePath),
-			slog.Any("error", err),
-		)
-		return nil, "", "", err
+	key := getImageKey(data)
+	file, _, err := svc.blobStore.Get(ctx, key)
+	if err == storage.ErrBlobNotFound {
+		return nil, "", "", gallery.ErrImageFileNotFound
 	}
-
-	file, err := os.OpenFile(localFilePath, os.O_RDONLY, 0)
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to open image file",
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to open image file",
 			slog.String("id", id),
-			slog.String("path", localFilePath),
+			slog.String("key", key),
 			slog.Any("error", err),
 		)
 		return nil, "", "", err
@@ -219,125 +219,216 @@ func (svc *service) GetImageData(ctx context.Context, id string) (io.ReadCloser,
 	return file, data.MimeType, data.OriginalFileName, nil
 }
 
+// SetImageData stores the image file. The format is detected from the content, a declared mime type
+// that doesn't match is rejected. The file is validated in a local temporary file first and only
+// replaces the current file when it is valid, so a failed upload never leaves a partial file behind.
 func (svc *service) SetImageData(ctx context.Context, id string, file io.Reader, mimeType string, originalFileName string) (*model.Image, error) {
 	logger := logging.GetLoggerFromContext(ctx)
 
-	// Get the file extension based on the mime type
-	fileExt := ""
-	switch mimeType {
-	case "image/jpeg":
-		fileExt = ".jpg"
-	case "image/png":
-		fileExt = ".png"
-	default:
-		return nil, gallery.ErrImageFormatNotSupported
-	}
-
-	// Get the storage folder
-	now := time.Now().UTC()
-	localFileName := fmt.Sprintf("%s%s", id, fileExt)
-	localFolder := filepath.Join("gallery", "images", fmt.Sprintf("%04d/%02d", now.Year(), int(now.Month())))
-	localFilePath := svc.storageProvider.GetPath(ctx, localFolder, localFileName)
-	err := core.EnsureFolder(localFolder)
+	data, err := svc.GetImageById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Open the local file
-	localFile, err := os.OpenFile(localFilePath, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		logger.ErrorContext(ctx, "Failed to open image file",
+	// Detect the format from the first bytes
+	header := make([]byte, imaging.DetectFormatLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+	detectedMimeType := imaging.DetectFormat(header)
+	if detectedMimeType == "" {
+		return nil, gallery.ErrImageFormatNotSupported
+	}
+	if mimeType != "" && mimeType != "application/octet-stream" && mimeType != detectedMimeType {
+		logger.WarnContext(ctx, "Rejected image with mismatching mime type",
 			slog.String("id", id),
-			slog.String("path", localFilePath),
-			slog.Any("error", err),
+			slog.String("mimeType", mimeType),
+			slog.String("detectedMimeType", detectedMimeType),
 		)
-		return nil, err
+		return nil, gallery.ErrImageFormatMismatch
 	}
-	defer localFile.Close()
+	mimeType = detectedMimeType
+	fileExt, _ := imaging.FileExtension(mimeType)
 
-	// Copy the file data
-	_, err = io.Copy(localFile, file)
+	// Validate the image in a temporary file, the dimensions and metadata are read from it
+	tempFile, err := os.CreateTemp("", "cbm-upload-*")
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to write image file",
+		logger.ErrorContext(ctx, "Failed to create temporary image file",
 			slog.String("id", id),
-			slog.String("path", localFilePath),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
 
-	// Set the image file info
-	image, err := svc.setImageFileInfo(ctx, logger, id, localFolder, localFileName, originalFileName, mimeType)
+	fileInfo, err := svc.writeImageFile(ctx, tempFile, io.MultiReader(bytes.NewReader(header), file), mimeType)
 	if err != nil {
 		return nil, err
 	}
 
-	return image, nil
-}
-
-func (svc *service) setImageFileInfo(ctx context.Context, logger *slog.Logger, id string, localFolder string, localFileName string, originalFileName string, mimeType string) (*model.Image, error) {
-	data, err := svc.database.Images().GetImageById(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	if data == nil {
-		return nil, gallery.ErrImageNotFound
+	// Store the image file
+	now := time.Now().UTC()
+	previousKey := ""
+	if data.FileName != "" {
+		previousKey = getImageKey(data)
 	}
+	data.OriginalFileName = originalFileName
+	data.StorageFolder = path.Join("gallery", "images", fmt.Sprintf("%04d/%02d", now.Year(), int(now.Month())))
+	data.FileName = fmt.Sprintf("%s%s", path.Base(id), fileExt)
+	key := getImageKey(data)
 
-	localFilePath := svc.storageProvider.GetPath(ctx, localFolder, localFileName)
-	fileInfo, err := os.Stat(localFilePath)
+	_, err = tempFile.Seek(0, io.SeekStart)
+	if err == nil {
+		err = svc.blobStore.Put(ctx, key, tempFile, fileInfo.size, mimeType)
+	}
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to stat image file",
+		logger.ErrorContext(ctx, "Failed to write image file",
 			slog.String("id", id),
-			slog.String("path", localFilePath),
+			slog.String("key", key),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
 
-	file, err := os.Open(localFilePath)
+	// Set the image file info
+	data.FileSize = fileInfo.size
+	data.MimeType = mimeType
+	data.ContentHash = fileInfo.contentHash
+	data.UploadedAt = now
+	data.Width = int32(fileInfo.width)
+	data.Height = int32(fileInfo.height)
+	data.CapturedAt = fileInfo.metadata.CapturedAt
+	data.CameraMake = fileInfo.metadata.CameraMake
+	data.CameraModel = fileInfo.metadata.CameraModel
+	data.Orientation = int32(fileInfo.metadata.Orientation)
+
+	err = svc.database.Images().UpdateImage(ctx, data)
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to open image file",
+		logger.ErrorContext(ctx, "Failed to update image in database",
 			slog.String("id", id),
-			slog.String("path", localFilePath),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
-	defer file.Close()
 
-	var config image.Config
-	switch mimeType {
-	case "image/jpeg":
-		config, err = jpeg.DecodeConfig(file)
-	case "image/png":
-		config, err = png.DecodeConfig(file)
-	default:
-		err = gallery.ErrImageFormatNotSupported
+	// The previous file is replaced when it has the same key, otherwise it is removed
+	if previousKey != "" && previousKey != key {
+		err = svc.blobStore.Delete(ctx, previousKey)
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to delete previous image file",
+				slog.String("id", id),
+				slog.String("key", previousKey),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	// Renditions of the previous file are outdated
+	svc.deleteImageRenditions(ctx, id)
+
+	return svc.GetImageById(ctx, id)
+}
+
+// getImageKey returns the blob key of the image file, images stored before blob stores were
+// introduced use the same relative path.
+func getImageKey(data *model.Image) string {
+	return path.Join(filepath.ToSlash(data.StorageFolder), data.FileName)
+}
+
+type imageFileInfo struct {
+	size        int64
+	contentHash string
+	width       int
+	height      int
+	metadata    *imaging.Metadata
+}
+
+// writeImageFile copies the data into the file within the size limit and validates the dimensions, svg
+// files are sanitized as they are served to browsers.
+func (svc *service) writeImageFile(ctx context.Context, file *os.File, data io.Reader, mimeType string) (*imageFileInfo, error) {
+	logger := logging.GetLoggerFromContext(ctx)
+
+	// Read one byte more than allowed to detect files that are too large
+	limited := &io.LimitedReader{R: data, N: svc.maxFileSize + 1}
+	// The hash is computed over the stored data, which differs from the upload for svg files
+	hash := sha256.New()
+	output := io.MultiWriter(file, hash)
+	var err error
+	if mimeType == imaging.MimeTypeSvg {
+		err = imaging.SanitizeSvg(limited, output)
+	} else {
+		_, err = io.Copy(output, limited)
+	}
+	if limited.N <= 0 {
+		return nil, gallery.ErrImageTooLarge
+	}
+	if errors.Is(err, imaging.ErrSvgInvalid) {
+		logger.WarnContext(ctx, "Rejected invalid svg image",
+			slog.String("path", file.Name()),
+			slog.Any("error", err),
+		)
+		return nil, gallery.ErrImageDataInvalid
 	}
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to decode image config",
-			slog.String("id", id),
-			slog.String("path", localFilePath),
+		logger.ErrorContext(ctx, "Failed to write image file",
+			slog.String("path", file.Name()),
 			slog.Any("error", err),
 		)
 		return nil, err
 	}
 
-	data.OriginalFileName = originalFileName
-	data.StorageFolder = localFolder
-	data.FileName = localFileName
-	data.FileSize = fileInfo.Size()
-	data.MimeType = mimeType
-	data.Width = int32(config.Width)
-	data.Height = int32(config.Height)
+	fileSize, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
 
-	err = svc.database.Images().UpdateImage(ctx, data)
+	// The dimensions are checked before the image is ever decoded, to refuse decompression bombs. Only the
+	// headers are read, for a gif as well, so nothing is allocated at the claimed size.
+	config, err := imaging.DecodeConfig(file, mimeType)
+	if err != nil {
+		logger.WarnContext(ctx, "Rejected image that can't be decoded",
+			slog.String("path", file.Name()),
+			slog.String("mimeType", mimeType),
+			slog.Any("error", err),
+		)
+		return nil, gallery.ErrImageDataInvalid
+	}
+	if config.Width > svc.maxImageWidth || config.Height > svc.maxImageHeight {
+		return nil, gallery.ErrImageTooLarge
+	}
+
+	// Invalid metadata doesn't make the image invalid, it's ignored
+	_, err = file.Seek(0, io.SeekStart)
 	if err != nil {
 		return nil, err
 	}
+	metadata, err := imaging.DecodeMetadata(file, mimeType)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to read image metadata",
+			slog.String("path", file.Name()),
+			slog.Any("error", err),
+		)
+		metadata = &imaging.Metadata{}
+	}
 
-	return svc.GetImageById(ctx, id)
+	fileInfo := &imageFileInfo{
+		size:        fileSize,
+		contentHash: hex.EncodeToString(hash.Sum(nil)),
+		width:       config.Width,
+		height:      config.Height,
+		metadata:    metadata,
+	}
+	if imaging.IsTransposed(metadata.Orientation) {
+		fileInfo.width, fileInfo.height = config.Height, config.Width
+	}
+	return fileInfo, nil
 }
 
 func (svc *service) checkDuplicateImage(ctx context.Context, model *model.Image) error {
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/imaging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
+	"github.com/deb-ict/cloudbm-community/pkg/storage"
+)
+
+// GetPublicImageData returns the original image without personal metadata like the location, for
+// serving to anonymous users. The stripped copy is cached with the renditions.
+func (svc *service) GetPublicImageData(ctx context.Context, id string) (io.ReadCloser, string, string, error) {
+	data, err := svc.GetImageById(ctx, id)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if data.FileName == "" {
+		return nil, "", "", gallery.ErrImageFileNotFound
+	}
+	if data.MimeType != imaging.MimeTypeJpeg && data.MimeType != imaging.MimeTypePng && data.MimeType != imaging.MimeTypeWebp {
+		return svc.GetImageData(ctx, id)
+	}
+
+	fileExt, _ := imaging.FileExtension(data.MimeType)
+	file, err := svc.openCachedFile(ctx, data, "public"+fileExt, data.MimeType, func(w io.Writer, src io.Reader) error {
+		return imaging.StripMetadata(src, w, data.MimeType, int(data.Orientation))
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return file, data.MimeType, data.OriginalFileName, nil
+}
+
+// GetImageRendition returns a resized version of the image. Renditions are rendered on first use and
+// cached in the blob store, until the image data is replaced. Custom sizes are rounded to the rendition
+// steps, which limits the number of cached files per image. Vector images are returned as is.
+func (svc *service) GetImageRendition(ctx context.Context, id string, rendition *model.ImageRendition) (io.ReadCloser, string, string, error) {
+	size, err := svc.resolveRendition(rendition)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	data, err := svc.GetImageById(ctx, id)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if data.FileName == "" {
+		return nil, "", "", gallery.ErrImageFileNotFound
+	}
+	if imaging.IsVector(data.MimeType) {
+		return svc.GetImageData(ctx, id)
+	}
+
+	mimeType := imaging.RenditionFormat(data.MimeType, rendition.Format)
+	fileExt, _ := imaging.FileExtension(mimeType)
+	renditionFileName := fmt.Sprintf("%dx%d-%s%s", size.Width, size.Height, size.Fit, fileExt)
+	file, err := svc.openCachedFile(ctx, data, renditionFileName, mimeType, func(w io.Writer, src io.Reader) error {
+		return svc.renderImageRendition(ctx, data, size, mimeType, w, src)
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return file, mimeType, data.OriginalFileName, nil
+}
+
+func (svc *service) resolveRendition(rendition *model.ImageRendition) (*RenditionOptions, error) {
+	if rendition == nil {
+		return nil, gallery.ErrRenditionInvalid
+	}
+	if rendition.Format != "" && rendition.Format != imaging.MimeTypeWebp {
+		return nil, gallery.ErrRenditionInvalid
+	}
+	if rendition.Name != "" {
+		size, ok := svc.renditions[rendition.Name]
+		if !ok {
+			return nil, gallery.ErrRenditionNotFound
+		}
+		return &size, nil
+	}
+
+	size := &RenditionOptions{
+		Width:  rendition.Width,
+		Height: rendition.Height,
+		Fit:    rendition.Fit,
+	}
+	if size.Fit == "" {
+		size.Fit = imaging.FitContain
+	}
+	if !imaging.IsValidFit(size.Fit) {
+		return nil, gallery.ErrRenditionInvalid
+	}
+	if size.Width < 0 || size.Height < 0 || (size.Width == 0 && size.Height == 0) {
+		return nil, gallery.ErrRenditionInvalid
+	}
+	if size.Width > svc.maxRenditionWidth || size.Height > svc.maxRenditionHeight {
+		return nil, gallery.ErrRenditionInvalid
+	}
+	size.Width = svc.snapRenditionSize(size.Width)
+	size.Height = svc.snapRenditionSize(size.Height)
+	return size, nil
+}
+
+// snapRenditionSize rounds a custom dimension up to the next rendition step, a dimension larger than
+// every step is rounded down to the largest. An unset dimension stays unset.
+func (svc *service) snapRenditionSize(value int) int {
+	if value <= 0 {
+		return 0
+	}
+	for _, step := range svc.renditionSteps {
+		if value <= step {
+			return step
+		}
+	}
+	return svc.renditionSteps[len(svc.renditionSteps)-1]
+}
+
+func (svc *service) renderImageRendition(ctx context.Context, data *model.Image, size *RenditionOptions, mimeType string, w io.Writer, src io.Reader) error {
+	img, err := imaging.Decode(src)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to decode image file",
+			slog.String("id", data.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	// Renditions have no exif, the image is turned upright before it's resized
+	img = imaging.Orient(img, int(data.Orientation))
+	dst := imaging.Resize(img, size.Width, size.Height, size.Fit)
+	return imaging.Encode(w, dst, mimeType)
+}
+
+// openCachedFile opens a file derived from the image file, it's written on first use. The file is
+// written to a local temporary file first and stored when complete, so a partial file is never served.
+func (svc *service) openCachedFile(ctx context.Context, data *model.Image, fileName string, mimeType string, write func(w io.Writer, src io.Reader) error) (io.ReadCloser, error) {
+	logger := logging.GetLoggerFromContext(ctx)
+
+	key := path.Join(getRenditionPrefix(data.Id), fileName)
+	file, _, err := svc.blobStore.Get(ctx, key)
+	if err != storage.ErrBlobNotFound {
+		return file, err
+	}
+
+	src, _, err := svc.blobStore.Get(ctx, getImageKey(data))
+	if err == storage.ErrBlobNotFound {
+		return nil, gallery.ErrImageFileNotFound
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to open image file",
+			slog.String("id", data.Id),
+			slog.String("key", getImageKey(data)),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	defer src.Close()
+
+	tempFile, err := os.CreateTemp("", "cbm-rendition-*")
+	if err != nil {
+		return nil, err
+	}
+	cached := &tempFileReader{File: tempFile}
+
+	err = write(tempFile, src)
+	var size int64
+	if err == nil {
+		size, err = tempFile.Seek(0, io.SeekEnd)
+	}
+	if err == nil {
+		_, err = tempFile.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = svc.blobStore.Put(ctx, key, tempFile, size, mimeType)
+	}
+	if err == nil {
+		// The temporary file is served, instead of reading the stored file again
+		_, err = tempFile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cached.Close()
+		logger.ErrorContext(ctx, "Failed to write image rendition file",
+			slog.String("id", data.Id),
+			slog.String("key", key),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	return cached, nil
+}
+
+// deleteImageRenditions removes the cached renditions, a failure only leaves stale files behind.
+func (svc *service) deleteImageRenditions(ctx context.Context, id string) {
+	logger := logging.GetLoggerFromContext(ctx)
+
+	prefix := getRenditionPrefix(id) + "/"
+	renditions, err := svc.blobStore.List(ctx, prefix)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to list image renditions",
+			slog.String("id", id),
+			slog.String("prefix", prefix),
+			slog.Any("error", err),
+		)
+		return
+	}
+	for _, rendition := range renditions {
+		err = svc.blobStore.Delete(ctx, rendition.Key)
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to delete image rendition",
+				slog.String("id", id),
+				slog.String("key", rendition.Key),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+func getRenditionPrefix(id string) string {
+	return path.Join("gallery", "renditions", path.Base(id))
+}
+
+// tempFileReader removes the temporary file when it's closed.
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	os.Remove(r.File.Name())
+	return err
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery"
+	"github.com/deb-ict/cloudbm-community/pkg/module/gallery/model"
+)
+
+// GetImageUrl returns the url the image is served on. Urls of private images are signed and expire,
+// the signature covers the image and not the rendition, so size parameters can be added to the url.
+func (svc *service) GetImageUrl(ctx context.Context, id string) (*model.ImageUrl, error) {
+	data, err := svc.GetImageById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	imageUrl := &model.ImageUrl{
+		Url: svc.imageBaseUri + url.PathEscape(data.Id),
+	}
+	if !data.IsPrivate() {
+		return imageUrl, nil
+	}
+	if len(svc.urlSigningKey) == 0 {
+		return nil, gallery.ErrUrlSigningSecretMissing
+	}
+
+	// The expiration is rounded to seconds, as it's part of the signature
+	imageUrl.ExpiresAt = svc.now().Add(svc.signedUrlLifetime).UTC().Truncate(time.Second)
+	expires := strconv.FormatInt(imageUrl.ExpiresAt.Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {svc.signImageUrl(data.Id, expires)},
+	}
+	imageUrl.Url += "?" + query.Encode()
+
+	return imageUrl, nil
+}
+
+// VerifyImageUrl checks the signature and expiration of a signed image url.
+func (svc *service) VerifyImageUrl(ctx context.Context, id string, expires string, signature string) error {
+	if expires == "" || signature == "" || len(svc.urlSigningKey) == 0 {
+		return gallery.ErrImageUrlInvalid
+	}
+	expected := svc.signImageUrl(id, expires)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return gallery.ErrImageUrlInvalid
+	}
+
+	// The expiration is only trusted once the signature is verified
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return gallery.ErrImageUrlInvalid
+	}
+	if !svc.now().Before(time.Unix(expiresAt, 0)) {
+		return gallery.ErrImageUrlExpired
+	}
+	return nil
+}
+
+func (svc *service) signImageUrl(id string, expires string) string {
+	mac := hmac.New(sha256.New, svc.urlSigningKey)
+	mac.Write([]byte(id + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -11,6 +11,7 @@ import (
 )
 
 const (
+	PolicyAuthenticatedV1   = "session_api:Authenticated:v1"
 	PolicyReadSessionsV1    = "session_api:ReadSessions:v1"
 	PolicyCreateSessionsV1  = "session_api:CreateSessions:v1"
 	PolicyUpdateSessionsV1  = "session_api:UpdateSessions:v1"
@@ -34,6 +35,7 @@ func NewApiV1(service session.Service) ApiV1 {
 }
 
 func (api *apiV1) RegisterAuthorizationPolicies(middleware *authorization.Middleware) {
+	middleware.SetPolicy(authorization.NewPolicy(PolicyAuthenticatedV1))
 	middleware.SetPolicy(authorization.NewPolicy(PolicyReadSessionsV1,
 		authorization.NewScopeRequirement("session.read"),
 	))
@@ -76,6 +78,24 @@ func (api *apiV1) RegisterRoutes(r *router.Router) {
 		router.AllowedMethod(http.MethodPost),
 		router.Authorized(PolicyCleanupSessionsV1),
 	)
+	r.HandleFunc("/v1/user/{userId}/session", api.LogoutUserHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyDeleteSessionsV1),
+	)
+
+	// Sessions of the current user
+	r.HandleFunc("/v1/me/session", api.GetCurrentUserSessionsHandlerV1,
+		router.AllowedMethod(http.MethodGet),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/session", api.LogoutCurrentUserHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
+	r.HandleFunc("/v1/me/session/{id}", api.DeleteCurrentUserSessionHandlerV1,
+		router.AllowedMethod(http.MethodDelete),
+		router.Authorized(PolicyAuthenticatedV1),
+	)
 }
 
 func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
@@ -88,6 +108,10 @@ func (api *apiV1) handleError(w http.ResponseWriter, err error) bool {
 		rest.WriteError(w, http.StatusNotFound, err.Error())
 	case session.ErrSessionExpired:
 		rest.WriteError(w, http.StatusNotFound, err.Error())
+	case session.ErrSessionConflict:
+		rest.WriteError(w, http.StatusConflict, err.Error())
+	case session.ErrSessionDataInvalid:
+		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	case core.ErrInvalidId:
 		rest.WriteError(w, http.StatusBadRequest, err.Error())
 	default:
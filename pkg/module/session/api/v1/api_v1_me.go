@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/deb-ict/cloudbm-community/pkg/http/rest"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+	"github.com/deb-ict/go-router"
+	"github.com/deb-ict/go-router/authentication"
+)
+
+func (api *apiV1) GetCurrentUserSessionsHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	filter := &model.SessionFilter{
+		UserId: userId,
+	}
+	paging := rest.GetPaging(r)
+	sort := rest.GetSorting(r)
+
+	result, count, err := api.service.GetSessions(ctx, (paging.PageIndex-1)*paging.PageSize, paging.PageSize, filter, sort)
+	if api.handleError(w, err) {
+		return
+	}
+
+	response := SessionListV1{
+		PaginatedList: rest.PaginatedList{
+			PageIndex: paging.PageIndex,
+			PageSize:  paging.PageSize,
+			ItemCount: count,
+		},
+		Items: make([]*SessionListItemV1, 0),
+	}
+	for _, item := range result {
+		response.Items = append(response.Items, SessionToListItemViewModelV1(item))
+	}
+
+	rest.WriteResult(w, response)
+}
+
+func (api *apiV1) DeleteCurrentUserSessionHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	id := router.Param(r, "id")
+
+	err := api.service.DeleteUserSession(ctx, userId, id)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+// LogoutCurrentUserHandlerV1 deletes all sessions of the current user, logging out everywhere.
+func (api *apiV1) LogoutCurrentUserHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId, ok := api.currentUserId(w, r)
+	if !ok {
+		return
+	}
+
+	err := api.service.LogoutUser(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
+func (api *apiV1) currentUserId(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := authentication.GetContext(r.Context()).GetSubjectId()
+	if userId == "" {
+		rest.WriteStatus(w, http.StatusUnauthorized)
+		return "", false
+	}
+	return userId, true
+}
@@ -19,6 +19,7 @@ type SessionV1 struct {
 	Lifetime             time.Duration     `json:"lifetime"`
 	UseSlidingExpiration bool              `json:"useSlidingExpiration"`
 	Data                 map[string]string `json:"data"`
+	Version              int64             `json:"version"`
 }
 
 type SessionListV1 struct {
@@ -48,6 +49,8 @@ type UpdateSessionV1 struct {
 	Lifetime             time.Duration     `json:"lifetime"`
 	UseSlidingExpiration bool              `json:"useSlidingExpiration"`
 	Data                 map[string]string `json:"data"`
+	// The version that was read, the update fails with a conflict when the session changed since
+	Version int64 `json:"version"`
 }
 
 func (api *apiV1) GetSessionsHandlerV1(w http.ResponseWriter, r *http.Request) {
@@ -152,8 +155,23 @@ func (api *apiV1) CleanupExpiredSessionsHandlerV1(w http.ResponseWriter, r *http
 	rest.WriteStatus(w, http.StatusNoContent)
 }
 
+func (api *apiV1) LogoutUserHandlerV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userId := router.Param(r, "userId")
+
+	err := api.service.LogoutUser(ctx, userId)
+	if api.handleError(w, err) {
+		return
+	}
+
+	rest.WriteStatus(w, http.StatusNoContent)
+}
+
 func (api *apiV1) parseSessionFilterV1(r *http.Request) *model.SessionFilter {
-	return &model.SessionFilter{}
+	return &model.SessionFilter{
+		UserId: r.URL.Query().Get("userId"),
+	}
 }
 
 func SessionToViewModelV1(model *model.Session) *SessionV1 {
@@ -166,6 +184,7 @@ func SessionToViewModelV1(model *model.Session) *SessionV1 {
 		Lifetime:             model.Lifetime,
 		UseSlidingExpiration: model.UseSlidingExpiration,
 		Data:                 make(map[string]string),
+		Version:              model.Version,
 	}
 	for key, value := range model.Data {
 		viewModel.Data[key] = value
@@ -204,6 +223,7 @@ func SessionFromUpdateViewModelV1(viewModel *UpdateSessionV1) *model.Session {
 		Lifetime:             viewModel.Lifetime,
 		UseSlidingExpiration: viewModel.UseSlidingExpiration,
 		Data:                 make(map[string]string),
+		Version:              viewModel.Version,
 	}
 	for key, value := range viewModel.Data {
 		model.Data[key] = value
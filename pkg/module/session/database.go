@@ -15,6 +15,8 @@ type SessionRepository interface {
 	GetSessions(ctx context.Context, offset int64, limit int64, filter *model.SessionFilter, sort *core.Sort) ([]*model.Session, int64, error)
 	GetSessionById(ctx context.Context, id string) (*model.Session, error)
 	CreateSession(ctx context.Context, model *model.Session) (string, error)
+	// UpdateSession only stores the session when the stored version equals model.Version, otherwise it
+	// returns ErrSessionConflict. On success the version is incremented, also on the given model.
 	UpdateSession(ctx context.Context, model *model.Session) error
 	DeleteSession(ctx context.Context, model *model.Session) error
 }
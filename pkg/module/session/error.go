@@ -6,4 +6,6 @@ var (
 	ErrSessionNotFound     error = errors.New("session not found")
 	ErrSessionDataNotFound error = errors.New("session data not found")
 	ErrSessionExpired      error = errors.New("session expired")
+	ErrSessionConflict     error = errors.New("session was changed by another request")
+	ErrSessionDataInvalid  error = errors.New("session data invalid")
 )
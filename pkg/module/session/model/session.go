@@ -13,9 +13,14 @@ type Session struct {
 	Lifetime             time.Duration
 	UseSlidingExpiration bool
 	Data                 map[string]string
+	// Incremented on every update, used for optimistic concurrency
+	Version int64
+	// Data as stored in the database when encryption at rest is enabled, Data is empty then
+	EncryptedData string
 }
 
 type SessionFilter struct {
+	UserId        string
 	CreatedBefore time.Time
 	CreatedAfter  time.Time
 	UpdatedBefore time.Time
@@ -27,11 +32,16 @@ type SessionFilter struct {
 func (m *Session) UpdateModel(other *Session) {
 	m.UserId = other.UserId
 	m.Lifetime = other.Lifetime
-	m.UpdatedAt = time.Now().UTC()
 	m.Data = make(map[string]string)
 	for k, v := range other.Data {
 		m.Data[k] = v
 	}
+	m.Touch()
+}
+
+// Touch marks the session as updated and extends a sliding expiration.
+func (m *Session) Touch() {
+	m.UpdatedAt = time.Now().UTC()
 	if m.UseSlidingExpiration {
 		m.ExpiresAt = m.UpdatedAt.Add(m.Lifetime)
 	}
@@ -63,6 +73,8 @@ func (m *Session) Clone() *Session {
 		Lifetime:             m.Lifetime,
 		UseSlidingExpiration: m.UseSlidingExpiration,
 		Data:                 make(map[string]string),
+		Version:              m.Version,
+		EncryptedData:        m.EncryptedData,
 	}
 	for k, v := range m.Data {
 		model.Data[k] = v
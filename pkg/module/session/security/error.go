@@ -0,0 +1,10 @@
+package security
+
+import "errors"
+
+var (
+	ErrKeyringEmpty          error = errors.New("keyring has no keys")
+	ErrEncryptionKeyNotFound error = errors.New("encryption key not found")
+	ErrEncryptionKeyInvalid  error = errors.New("encryption key invalid")
+	ErrCiphertextInvalid     error = errors.New("ciphertext invalid")
+)
@@ -0,0 +1,131 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Keyring encrypts session data with AES-GCM. Values are encrypted with the primary key and
+// prefixed with its id, so values encrypted with a previous key can still be decrypted after rotation.
+type Keyring interface {
+	Encrypt(plaintext []byte, additionalData []byte) (string, error)
+	Decrypt(value string, additionalData []byte) ([]byte, error)
+}
+
+type KeyringOptions struct {
+	// The first key encrypts, the other keys only decrypt values encrypted before the rotation
+	Keys []EncryptionKeyOptions `yaml:"keys"`
+}
+
+type EncryptionKeyOptions struct {
+	Id string `yaml:"id"`
+	// Base64 encoded key of 16, 24 or 32 bytes
+	Secret string `yaml:"secret"`
+}
+
+type keyring struct {
+	primary *encryptionKey
+	keys    map[string]*encryptionKey
+}
+
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func NewKeyring(opts *KeyringOptions) (Keyring, error) {
+	if opts == nil || len(opts.Keys) == 0 {
+		return nil, ErrKeyringEmpty
+	}
+
+	k := &keyring{
+		keys: make(map[string]*encryptionKey),
+	}
+	for i := range opts.Keys {
+		key, err := loadEncryptionKey(&opts.Keys[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := k.keys[key.id]; exists {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrEncryptionKeyInvalid, key.id)
+		}
+		if k.primary == nil {
+			k.primary = key
+		}
+		k.keys[key.id] = key
+	}
+
+	return k, nil
+}
+
+// GenerateEncryptionKey returns a random base64 encoded AES-256 key for the configuration.
+func GenerateEncryptionKey() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+func (k *keyring) Encrypt(plaintext []byte, additionalData []byte) (string, error) {
+	nonce := make([]byte, k.primary.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := k.primary.aead.Seal(nonce, nonce, plaintext, additionalData)
+	return k.primary.id + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (k *keyring) Decrypt(value string, additionalData []byte) ([]byte, error) {
+	id, encoded, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrCiphertextInvalid
+	}
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrEncryptionKeyNotFound
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return nil, ErrCiphertextInvalid
+	}
+	nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrCiphertextInvalid
+	}
+	return plaintext, nil
+}
+
+func loadEncryptionKey(opts *EncryptionKeyOptions) (*encryptionKey, error) {
+	if opts.Id == "" || strings.Contains(opts.Id, ".") {
+		return nil, fmt.Errorf("%w: id %q", ErrEncryptionKeyInvalid, opts.Id)
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(opts.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not base64 encoded", ErrEncryptionKeyInvalid, opts.Id)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be 16, 24 or 32 bytes", ErrEncryptionKeyInvalid, opts.Id)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptionKey{
+		id:   opts.Id,
+		aead: aead,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package security
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyOptions(t *testing.T, id string) EncryptionKeyOptions {
+	secret, err := GenerateEncryptionKey()
+	assert.NoError(t, err)
+	return EncryptionKeyOptions{Id: id, Secret: secret}
+}
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring(&KeyringOptions{Keys: []EncryptionKeyOptions{newTestKeyOptions(t, "k1")}})
+	assert.NoError(t, err)
+
+	value, err := k.Encrypt([]byte("secret data"), []byte("session"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "k1."))
+	assert.NotContains(t, value, "secret data")
+
+	other, err := k.Encrypt([]byte("secret data"), []byte("session"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, value, other, "Every encryption should use a new nonce")
+
+	plaintext, err := k.Decrypt(value, []byte("session"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret data", string(plaintext))
+
+	_, err = k.Decrypt(value, []byte("cookie"))
+	assert.ErrorIs(t, err, ErrCiphertextInvalid, "Additional data should be authenticated")
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	oldKey := newTestKeyOptions(t, "old")
+	newKey := newTestKeyOptions(t, "new")
+
+	before, err := NewKeyring(&KeyringOptions{Keys: []EncryptionKeyOptions{oldKey}})
+	assert.NoError(t, err)
+	value, err := before.Encrypt([]byte("data"), nil)
+	assert.NoError(t, err)
+
+	after, err := NewKeyring(&KeyringOptions{Keys: []EncryptionKeyOptions{newKey, oldKey}})
+	assert.NoError(t, err)
+
+	plaintext, err := after.Decrypt(value, nil)
+	assert.NoError(t, err, "Values encrypted with the previous key should decrypt")
+	assert.Equal(t, "data", string(plaintext))
+
+	rotated, err := after.Encrypt(plaintext, nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rotated, "new."), "New values should be encrypted with the primary key")
+
+	_, err = before.Decrypt(rotated, nil)
+	assert.ErrorIs(t, err, ErrEncryptionKeyNotFound)
+}
+
+func TestKeyring_Tampered(t *testing.T) {
+	k, err := NewKeyring(&KeyringOptions{Keys: []EncryptionKeyOptions{newTestKeyOptions(t, "k1")}})
+	assert.NoError(t, err)
+
+	value, err := k.Encrypt([]byte("data"), nil)
+	assert.NoError(t, err)
+
+	sealed, _ := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, "k1."))
+	sealed[len(sealed)-1] ^= 0x01
+	tampered := "k1." + base64.RawURLEncoding.EncodeToString(sealed)
+
+	tests := []string{
+		tampered,
+		"k1",
+		"k1.not-base64!",
+		"k1.AAAA",
+	}
+	for _, value := range tests {
+		_, err = k.Decrypt(value, nil)
+		assert.ErrorIs(t, err, ErrCiphertextInvalid, value)
+	}
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	valid := newTestKeyOptions(t, "k1")
+
+	tests := []struct {
+		name string
+		opts *KeyringOptions
+		err  error
+	}{
+		{"nil", nil, ErrKeyringEmpty},
+		{"empty", &KeyringOptions{}, ErrKeyringEmpty},
+		{"missing id", &KeyringOptions{Keys: []EncryptionKeyOptions{{Secret: valid.Secret}}}, ErrEncryptionKeyInvalid},
+		{"dot in id", &KeyringOptions{Keys: []EncryptionKeyOptions{{Id: "a.b", Secret: valid.Secret}}}, ErrEncryptionKeyInvalid},
+		{"not base64", &KeyringOptions{Keys: []EncryptionKeyOptions{{Id: "k1", Secret: "***"}}}, ErrEncryptionKeyInvalid},
+		{"short key", &KeyringOptions{Keys: []EncryptionKeyOptions{{Id: "k1", Secret: "c2hvcnQ="}}}, ErrEncryptionKeyInvalid},
+		{"duplicate id", &KeyringOptions{Keys: []EncryptionKeyOptions{valid, valid}}, ErrEncryptionKeyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.opts)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -15,12 +15,21 @@ type Service interface {
 	CreateSession(ctx context.Context, model *model.Session) (*model.Session, error)
 	UpdateSession(ctx context.Context, id string, model *model.Session) (*model.Session, error)
 	DeleteSession(ctx context.Context, id string) error
+	DeleteUserSession(ctx context.Context, userId string, id string) error
 
 	GetSessionData(ctx context.Context, id string, key string) (string, error)
 	SetSessionData(ctx context.Context, id string, key string, value string) error
+	DeleteSessionData(ctx context.Context, id string, key string) error
+	IncrementSessionData(ctx context.Context, id string, key string, delta int64) (int64, error)
+	UpdateSessionData(ctx context.Context, id string, update func(data map[string]string) error) (*model.Session, error)
 
 	LoadSession(ctx context.Context, id string) (*model.Session, error)
 	SaveSession(ctx context.Context, session *model.Session) (*model.Session, error)
 
+	RegenerateSession(ctx context.Context, id string) (*model.Session, error)
+	BindUser(ctx context.Context, id string, userId string) (*model.Session, error)
+	Logout(ctx context.Context, id string) (*model.Session, error)
+	LogoutUser(ctx context.Context, userId string) error
+
 	CleanupExpiredSessions(ctx context.Context) error
 }
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/security"
+)
+
+// Binds the stored ciphertext to its purpose, so it can't be replayed as a session cookie
+var sessionDataAdditionalData = []byte("cbm.session.data")
+
+// encryptedDatabase encrypts the session data before it is stored and decrypts it after it is read.
+// Sessions stored before encryption was enabled are read as plaintext and encrypted on the next update.
+type encryptedDatabase struct {
+	database session.Database
+	keyring  security.Keyring
+}
+
+type encryptedSessionRepository struct {
+	repository session.SessionRepository
+	keyring    security.Keyring
+}
+
+func newEncryptedDatabase(database session.Database, keyring security.Keyring) session.Database {
+	return &encryptedDatabase{
+		database: database,
+		keyring:  keyring,
+	}
+}
+
+func (db *encryptedDatabase) Sessions() session.SessionRepository {
+	return &encryptedSessionRepository{
+		repository: db.database.Sessions(),
+		keyring:    db.keyring,
+	}
+}
+
+func (r *encryptedSessionRepository) GetSessions(ctx context.Context, offset int64, limit int64, filter *model.SessionFilter, sort *core.Sort) ([]*model.Session, int64, error) {
+	data, count, err := r.repository.GetSessions(ctx, offset, limit, filter, sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	// A session which can't be decrypted, for example after its key was removed from the keyring,
+	// is left out instead of failing the whole list
+	result := make([]*model.Session, 0, len(data))
+	for _, item := range data {
+		decrypted, err := r.decrypt(item)
+		if err != nil {
+			logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to decrypt session data",
+				slog.String("id", item.Id),
+				slog.Any("error", err),
+			)
+			count--
+			continue
+		}
+		result = append(result, decrypted)
+	}
+	return result, count, nil
+}
+
+func (r *encryptedSessionRepository) GetSessionById(ctx context.Context, id string) (*model.Session, error) {
+	data, err := r.repository.GetSessionById(ctx, id)
+	if err != nil || data == nil {
+		return data, err
+	}
+	decrypted, err := r.decrypt(data)
+	if err != nil {
+		// The session can never be read again, so it is removed and handled as not found
+		logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to decrypt session data, deleting the session",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		err = r.repository.DeleteSession(ctx, data)
+		if err != nil {
+			logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete session from database",
+				slog.String("id", id),
+				slog.Any("error", err),
+			)
+		}
+		return nil, nil
+	}
+	return decrypted, nil
+}
+
+func (r *encryptedSessionRepository) CreateSession(ctx context.Context, model *model.Session) (string, error) {
+	data, err := r.encrypt(model)
+	if err != nil {
+		return "", err
+	}
+	return r.repository.CreateSession(ctx, data)
+}
+
+func (r *encryptedSessionRepository) UpdateSession(ctx context.Context, model *model.Session) error {
+	data, err := r.encrypt(model)
+	if err != nil {
+		return err
+	}
+	err = r.repository.UpdateSession(ctx, data)
+	if err != nil {
+		return err
+	}
+	model.Version = data.Version
+	return nil
+}
+
+func (r *encryptedSessionRepository) DeleteSession(ctx context.Context, model *model.Session) error {
+	return r.repository.DeleteSession(ctx, model)
+}
+
+func (r *encryptedSessionRepository) encrypt(model *model.Session) (*model.Session, error) {
+	plaintext, err := json.Marshal(model.Data)
+	if err != nil {
+		return nil, err
+	}
+	value, err := r.keyring.Encrypt(plaintext, sessionDataAdditionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	data := model.Clone()
+	data.Data = make(map[string]string)
+	data.EncryptedData = value
+	return data, nil
+}
+
+func (r *encryptedSessionRepository) decrypt(data *model.Session) (*model.Session, error) {
+	if data.EncryptedData == "" {
+		return data, nil
+	}
+	plaintext, err := r.keyring.Decrypt(data.EncryptedData, sessionDataAdditionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	err = json.Unmarshal(plaintext, &values)
+	if err != nil {
+		return nil, err
+	}
+	data.Data = values
+	data.EncryptedData = ""
+	return data, nil
+}
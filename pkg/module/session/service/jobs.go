@@ -0,0 +1,27 @@
+package service
+
+import (
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/hosting"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+)
+
+// RegisterJobs registers the periodic jobs of the session module.
+func RegisterJobs(registry hosting.JobRegistry, svc session.Service, opts *ServiceOptions) error {
+	if opts == nil {
+		opts = &ServiceOptions{}
+	}
+	opts.EnsureDefaults()
+
+	schedule, err := hosting.ParseSchedule(opts.CleanupSchedule)
+	if err != nil {
+		return err
+	}
+	return registry.RegisterJob(&hosting.Job{
+		Name:     "session.cleanup",
+		Schedule: schedule,
+		Jitter:   time.Minute,
+		Run:      svc.CleanupExpiredSessions,
+	})
+}
@@ -5,17 +5,29 @@ import (
 
 	"github.com/deb-ict/cloudbm-community/pkg/core"
 	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/security"
 )
 
+// Number of times an update of session data is retried when another request changed the session
+const maxUpdateAttempts = 5
+
 type ServiceOptions struct {
-	FeatureProvider       core.FeatureProvider
-	SessionTimeoutMinutes int64
+	FeatureProvider core.FeatureProvider
+	// Encrypts session data at rest when set
+	Keyring               security.Keyring
+	SessionTimeoutMinutes int64 `yaml:"session_timeout_minutes"`
+	// Oldest sessions of a user are removed when a new session is bound beyond this limit, a negative value disables the limit
+	MaxSessionsPerUser int `yaml:"max_sessions_per_user"`
+	// Schedule of the expired session cleanup, either "@every <duration>" or a cron expression
+	CleanupSchedule string                  `yaml:"cleanup_schedule"`
+	Encryption      security.KeyringOptions `yaml:"encryption"`
 }
 
 type service struct {
-	featureProvider core.FeatureProvider
-	sessionTimeout  time.Duration
-	database        session.Database
+	featureProvider    core.FeatureProvider
+	sessionTimeout     time.Duration
+	maxSessionsPerUser int
+	database           session.Database
 }
 
 func NewService(database session.Database, opts *ServiceOptions) session.Service {
@@ -24,10 +36,15 @@ func NewService(database session.Database, opts *ServiceOptions) session.Service
 	}
 	opts.EnsureDefaults()
 
+	if opts.Keyring != nil {
+		database = newEncryptedDatabase(database, opts.Keyring)
+	}
+
 	svc := &service{
-		featureProvider: opts.FeatureProvider,
-		sessionTimeout:  time.Duration(opts.SessionTimeoutMinutes) * time.Minute,
-		database:        database,
+		featureProvider:    opts.FeatureProvider,
+		sessionTimeout:     time.Duration(opts.SessionTimeoutMinutes) * time.Minute,
+		maxSessionsPerUser: opts.MaxSessionsPerUser,
+		database:           database,
 	}
 
 	return svc
@@ -44,4 +61,10 @@ func (opts *ServiceOptions) EnsureDefaults() {
 	if opts.SessionTimeoutMinutes == 0 {
 		opts.SessionTimeoutMinutes = 30
 	}
+	if opts.MaxSessionsPerUser == 0 {
+		opts.MaxSessionsPerUser = 10
+	}
+	if opts.CleanupSchedule == "" {
+		opts.CleanupSchedule = "@every 15m"
+	}
 }
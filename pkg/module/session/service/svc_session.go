@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/deb-ict/cloudbm-community/pkg/core"
@@ -44,6 +45,7 @@ func (svc *service) GetSessionById(ctx context.Context, id string) (*model.Sessi
 
 func (svc *service) CreateSession(ctx context.Context, model *model.Session) (*model.Session, error) {
 	model.Id = ""
+	model.Version = 1
 	model.CreatedAt = time.Now().UTC()
 	model.SetExpiration(model.Lifetime)
 
@@ -75,9 +77,16 @@ func (svc *service) UpdateSession(ctx context.Context, id string, model *model.S
 	if data.HasExpired() {
 		return data, session.ErrSessionExpired
 	}
+	// A version of zero skips the check and replaces the latest version
+	if model.Version != 0 && model.Version != data.Version {
+		return nil, session.ErrSessionConflict
+	}
 	data.UpdateModel(model)
 
 	err = svc.database.Sessions().UpdateSession(ctx, data)
+	if err == session.ErrSessionConflict {
+		return nil, err
+	}
 	if err != nil {
 		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update session in database",
 			slog.String("id", id),
@@ -114,11 +123,40 @@ func (svc *service) DeleteSession(ctx context.Context, id string) error {
 	return nil
 }
 
+// DeleteUserSession deletes a session of the given user. Sessions of other users are reported as not found.
+func (svc *service) DeleteUserSession(ctx context.Context, userId string, id string) error {
+	data, err := svc.database.Sessions().GetSessionById(ctx, id)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to get session from database by id",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	if data == nil || data.UserId == "" || data.UserId != userId {
+		return session.ErrSessionNotFound
+	}
+
+	err = svc.database.Sessions().DeleteSession(ctx, data)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete session in database",
+			slog.String("id", id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	return nil
+}
+
 func (svc *service) GetSessionData(ctx context.Context, id string, key string) (string, error) {
-	data, err := svc.getOrCreateSession(ctx, id)
+	data, err := svc.LoadSession(ctx, id)
 	if err != nil {
 		return "", err
 	}
+	if data == nil {
+		return "", session.ErrSessionNotFound
+	}
 
 	value, exists := data.Data[key]
 	if !exists {
@@ -129,23 +167,114 @@ func (svc *service) GetSessionData(ctx context.Context, id string, key string) (
 }
 
 func (svc *service) SetSessionData(ctx context.Context, id string, key string, value string) error {
-	data, err := svc.getOrCreateSession(ctx, id)
+	_, err := svc.UpdateSessionData(ctx, id, func(data map[string]string) error {
+		data[key] = value
+		return nil
+	})
+	return err
+}
+
+func (svc *service) DeleteSessionData(ctx context.Context, id string, key string) error {
+	_, err := svc.UpdateSessionData(ctx, id, func(data map[string]string) error {
+		delete(data, key)
+		return nil
+	})
+	return err
+}
+
+// IncrementSessionData adds delta to the integer value of the key, a missing key counts as zero.
+func (svc *service) IncrementSessionData(ctx context.Context, id string, key string, delta int64) (int64, error) {
+	var result int64
+	_, err := svc.UpdateSessionData(ctx, id, func(data map[string]string) error {
+		var value int64
+		if current, ok := data[key]; ok {
+			parsed, err := strconv.ParseInt(current, 10, 64)
+			if err != nil {
+				return session.ErrSessionDataInvalid
+			}
+			value = parsed
+		}
+		result = value + delta
+		data[key] = strconv.FormatInt(result, 10)
+		return nil
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return result, nil
+}
 
-	data.Data[key] = value
+// UpdateSessionData applies update to the latest data of the session and stores it when no other
+// request changed the session in between. On a conflict the update is applied again on the new data,
+// so update can run more than once.
+func (svc *service) UpdateSessionData(ctx context.Context, id string, update func(data map[string]string) error) (*model.Session, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		data, err := svc.GetSessionById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if data.Data == nil {
+			data.Data = make(map[string]string)
+		}
 
-	_, err = svc.UpdateSession(ctx, data.Id, data)
-	if err != nil {
-		return err
+		err = update(data.Data)
+		if err != nil {
+			return nil, err
+		}
+		data.Touch()
+
+		err = svc.database.Sessions().UpdateSession(ctx, data)
+		if err == session.ErrSessionConflict {
+			continue
+		}
+		if err != nil {
+			logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to update session in database",
+				slog.String("id", id),
+				slog.Any("error", err),
+			)
+			return nil, err
+		}
+		return data, nil
 	}
 
-	return nil
+	logging.GetLoggerFromContext(ctx).WarnContext(ctx, "Failed to update session data after retries",
+		slog.String("id", id),
+		slog.Int("attempts", maxUpdateAttempts),
+	)
+	return nil, session.ErrSessionConflict
 }
 
+// LoadSession returns the session of the id and extends its expiration. An unknown or expired id
+// has no session and returns nil, a new session is only stored once it is saved.
 func (svc *service) LoadSession(ctx context.Context, id string) (*model.Session, error) {
-	return svc.getOrCreateSession(ctx, id)
+	if id == "" {
+		return nil, nil
+	}
+
+	data, err := svc.GetSessionById(ctx, id)
+	if err == session.ErrSessionExpired {
+		return nil, svc.DeleteSession(ctx, id)
+	}
+	if err == session.ErrSessionNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if data.UseSlidingExpiration {
+		data.SetExpiration(data.Lifetime)
+		err = svc.database.Sessions().UpdateSession(ctx, data)
+		if err == session.ErrSessionConflict {
+			// Another request updated the session, which extended the expiration as well
+			return svc.GetSessionById(ctx, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
 }
 
 func (svc *service) SaveSession(ctx context.Context, model *model.Session) (*model.Session, error) {
@@ -153,10 +282,14 @@ func (svc *service) SaveSession(ctx context.Context, model *model.Session) (*mod
 	if err != nil {
 		return nil, err
 	}
+	if model.Lifetime <= 0 {
+		model.Lifetime = data.Lifetime
+	}
 	data.UpdateModel(model)
 	data.SetExpiration(data.Lifetime)
 
-	data, err = svc.UpdateSession(ctx, model.Id, data)
+	// A transient session gets its id when it is created above
+	data, err = svc.UpdateSession(ctx, data.Id, data)
 	if err != nil {
 		return nil, err
 	}
@@ -164,45 +297,20 @@ func (svc *service) SaveSession(ctx context.Context, model *model.Session) (*mod
 }
 
 func (svc *service) getOrCreateSession(ctx context.Context, id string) (*model.Session, error) {
-	var data *model.Session = nil
-	var err error = nil
-
-	if id != "" {
-		data, err = svc.GetSessionById(ctx, id)
-
-		if err != nil {
-			if err == session.ErrSessionExpired {
-				err = svc.DeleteSession(ctx, id)
-				data = nil
-			}
-
-			if err != session.ErrSessionNotFound {
-				return nil, err
-			}
-		}
+	data, err := svc.LoadSession(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-
-	if data == nil {
-		data = &model.Session{
-			Lifetime:             svc.sessionTimeout,
-			UseSlidingExpiration: true,
-			Data:                 make(map[string]string),
-		}
-		data, err = svc.CreateSession(ctx, data)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		if data.UseSlidingExpiration {
-			data.SetExpiration(data.Lifetime)
-			err = svc.database.Sessions().UpdateSession(ctx, data)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if data != nil {
+		return data, nil
 	}
 
-	return data, nil
+	data = &model.Session{
+		Lifetime:             svc.sessionTimeout,
+		UseSlidingExpiration: true,
+		Data:                 make(map[string]string),
+	}
+	return svc.CreateSession(ctx, data)
 }
 
 func (svc *service) CleanupExpiredSessions(ctx context.Context) error {
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session"
+	"github.com/deb-ict/cloudbm-community/pkg/module/session/model"
+)
+
+// RegenerateSession moves the session data to a new id and deletes the old session, so an id that
+// leaked before a privilege change can't be used afterwards.
+func (svc *service) RegenerateSession(ctx context.Context, id string) (*model.Session, error) {
+	return svc.regenerateSession(ctx, id, func(data *model.Session) {})
+}
+
+// BindUser attaches the user to the session under a new id, which prevents session fixation on login.
+func (svc *service) BindUser(ctx context.Context, id string, userId string) (*model.Session, error) {
+	if userId == "" {
+		return nil, core.ErrInvalidId
+	}
+
+	data, err := svc.regenerateSession(ctx, id, func(data *model.Session) {
+		data.UserId = userId
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.enforceSessionLimit(ctx, userId, data.Id)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Logout detaches the user from the session under a new id, data that isn't bound to the user is kept.
+func (svc *service) Logout(ctx context.Context, id string) (*model.Session, error) {
+	return svc.regenerateSession(ctx, id, func(data *model.Session) {
+		data.UserId = ""
+	})
+}
+
+// LogoutUser deletes all sessions of the user.
+func (svc *service) LogoutUser(ctx context.Context, userId string) error {
+	if userId == "" {
+		return core.ErrInvalidId
+	}
+
+	filter := &model.SessionFilter{
+		UserId: userId,
+	}
+
+	for {
+		sessions, _, err := svc.GetSessions(ctx, 0, 100, filter, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(sessions) == 0 {
+			break
+		}
+
+		for _, data := range sessions {
+			err = svc.deleteSession(ctx, data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (svc *service) regenerateSession(ctx context.Context, id string, update func(data *model.Session)) (*model.Session, error) {
+	var current *model.Session
+	if id != "" {
+		data, err := svc.GetSessionById(ctx, id)
+		if err != nil && err != session.ErrSessionNotFound && err != session.ErrSessionExpired {
+			return nil, err
+		}
+		// An expired session is replaced without its data
+		if err == session.ErrSessionExpired {
+			err = svc.deleteSession(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			data = nil
+		}
+		current = data
+	}
+
+	data := &model.Session{
+		Lifetime:             svc.sessionTimeout,
+		UseSlidingExpiration: true,
+		Data:                 make(map[string]string),
+	}
+	if current != nil {
+		data = current.Clone()
+		if data.Lifetime <= 0 {
+			data.Lifetime = svc.sessionTimeout
+		}
+	}
+	update(data)
+
+	data, err := svc.CreateSession(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		err = svc.deleteSession(ctx, current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// enforceSessionLimit removes the least recently used sessions of the user, the given session is always kept.
+func (svc *service) enforceSessionLimit(ctx context.Context, userId string, keepId string) error {
+	if svc.maxSessionsPerUser < 0 {
+		return nil
+	}
+
+	filter := &model.SessionFilter{
+		UserId:       userId,
+		ExpiresAfter: time.Now().UTC(),
+	}
+
+	sessions := make([]*model.Session, 0)
+	for offset := int64(0); ; offset += 100 {
+		page, count, err := svc.GetSessions(ctx, offset, 100, filter, nil)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, page...)
+		if len(page) == 0 || int64(len(sessions)) >= count {
+			break
+		}
+	}
+	if len(sessions) <= svc.maxSessionsPerUser {
+		return nil
+	}
+
+	slices.SortFunc(sessions, func(a *model.Session, b *model.Session) int {
+		if a.Id == keepId {
+			return -1
+		}
+		if b.Id == keepId {
+			return 1
+		}
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+
+	for _, data := range sessions[svc.maxSessionsPerUser:] {
+		err := svc.deleteSession(ctx, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc *service) deleteSession(ctx context.Context, data *model.Session) error {
+	err := svc.database.Sessions().DeleteSession(ctx, data)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to delete session in database",
+			slog.String("id", data.Id),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+)
+
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type EmailSender interface {
+	SendEmail(ctx context.Context, message *EmailMessage) error
+}
+
+type logEmailSender struct {
+}
+
+// LogEmailSender writes the messages to the log instead of sending them, for development setups without a mail server.
+func LogEmailSender() EmailSender {
+	return &logEmailSender{}
+}
+
+func (s *logEmailSender) SendEmail(ctx context.Context, message *EmailMessage) error {
+	logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Email message not sent, no mail server configured",
+		slog.String("to", message.To),
+		slog.String("subject", message.Subject),
+		slog.String("body", message.Body),
+	)
+	return nil
+}
@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+)
+
+// OutboxOptions configures files which receive the messages instead of sending them,
+// so development setups can pick up links and codes without a mail server or sms provider.
+type OutboxOptions struct {
+	EmailFile string `yaml:"email_file"`
+	SmsFile   string `yaml:"sms_file"`
+}
+
+type outbox struct {
+	mutex sync.Mutex
+	path  string
+}
+
+type outboxEmailSender struct {
+	outbox
+}
+
+type outboxSmsSender struct {
+	outbox
+}
+
+func NewOutboxEmailSender(path string) EmailSender {
+	return &outboxEmailSender{
+		outbox: outbox{path: core.FixUserFolder(path)},
+	}
+}
+
+func NewOutboxSmsSender(path string) SmsSender {
+	return &outboxSmsSender{
+		outbox: outbox{path: core.FixUserFolder(path)},
+	}
+}
+
+func (s *outboxEmailSender) SendEmail(ctx context.Context, message *EmailMessage) error {
+	return s.write(ctx, fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Body))
+}
+
+func (s *outboxSmsSender) SendSms(ctx context.Context, message *SmsMessage) error {
+	return s.write(ctx, fmt.Sprintf("To: %s\n\n%s\n", message.To, message.Body))
+}
+
+func (o *outbox) write(ctx context.Context, message string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	file, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to open outbox file",
+			slog.String("path", o.path),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "--- %s\n%s\n", time.Now().UTC().Format(time.RFC3339), message)
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to write message to outbox file",
+			slog.String("path", o.path),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package notification
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxEmailSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "email.txt")
+	sender := NewOutboxEmailSender(path)
+
+	err := sender.SendEmail(context.Background(), &EmailMessage{To: "john@example.com", Subject: "First", Body: "Code 123456"})
+	assert.NoError(t, err)
+	err = sender.SendEmail(context.Background(), &EmailMessage{To: "jane@example.com", Subject: "Second", Body: "Code 654321"})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "To: john@example.com\nSubject: First\n\nCode 123456\n")
+	assert.Contains(t, string(data), "To: jane@example.com\nSubject: Second\n\nCode 654321\n")
+}
+
+func TestOutboxSmsSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.txt")
+	sender := NewOutboxSmsSender(path)
+
+	err := sender.SendSms(context.Background(), &SmsMessage{To: "+32470000000", Body: "Code 123456"})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "To: +32470000000\n\nCode 123456\n")
+}
+
+func TestOutboxSenderInvalidPath(t *testing.T) {
+	sender := NewOutboxSmsSender(filepath.Join(t.TempDir(), "missing", "sms.txt"))
+
+	err := sender.SendSms(context.Background(), &SmsMessage{To: "+32470000000", Body: "Code 123456"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,33 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+)
+
+type SmsMessage struct {
+	To   string
+	Body string
+}
+
+type SmsSender interface {
+	SendSms(ctx context.Context, message *SmsMessage) error
+}
+
+type logSmsSender struct {
+}
+
+// LogSmsSender writes the messages to the log instead of sending them, for development setups without a sms provider.
+func LogSmsSender() SmsSender {
+	return &logSmsSender{}
+}
+
+func (s *logSmsSender) SendSms(ctx context.Context, message *SmsMessage) error {
+	logging.GetLoggerFromContext(ctx).InfoContext(ctx, "Sms message not sent, no sms provider configured",
+		slog.String("to", message.To),
+		slog.String("body", message.Body),
+	)
+	return nil
+}
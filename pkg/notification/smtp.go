@@ -0,0 +1,86 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/deb-ict/cloudbm-community/pkg/logging"
+)
+
+const (
+	DEFAULT_SMTP_PORT int = 587
+)
+
+type SmtpOptions struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type smtpEmailSender struct {
+	address string
+	host    string
+	auth    smtp.Auth
+	from    string
+}
+
+func NewSmtpEmailSender(opts *SmtpOptions) EmailSender {
+	opts.EnsureDefaults()
+
+	s := &smtpEmailSender{
+		address: net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)),
+		host:    opts.Host,
+		from:    opts.From,
+	}
+	if opts.Username != "" {
+		s.auth = smtp.PlainAuth("", opts.Username, opts.Password, opts.Host)
+	}
+	return s
+}
+
+func (s *smtpEmailSender) SendEmail(ctx context.Context, message *EmailMessage) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "From: %s\r\n", s.from)
+	fmt.Fprintf(&builder, "To: %s\r\n", message.To)
+	fmt.Fprintf(&builder, "Subject: %s\r\n", message.Subject)
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(message.Body)
+
+	err := smtp.SendMail(s.address, s.auth, s.from, []string{message.To}, []byte(builder.String()))
+	if err != nil {
+		logging.GetLoggerFromContext(ctx).ErrorContext(ctx, "Failed to send email message",
+			slog.String("to", message.To),
+			slog.String("subject", message.Subject),
+			slog.Any("error", err),
+		)
+		return err
+	}
+	return nil
+}
+
+func (opts *SmtpOptions) LoadEnvironment() {
+	password, ok := os.LookupEnv("SMTP_PASSWORD")
+	if ok {
+		slog.InfoContext(context.Background(), "Override smtp password from environment")
+		opts.Password = password
+	}
+}
+
+func (opts *SmtpOptions) EnsureDefaults() {
+	if opts.Port <= 0 {
+		opts.Port = DEFAULT_SMTP_PORT
+	}
+	if opts.From == "" {
+		opts.From = opts.Username
+	}
+}
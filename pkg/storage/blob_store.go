@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBlobNotFound   error = errors.New("blob not found")
+	ErrBlobKeyInvalid error = errors.New("blob key invalid")
+)
+
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore stores files by key. Keys are relative, slash separated paths like "gallery/images/1.png".
+type BlobStore interface {
+	// Put stores the blob, replacing an existing blob with the same key. The blob is only visible when
+	// it's completely written. The size is required by stores that need the length upfront.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrBlobNotFound when the blob doesn't exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	// Stat returns ErrBlobNotFound when the blob doesn't exist.
+	Stat(ctx context.Context, key string) (*BlobInfo, error)
+	// Delete doesn't fail when the blob doesn't exist.
+	Delete(ctx context.Context, key string) error
+	// List returns the blobs with a key starting with the prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]*BlobInfo, error)
+}
+
+// ValidateKey refuses keys that could point outside the store.
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || strings.HasSuffix(key, "/") {
+		return ErrBlobKeyInvalid
+	}
+	if path.Clean(key) != key {
+		return ErrBlobKeyInvalid
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || segment == "." {
+			return ErrBlobKeyInvalid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlobStore runs the behaviour every store has to implement.
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+
+	_, _, err := store.Get(ctx, "gallery/images/1.png")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	_, err = store.Stat(ctx, "gallery/images/1.png")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	for key, data := range map[string]string{
+		"gallery/images/1.png":         "first",
+		"gallery/images/2.png":         "second",
+		"gallery/renditions/1/a b.png": "rendition",
+		"gallery/empty.txt":            "",
+	} {
+		assert.NoError(t, store.Put(ctx, key, strings.NewReader(data), int64(len(data)), "image/png"))
+	}
+	// Replacing a blob
+	assert.NoError(t, store.Put(ctx, "gallery/images/1.png", strings.NewReader("replaced"), 8, "image/png"))
+
+	file, info, err := store.Get(ctx, "gallery/images/1.png")
+	if assert.NoError(t, err) {
+		data, err := io.ReadAll(file)
+		file.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "replaced", string(data))
+		assert.Equal(t, int64(8), info.Size)
+		assert.Equal(t, "image/png", info.ContentType)
+		assert.False(t, info.ModTime.IsZero())
+	}
+
+	info, err = store.Stat(ctx, "gallery/renditions/1/a b.png")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(9), info.Size)
+	}
+
+	list, err := store.List(ctx, "gallery/images/")
+	assert.NoError(t, err)
+	keys := make([]string, 0)
+	for _, item := range list {
+		keys = append(keys, item.Key)
+	}
+	assert.Equal(t, []string{"gallery/images/1.png", "gallery/images/2.png"}, keys)
+
+	list, err = store.List(ctx, "gallery/")
+	assert.NoError(t, err)
+	assert.Len(t, list, 4)
+	list, err = store.List(ctx, "other/")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	assert.NoError(t, store.Delete(ctx, "gallery/images/1.png"))
+	assert.NoError(t, store.Delete(ctx, "gallery/images/1.png"), "Deleting a missing blob should not fail")
+	_, err = store.Stat(ctx, "gallery/images/1.png")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	assert.ErrorIs(t, store.Put(ctx, "../etc/passwd", strings.NewReader("x"), 1, ""), ErrBlobKeyInvalid)
+	_, _, err = store.Get(ctx, "/etc/passwd")
+	assert.ErrorIs(t, err, ErrBlobKeyInvalid)
+}
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"gallery/images/1.png", true},
+		{"1.png", true},
+		{"", false},
+		{"/gallery/1.png", false},
+		{"gallery/../1.png", false},
+		{"../1.png", false},
+		{"gallery//1.png", false},
+		{"gallery/./1.png", false},
+		{"gallery/", false},
+		{"gallery\\1.png", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrBlobKeyInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deb-ict/cloudbm-community/pkg/core"
+)
+
+// Temporary files are hidden from List until they are renamed
+const filesystemTempPrefix string = ".blob-"
+
+type filesystemBlobStore struct {
+	rootFolder string
+}
+
+// NewFilesystemBlobStore stores blobs as files under the root folder, the key is the relative path.
+// The content type isn't stored, it's derived from the file extension.
+func NewFilesystemBlobStore(rootFolder string) BlobStore {
+	return &filesystemBlobStore{
+		rootFolder: rootFolder,
+	}
+}
+
+func (s *filesystemBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	filePath, err := s.getPath(key)
+	if err != nil {
+		return err
+	}
+	err = core.EnsureFolder(filepath.Dir(filePath))
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), filesystemTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = io.Copy(tempFile, r)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
+
+func (s *filesystemBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	filePath, err := s.getPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if fileInfo.IsDir() {
+		file.Close()
+		return nil, nil, ErrBlobNotFound
+	}
+	return file, s.getBlobInfo(key, fileInfo), nil
+}
+
+func (s *filesystemBlobStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	filePath, err := s.getPath(key)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && fileInfo.IsDir()) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.getBlobInfo(key, fileInfo), nil
+}
+
+func (s *filesystemBlobStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.getPath(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *filesystemBlobStore) List(ctx context.Context, prefix string) ([]*BlobInfo, error) {
+	// Walk the deepest folder that contains all keys with the prefix
+	folder := path.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		folder = strings.TrimSuffix(prefix, "/")
+	}
+	if folder != "." {
+		if err := ValidateKey(folder); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*BlobInfo, 0)
+	err := filepath.WalkDir(filepath.Join(s.rootFolder, filepath.FromSlash(folder)), func(filePath string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), filesystemTempPrefix) {
+			return nil
+		}
+		relativePath, err := filepath.Rel(s.rootFolder, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativePath)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		result = append(result, s.getBlobInfo(key, fileInfo))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+func (s *filesystemBlobStore) getPath(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.rootFolder, filepath.FromSlash(key)), nil
+}
+
+func (s *filesystemBlobStore) getBlobInfo(key string, fileInfo fs.FileInfo) *BlobInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &BlobInfo{
+		Key:         key,
+		Size:        fileInfo.Size(),
+		ContentType: contentType,
+		ModTime:     fileInfo.ModTime(),
+	}
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystemBlobStore(t *testing.T) {
+	root := t.TempDir()
+	testBlobStore(t, NewFilesystemBlobStore(root))
+
+	// Keys are stored as relative paths, without leftover temporary files
+	assert.FileExists(t, filepath.Join(root, "gallery", "images", "2.png"))
+	entries, err := os.ReadDir(filepath.Join(root, "gallery", "images"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_S3_REGION string = "us-east-1"
+)
+
+type S3Options struct {
+	// Url of the s3 compatible endpoint, like https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyId     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// Address the bucket as https://<bucket>.<endpoint> instead of https://<endpoint>/<bucket>, most
+	// stand-ins like minio only support the path style
+	VirtualHostStyle bool `yaml:"virtual_host_style"`
+	// HttpClient used for the requests, the default client when nil
+	HttpClient *http.Client `yaml:"-"`
+}
+
+type s3BlobStore struct {
+	endpoint         *url.URL
+	bucket           string
+	virtualHostStyle bool
+	credentials      *sigV4Credentials
+	client           *http.Client
+	now              func() time.Time
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewS3BlobStore stores blobs in a bucket of an s3 compatible store. Requests are signed with aws
+// signature version 4, the payload isn't signed so uploads can be streamed.
+func NewS3BlobStore(opts *S3Options) (BlobStore, error) {
+	opts.EnsureDefaults()
+
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("missing s3 bucket")
+	}
+	client := opts.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &s3BlobStore{
+		endpoint:         endpoint,
+		bucket:           opts.Bucket,
+		virtualHostStyle: opts.VirtualHostStyle,
+		credentials: &sigV4Credentials{
+			accessKeyId:     opts.AccessKeyId,
+			secretAccessKey: opts.SecretAccessKey,
+			region:          opts.Region,
+			service:         "s3",
+		},
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if size == 0 {
+		r = http.NoBody
+	}
+	request, err := s.newRequest(ctx, http.MethodPut, key, nil, r)
+	if err != nil {
+		return err
+	}
+	// S3 doesn't accept chunked uploads without signing every chunk
+	request.ContentLength = size
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := s.do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (s *s3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, nil, err
+	}
+	request, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := s.do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	return response.Body, s.getBlobInfo(key, response), nil
+}
+
+func (s *s3BlobStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	request, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.do(request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	return s.getBlobInfo(key, response), nil
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	request, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	response, err := s.do(request)
+	if err == ErrBlobNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (s *s3BlobStore) List(ctx context.Context, prefix string) ([]*BlobInfo, error) {
+	result := make([]*BlobInfo, 0)
+	continuationToken := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		request, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		response, err := s.do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		var page s3ListBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range page.Contents {
+			result = append(result, &BlobInfo{
+				Key:     content.Key,
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		continuationToken = page.NextContinuationToken
+	}
+}
+
+func (s *s3BlobStore) newRequest(ctx context.Context, method string, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.virtualHostStyle {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	} else {
+		u.Path = basePath + "/" + s.bucket + "/" + key
+	}
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Amz-Content-Sha256", sigV4UnsignedPayload)
+	s.credentials.sign(request, sigV4UnsignedPayload, s.now())
+	return request, nil
+}
+
+// do sends the request and turns error responses into errors, a missing key returns ErrBlobNotFound.
+func (s *s3BlobStore) do(request *http.Request) (*http.Response, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	var s3Err s3Error
+	xml.NewDecoder(io.LimitReader(response.Body, 64<<10)).Decode(&s3Err)
+	return nil, fmt.Errorf("s3 %s %s failed with status %d: %s %s", request.Method, request.URL.Path, response.StatusCode, s3Err.Code, s3Err.Message)
+}
+
+func (s *s3BlobStore) getBlobInfo(key string, response *http.Response) *BlobInfo {
+	info := &BlobInfo{
+		Key:         key,
+		Size:        response.ContentLength,
+		ContentType: response.Header.Get("Content-Type"),
+	}
+	if size, err := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if modTime, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+func (opts *S3Options) LoadEnvironment() {
+	accessKeyId, ok := os.LookupEnv("S3_ACCESS_KEY_ID")
+	if ok {
+		slog.InfoContext(context.Background(), "Override s3 access key id from environment")
+		opts.AccessKeyId = accessKeyId
+	}
+	secretAccessKey, ok := os.LookupEnv("S3_SECRET_ACCESS_KEY")
+	if ok {
+		slog.InfoContext(context.Background(), "Override s3 secret access key from environment")
+		opts.SecretAccessKey = secretAccessKey
+	}
+}
+
+func (opts *S3Options) EnsureDefaults() {
+	if opts.Region == "" {
+		opts.Region = DEFAULT_S3_REGION
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3Object struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// fakeS3Server implements the part of the s3 api used by the store, it verifies the signature of every request.
+type fakeS3Server struct {
+	t           *testing.T
+	bucket      string
+	credentials *sigV4Credentials
+	mutex       sync.Mutex
+	objects     map[string]*fakeS3Object
+}
+
+func newFakeS3Server(t *testing.T, bucket string) *fakeS3Server {
+	return &fakeS3Server{
+		t:      t,
+		bucket: bucket,
+		credentials: &sigV4Credentials{
+			accessKeyId:     "AKIDEXAMPLE",
+			secretAccessKey: "secret",
+			region:          "eu-west-1",
+			service:         "s3",
+		},
+		objects: make(map[string]*fakeS3Object),
+	}
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.verifySignature(r) {
+		s.writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key == "" && r.Method == http.MethodGet {
+		s.list(w, r)
+		return
+	}
+	object := s.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		if len(r.TransferEncoding) > 0 {
+			s.writeError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = &fakeS3Object{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+	case http.MethodGet, http.MethodHead:
+		if object == nil {
+			s.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		w.Header().Set("Last-Modified", object.modTime.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list returns pages of two keys, so the continuation is tested.
+func (s *fakeS3Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	keys := make([]string, 0)
+	for key := range s.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := struct {
+		XMLName  xml.Name `xml:"ListBucketResult"`
+		Contents []struct {
+			Key          string
+			Size         int
+			LastModified time.Time
+		}
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	for i, key := range keys {
+		if i == 2 {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[i-1]
+			break
+		}
+		result.Contents = append(result.Contents, struct {
+			Key          string
+			Size         int
+			LastModified time.Time
+		}{key, len(s.objects[key].data), s.objects[key].modTime})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeS3Server) verifySignature(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	_, signedHeaders, ok := strings.Cut(authorization, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+	date, err := time.Parse(sigV4DateLayout, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	// Sign a copy with only the signed headers and compare the result
+	clone := r.Clone(r.Context())
+	clone.Header = http.Header{}
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			clone.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	clone.URL.Host = r.Host
+	s.credentials.sign(clone, r.Header.Get("X-Amz-Content-Sha256"), date)
+	return clone.Header.Get("Authorization") == authorization
+}
+
+func (s *fakeS3Server) writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func TestS3BlobStore(t *testing.T) {
+	server := httptest.NewServer(newFakeS3Server(t, "media"))
+	defer server.Close()
+
+	store, err := NewS3BlobStore(&S3Options{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "media",
+		AccessKeyId:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	assert.NoError(t, err)
+	testBlobStore(t, store)
+
+	// A wrong secret is refused by the server
+	store, err = NewS3BlobStore(&S3Options{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "media",
+		AccessKeyId:     "AKIDEXAMPLE",
+		SecretAccessKey: "wrong",
+	})
+	assert.NoError(t, err)
+	_, err = store.Stat(t.Context(), "gallery/images/2.png")
+	assert.ErrorContains(t, err, "status 403")
+}
+
+func TestNewS3BlobStore_Invalid(t *testing.T) {
+	_, err := NewS3BlobStore(&S3Options{Bucket: "media"})
+	assert.Error(t, err)
+	_, err = NewS3BlobStore(&S3Options{Endpoint: "http://localhost:9000"})
+	assert.Error(t, err)
+}
+
+func TestSigV4Sign(t *testing.T) {
+	// The get-vanilla case of the aws signature version 4 test suite
+	credentials := &sigV4Credentials{
+		accessKeyId:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		region:          "us-east-1",
+		service:         "service",
+	}
+	r := httptest.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	r.Header = http.Header{}
+	credentials.sign(r, sigV4Hash(nil), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", r.Header.Get("Authorization"))
+	assert.Equal(t, "20150830T123600Z", r.Header.Get("X-Amz-Date"))
+}
+
+func TestSigV4Escape(t *testing.T) {
+	assert.Equal(t, "a%20b%2Fc~d", sigV4Escape("a b/c~d"))
+	assert.Equal(t, "/media/gallery/a%20b.png", sigV4CanonicalUri(&url.URL{Path: "/media/gallery/a b.png"}))
+}
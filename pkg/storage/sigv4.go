@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       string = "AWS4-HMAC-SHA256"
+	sigV4DateLayout      string = "20060102T150405Z"
+	sigV4UnsignedPayload string = "UNSIGNED-PAYLOAD"
+)
+
+// sigV4Credentials signs requests with aws signature version 4, as used by s3 compatible stores.
+type sigV4Credentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	region          string
+	service         string
+}
+
+// sign adds the date and authorization header, all headers set on the request are signed.
+func (c *sigV4Credentials) sign(r *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4DateLayout)
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format("20060102"), c.region, c.service)
+	r.Header.Set("X-Amz-Date", amzDate)
+
+	canonicalHeaders, signedHeaders := sigV4CanonicalHeaders(r)
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		sigV4CanonicalUri(r.URL),
+		sigV4CanonicalQuery(r.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sigV4Hash([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := sigV4Hmac([]byte("AWS4"+c.secretAccessKey), []byte(now.Format("20060102")))
+	key = sigV4Hmac(key, []byte(c.region))
+	key = sigV4Hmac(key, []byte(c.service))
+	key = sigV4Hmac(key, []byte("aws4_request"))
+	signature := hex.EncodeToString(sigV4Hmac(key, []byte(stringToSign)))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, c.accessKeyId, scope, signedHeaders, signature))
+}
+
+func sigV4CanonicalHeaders(r *http.Request) (string, string) {
+	headers := map[string]string{
+		"host": r.URL.Host,
+	}
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return builder.String(), strings.Join(names, ";")
+}
+
+func sigV4CanonicalUri(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	// Each segment is encoded with the strict s3 rules
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			unescaped = segment
+		}
+		segments[i] = sigV4Escape(unescaped)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sigV4CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent encodes everything except the unreserved characters.
+func sigV4Escape(value string) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '.' || b == '_' || b == '~' {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+func sigV4Hash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func sigV4Hmac(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}